@@ -0,0 +1,235 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	contextAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// TxCommitStatus is the outcome of an asynchronously submitted transaction
+type TxCommitStatus struct {
+	TxID           fabAPI.TransactionID
+	ValidationCode pb.TxValidationCode
+	BlockNumber    uint64
+	// Err is set if the transaction was not committed as VALID or its status was not received.
+	// Use LedgerErrorKindOf to tell an MVCC conflict from an endorsement policy failure.
+	Err error
+}
+
+// AsyncSubmitter endorses and orders transactions without waiting for them to be committed.
+// All transactions share the event service connection of the channel to receive their status.
+type AsyncSubmitter struct {
+	chClient      *channel.Client
+	events        *event.Client
+	commitTimeout time.Duration
+	done          chan struct{}
+	// mutex orders the submissions counted in pending with closing the submitter
+	mutex   sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// NewAsyncSubmitter creates an AsyncSubmitter for the given channel context.
+// A commit status that is not received within commitTimeout is reported as a timeout,
+// zero means waiting until the submitter is closed.
+func NewAsyncSubmitter(ctx contextAPI.ChannelProvider, commitTimeout time.Duration) (*AsyncSubmitter, error) {
+	chClient, err := channel.New(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create new channel client")
+	}
+
+	events, err := event.New(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create new event client")
+	}
+
+	return &AsyncSubmitter{
+		chClient:      chClient,
+		events:        events,
+		commitTimeout: commitTimeout,
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// Submit endorses the request and sends it to the orderer. It returns as soon as the orderer
+// accepted the transaction, the commit status is delivered later on the returned channel.
+// reqCtx only bounds the endorsement and ordering.
+func (s *AsyncSubmitter) Submit(reqCtx context.Context, request channel.Request, options ...channel.RequestOption) (fabAPI.TransactionID, <-chan TxCommitStatus, error) {
+	notifier := make(chan TxCommitStatus, 1)
+	txID, err := s.SubmitWithCallback(reqCtx, request, func(st TxCommitStatus) {
+		notifier <- st
+	}, options...)
+	if err != nil {
+		return "", nil, err
+	}
+	return txID, notifier, nil
+}
+
+// SubmitWithCallback is like Submit but calls callback with the commit status.
+// The callback runs on its own goroutine.
+func (s *AsyncSubmitter) SubmitWithCallback(reqCtx context.Context, request channel.Request, callback func(TxCommitStatus), options ...channel.RequestOption) (fabAPI.TransactionID, error) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return "", errors.New("submitter is closed")
+	}
+	s.pending.Add(1)
+	s.mutex.Unlock()
+
+	commit := &asyncCommitHandler{submitter: s, callback: callback}
+	handler := invoke.NewSelectAndEndorseHandler(
+		invoke.NewEndorsementValidationHandler(
+			invoke.NewSignatureValidationHandler(commit),
+		),
+	)
+
+	options = append([]channel.RequestOption{channel.WithParentContext(reqCtx)}, options...)
+	response, err := s.chClient.InvokeHandler(handler, request, options...)
+	if err != nil {
+		commit.abandon()
+		return "", err
+	}
+	return response.TransactionID, nil
+}
+
+// Close stops waiting for pending commit statuses, which are then reported as failed,
+// and waits for their callbacks to return. Submissions accepted before Close are waited
+// for, later ones fail.
+func (s *AsyncSubmitter) Close() {
+	s.mutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mutex.Unlock()
+	s.pending.Wait()
+}
+
+// asyncCommitHandler registers for the transaction status, sends the transaction to
+// the orderer and waits for the status in the background. The goroutine waiting for
+// the status takes over the pending count of the submission, which is released by
+// abandon if the submission fails before.
+type asyncCommitHandler struct {
+	submitter *AsyncSubmitter
+	callback  func(TxCommitStatus)
+	mutex     sync.Mutex
+	waiting   bool
+	abandoned bool
+}
+
+// abandon releases the pending count of a failed submission unless its status is
+// already awaited. The SDK may return before Handle does, so a later Handle stops.
+func (h *asyncCommitHandler) abandon() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.abandoned = true
+	if !h.waiting {
+		h.submitter.pending.Done()
+	}
+}
+
+// Handle sends the endorsed transaction
+func (h *asyncCommitHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	s := h.submitter
+	txnID := requestContext.Response.TransactionID
+
+	reg, statusNotifier, err := s.events.RegisterTxStatusEvent(string(txnID))
+	if err != nil {
+		requestContext.Error = errors.Wrap(err, "error registering for TxStatus event")
+		return
+	}
+
+	tx, err := clientContext.Transactor.CreateTransaction(fabAPI.TransactionRequest{
+		Proposal:          requestContext.Response.Proposal,
+		ProposalResponses: requestContext.Response.Responses,
+	})
+	if err != nil {
+		s.events.Unregister(reg)
+		requestContext.Error = errors.WithMessage(err, "CreateTransaction failed")
+		return
+	}
+	if _, err = clientContext.Transactor.SendTransaction(tx); err != nil {
+		s.events.Unregister(reg)
+		requestContext.Error = errors.WithMessage(err, "SendTransaction failed")
+		return
+	}
+
+	h.mutex.Lock()
+	if h.abandoned {
+		h.mutex.Unlock()
+		s.events.Unregister(reg)
+		requestContext.Error = errors.New("submission was abandoned")
+		return
+	}
+	h.waiting = true
+	h.mutex.Unlock()
+
+	go func() {
+		defer s.pending.Done()
+		defer s.events.Unregister(reg)
+		h.callback(s.waitForStatus(txnID, statusNotifier))
+	}()
+}
+
+func (s *AsyncSubmitter) waitForStatus(txnID fabAPI.TransactionID, statusNotifier <-chan *fabAPI.TxStatusEvent) TxCommitStatus {
+	result := TxCommitStatus{TxID: txnID}
+
+	var timeout <-chan time.Time
+	if s.commitTimeout > 0 {
+		timer := time.NewTimer(s.commitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case txStatus, ok := <-statusNotifier:
+		if !ok {
+			result.Err = errors.New("event channel closed before the transaction status was received")
+			return result
+		}
+		result.ValidationCode = txStatus.TxValidationCode
+		result.BlockNumber = txStatus.BlockNumber
+		if txStatus.TxValidationCode != pb.TxValidationCode_VALID {
+			result.Err = status.New(status.EventServerStatus, int32(txStatus.TxValidationCode),
+				"received invalid transaction", nil)
+		}
+	case <-timeout:
+		result.Err = status.New(status.ClientStatus, status.Timeout.ToInt32(),
+			"transaction status was not received in time", nil)
+	case <-s.done:
+		result.Err = errors.New("submitter closed before the transaction status was received")
+	}
+	return result
+}
+
+// SetKeyDataAsync submits a set of the given key value in cc without waiting for the commit.
+// The commit status is delivered on the returned channel.
+func SetKeyDataAsync(reqCtx context.Context, submitter *AsyncSubmitter, chaincodeID, value string, key string, policy RetryPolicy) (fabAPI.TransactionID, <-chan TxCommitStatus, error) {
+	txID, notifier, err := submitter.Submit(reqCtx,
+		channel.Request{
+			ChaincodeID: chaincodeID,
+			Fcn:         "invoke",
+			Args:        ExampleCCTxSetArgs(key, value),
+		},
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", nil, newLedgerErrorWithContext(reqCtx, "set", key, err)
+	}
+	return txID, notifier, nil
+}
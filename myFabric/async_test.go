@@ -0,0 +1,99 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+)
+
+// newTestSubmitter submits to a peer answering with the given handler
+func newTestSubmitter(t *testing.T, handler func(args []string) mockResponse) *AsyncSubmitter {
+	s, err := NewAsyncSubmitter(newMockChannelProvider(t, newMockPeer(handler)), 0)
+	if err != nil {
+		t.Fatalf("creating submitter failed: %s", err)
+	}
+	return s
+}
+
+func TestAsyncSubmitterSubmit(t *testing.T) {
+	cc := newFakeExampleCC(map[string]string{})
+	cc.fail["bad"] = ccErrorResponse(CCErrInvalidValue, "bad")
+	s := newTestSubmitter(t, cc.handle)
+	defer s.Close()
+
+	txID, notifier, err := SetKeyDataAsync(context.Background(), s, "examplecc", "1", "a", GatewayRetryPolicy)
+	if err != nil {
+		t.Fatalf("submitting failed: %s", err)
+	}
+	st := <-notifier
+	if st.Err != nil || st.TxID != txID {
+		t.Fatalf("expected transaction %s to be committed, got %+v", txID, st)
+	}
+
+	if _, _, err := SetKeyDataAsync(context.Background(), s, "examplecc", "1", "bad", GatewayRetryPolicy); !IsLedgerErrorKind(err, ErrChaincode) {
+		t.Fatalf("expected a chaincode error, got %v", err)
+	}
+}
+
+func TestAsyncSubmitterClose(t *testing.T) {
+	s := newTestSubmitter(t, newFakeExampleCC(map[string]string{}).handle)
+	s.Close()
+	s.Close()
+
+	_, err := s.SubmitWithCallback(context.Background(), channel.Request{ChaincodeID: "examplecc", Fcn: "invoke", Args: ExampleCCTxSetArgs("a", "1")},
+		func(TxCommitStatus) { t.Error("callback of a rejected submission called") })
+	if err == nil {
+		t.Fatal("expected a submission after Close to fail")
+	}
+}
+
+// TestAsyncSubmitterConcurrentClose closes the submitter while transactions are submitted.
+// Every accepted submission must have called back when Close returns.
+func TestAsyncSubmitterConcurrentClose(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		// Close once the first submission is endorsed, while the others are in flight
+		cc := newFakeExampleCC(map[string]string{})
+		endorsing := make(chan struct{})
+		var once sync.Once
+		s := newTestSubmitter(t, func(args []string) mockResponse {
+			once.Do(func() { close(endorsing) })
+			time.Sleep(time.Millisecond)
+			return cc.handle(args)
+		})
+
+		var accepted, callbacks int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				request := channel.Request{ChaincodeID: "examplecc", Fcn: "invoke", Args: ExampleCCTxSetArgs("a", "1")}
+				_, err := s.SubmitWithCallback(context.Background(), request, func(TxCommitStatus) {
+					atomic.AddInt32(&callbacks, 1)
+				})
+				if err == nil {
+					atomic.AddInt32(&accepted, 1)
+				}
+			}()
+		}
+
+		<-endorsing
+		s.Close()
+		atClose := atomic.LoadInt32(&callbacks)
+		wg.Wait()
+		if n := atomic.LoadInt32(&accepted); atClose != n || atomic.LoadInt32(&callbacks) != n {
+			t.Fatalf("round %d: %d submissions accepted but %d called back before Close returned and %d in total",
+				round, n, atClose, atomic.LoadInt32(&callbacks))
+		}
+	}
+}
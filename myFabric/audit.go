@@ -0,0 +1,157 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// Audit report formats
+const (
+	AuditJSON = "json"
+	AuditCSV  = "csv"
+)
+
+// auditCSVHeader names the columns of a CSV audit report
+var auditCSVHeader = []string{"key", "txId", "timestamp", "blockNumber", "creatorMspId", "creatorSubject", "operation", "value", "isDelete", "validationCode"}
+
+// KeyModification is a change of a key of example CC
+type KeyModification struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     string    `json:"value"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// AuditRecord is a change of a key together with the client that submitted it
+type AuditRecord struct {
+	KeyModification
+	BlockNumber    uint64 `json:"blockNumber"`
+	CreatorMSPID   string `json:"creatorMspId"`
+	CreatorSubject string `json:"creatorSubject"`
+	// Operation is the example CC action of the transaction, e.g. set or move
+	Operation      string `json:"operation"`
+	ValidationCode string `json:"validationCode"`
+}
+
+// AuditReport lists every change of a key, oldest first
+type AuditReport struct {
+	ChannelID   string         `json:"channelId"`
+	ChaincodeID string         `json:"chaincodeId"`
+	Key         string         `json:"key"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Records     []*AuditRecord `json:"records"`
+}
+
+// ExampleCCHistoryArgs returns example cc args that query the history of the given key
+func ExampleCCHistoryArgs(key string) [][]byte {
+	return [][]byte{[]byte("history"), []byte(key)}
+}
+
+// GetKeyHistoryWithContext queries every modification of the given key, oldest first.
+// On failure a *LedgerError is returned, with Kind ErrNotFound if the key has no history.
+func GetKeyHistoryWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, policy RetryPolicy) ([]*KeyModification, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCHistoryArgs(key),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "history", key, err)
+	}
+
+	var mods []*KeyModification
+	if err := json.Unmarshal(response.Payload, &mods); err != nil {
+		return nil, errors.Wrap(err, "decoding history failed")
+	}
+	return mods, nil
+}
+
+// AuditKey builds the audit report of the given key. The history of the key is joined with
+// the transactions queried by the explorer, which name the client of each change.
+func AuditKey(reqCtx context.Context, chClient *channel.Client, explorer *Explorer, channelID, ccID, key string, policy RetryPolicy) (*AuditReport, error) {
+	mods, err := GetKeyHistoryWithContext(reqCtx, chClient, ccID, key, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{ChannelID: channelID, ChaincodeID: ccID, Key: key, GeneratedAt: time.Now().UTC(), Records: []*AuditRecord{}}
+	for _, mod := range mods {
+		tx, err := explorer.Transaction(reqCtx, fabAPI.TransactionID(mod.TxID))
+		if err != nil {
+			return nil, errors.WithMessage(err, "querying transaction ["+mod.TxID+"] failed")
+		}
+
+		rec := &AuditRecord{KeyModification: *mod, BlockNumber: tx.BlockNumber, ValidationCode: tx.ValidationCode}
+		if tx.Creator != nil {
+			rec.CreatorMSPID = tx.Creator.MSPID
+			rec.CreatorSubject = tx.Creator.Subject
+		}
+		for _, action := range tx.Actions {
+			if action.Chaincode != ccID {
+				continue
+			}
+			rec.Operation = action.Function
+			if action.Function == "invoke" && len(action.Args) > 0 {
+				rec.Operation = action.Args[0]
+			}
+			break
+		}
+		report.Records = append(report.Records, rec)
+	}
+	return report, nil
+}
+
+// WriteAuditReport writes the report to out as indented JSON or as CSV with a header
+func WriteAuditReport(out io.Writer, format string, report *AuditReport) error {
+	switch format {
+	case AuditJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "encoding audit report failed")
+		}
+		_, err = out.Write(append(data, '\n'))
+		return errors.Wrap(err, "writing audit report failed")
+	case AuditCSV:
+		w := csv.NewWriter(out)
+		if err := w.Write(auditCSVHeader); err != nil {
+			return errors.Wrap(err, "writing audit report failed")
+		}
+		for _, rec := range report.Records {
+			err := w.Write([]string{
+				report.Key,
+				rec.TxID,
+				rec.Timestamp.Format(time.RFC3339Nano),
+				strconv.FormatUint(rec.BlockNumber, 10),
+				rec.CreatorMSPID,
+				rec.CreatorSubject,
+				rec.Operation,
+				rec.Value,
+				strconv.FormatBool(rec.IsDelete),
+				rec.ValidationCode,
+			})
+			if err != nil {
+				return errors.Wrap(err, "writing audit report failed")
+			}
+		}
+		w.Flush()
+		return errors.Wrap(w.Error(), "writing audit report failed")
+	}
+	return errors.Errorf("invalid audit report format [%s]", format)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// newTestAuditor serves the history of key a of example CC together with the blocks of its
+// transactions, it returns the explorer querying them
+func newTestAuditor(t *testing.T) *Explorer {
+	ledger := &mockLedger{}
+	ledger.add(
+		newMockBlock(t, 0),
+		newMockBlock(t, 1, mockTx{txID: "tx1", args: []string{"invoke", "set", "a", "100"}, code: pb.TxValidationCode_VALID}),
+		newMockBlock(t, 2,
+			mockTx{txID: "tx2", args: []string{"invoke", "move", "a", "b", "10"}, code: pb.TxValidationCode_VALID},
+			mockTx{txID: "tx3", args: []string{"invoke", "delete", "a"}, code: pb.TxValidationCode_VALID},
+		),
+	)
+	history, _ := json.Marshal([]*KeyModification{
+		{TxID: "tx1", Timestamp: mockTxTimestamp(1), Value: "100"},
+		{TxID: "tx2", Timestamp: mockTxTimestamp(2), Value: "90"},
+		{TxID: "tx3", Timestamp: mockTxTimestamp(2), IsDelete: true},
+	})
+	peer := newMockPeer(func(args []string) mockResponse {
+		if len(args) == 3 && args[0] == "invoke" && args[1] == "history" {
+			switch args[2] {
+			case "a":
+				return ccSuccess(string(history))
+			case "ghost":
+				return ccSuccess(`[{"txId":"tx9","value":"1"}]`)
+			}
+			return ccErrorResponse(CCErrNotFound, args[2])
+		}
+		return ledger.handle(args)
+	})
+	setMockClients(t, peer)
+
+	explorer, err := NewExplorer(org1ChannelClientContext)
+	if err != nil {
+		t.Fatalf("creating explorer failed: %s", err)
+	}
+	return explorer
+}
+
+func TestAuditKey(t *testing.T) {
+	explorer := newTestAuditor(t)
+
+	report, err := AuditKey(context.Background(), chClient, explorer, mockChannelID, "examplecc", "a", GatewayRetryPolicy)
+	if err != nil {
+		t.Fatalf("auditing key failed: %s", err)
+	}
+	if report.Key != "a" || report.ChannelID != mockChannelID || report.ChaincodeID != "examplecc" || report.GeneratedAt.IsZero() {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	want := []struct {
+		txID      string
+		block     uint64
+		operation string
+		value     string
+		isDelete  bool
+	}{
+		{"tx1", 1, "set", "100", false},
+		{"tx2", 2, "move", "90", false},
+		{"tx3", 2, "delete", "", true},
+	}
+	if len(report.Records) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(report.Records))
+	}
+	for i, w := range want {
+		rec := report.Records[i]
+		if rec.TxID != w.txID || rec.BlockNumber != w.block || rec.Operation != w.operation || rec.Value != w.value || rec.IsDelete != w.isDelete {
+			t.Errorf("expected record %d to be %+v, got %+v", i, w, rec)
+		}
+		if rec.CreatorMSPID != "Org1MSP" || !strings.Contains(rec.CreatorSubject, "CN=User1@org1.example.com") || rec.ValidationCode != "VALID" {
+			t.Errorf("expected record %d to name the valid transaction of User1, got %+v", i, rec)
+		}
+	}
+
+	if _, err := AuditKey(context.Background(), chClient, explorer, mockChannelID, "examplecc", "x", GatewayRetryPolicy); !IsLedgerErrorKind(err, ErrNotFound) {
+		t.Fatalf("expected a key without history not to be found, got %v", err)
+	}
+	if _, err := AuditKey(context.Background(), chClient, explorer, mockChannelID, "examplecc", "ghost", GatewayRetryPolicy); err == nil || !strings.Contains(err.Error(), "tx9") {
+		t.Fatalf("expected an unknown transaction to fail the report, got %v", err)
+	}
+}
+
+func testAuditReport() *AuditReport {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	return &AuditReport{
+		ChannelID:   mockChannelID,
+		ChaincodeID: "examplecc",
+		Key:         "a",
+		GeneratedAt: ts.Add(time.Hour),
+		Records: []*AuditRecord{
+			{KeyModification: KeyModification{TxID: "tx1", Timestamp: ts, Value: "1,2"}, BlockNumber: 3,
+				CreatorMSPID: "Org1MSP", CreatorSubject: "CN=user1,OU=client", Operation: "set", ValidationCode: "VALID"},
+			{KeyModification: KeyModification{TxID: "tx2", Timestamp: ts.Add(time.Minute), IsDelete: true}, BlockNumber: 4,
+				CreatorMSPID: "Org2MSP", CreatorSubject: "CN=user2", Operation: "delete", ValidationCode: "VALID"},
+		},
+	}
+}
+
+func TestWriteAuditReportJSON(t *testing.T) {
+	report := testAuditReport()
+	out := &bytes.Buffer{}
+	if err := WriteAuditReport(out, AuditJSON, report); err != nil {
+		t.Fatalf("writing report failed: %s", err)
+	}
+
+	decoded := &AuditReport{}
+	if err := json.Unmarshal(out.Bytes(), decoded); err != nil {
+		t.Fatalf("decoding report failed: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, report) {
+		t.Fatalf("expected %+v, got %+v", report, decoded)
+	}
+	if !strings.HasSuffix(out.String(), "}\n") {
+		t.Fatal("expected the report to end with a newline")
+	}
+}
+
+func TestWriteAuditReportCSV(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := WriteAuditReport(out, AuditCSV, testAuditReport()); err != nil {
+		t.Fatalf("writing report failed: %s", err)
+	}
+
+	rows, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatalf("reading report failed: %s", err)
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[0], auditCSVHeader) {
+		t.Fatalf("expected the header %v and 2 rows, got %v", auditCSVHeader, rows)
+	}
+	want := [][]string{
+		{"a", "tx1", "2020-01-02T03:04:05.000000006Z", "3", "Org1MSP", "CN=user1,OU=client", "set", "1,2", "false", "VALID"},
+		{"a", "tx2", "2020-01-02T03:05:05.000000006Z", "4", "Org2MSP", "CN=user2", "delete", "", "true", "VALID"},
+	}
+	if !reflect.DeepEqual(rows[1:], want) {
+		t.Fatalf("expected rows %v, got %v", want, rows[1:])
+	}
+
+	// A report without records is just the header
+	out.Reset()
+	if err := WriteAuditReport(out, AuditCSV, &AuditReport{Key: "a"}); err != nil {
+		t.Fatalf("writing report failed: %s", err)
+	}
+	if out.String() != strings.Join(auditCSVHeader, ",")+"\n" {
+		t.Fatalf("expected only the header, got [%s]", out.String())
+	}
+}
+
+func TestWriteAuditReportInvalidFormat(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := WriteAuditReport(out, "xml", testAuditReport()); err == nil || out.Len() != 0 {
+		t.Fatalf("expected an invalid format to fail without output, got %v", err)
+	}
+}
@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
 	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
@@ -26,9 +28,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"go/build"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -91,6 +95,59 @@ func ExampleCCTxSetArgs(key, value string) [][]byte {
 	return [][]byte{[]byte("set"), []byte(key), []byte(value)}
 }
 
+// TransferLeg moves an amount from one key to another as part of a transfer
+type TransferLeg struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+//ExampleCCTransferArgs returns example cc args that apply all legs in a single transaction
+func ExampleCCTransferArgs(legs []TransferLeg) [][]byte {
+	legsJSON, err := json.Marshal(legs)
+	if err != nil {
+		// Cannot happen, a TransferLeg always marshals
+		panic(err)
+	}
+	return [][]byte{[]byte("transfer"), legsJSON}
+}
+
+// TokenHolding is an initial balance of example CC in token mode
+type TokenHolding struct {
+	Key    string
+	Amount int
+}
+
+//ExampleCCInitTokenArgs returns example cc init args that enable token mode. The total supply
+//is the sum of the holdings, mint and burn are restricted to members of the issuer MSP.
+func ExampleCCInitTokenArgs(issuer string, decimals, supplyCap int, holdings []TokenHolding) [][]byte {
+	args := [][]byte{[]byte("inittoken"), []byte(issuer), []byte(strconv.Itoa(decimals)), []byte(strconv.Itoa(supplyCap))}
+	for _, h := range holdings {
+		args = append(args, []byte(h.Key), []byte(strconv.Itoa(h.Amount)))
+	}
+	return args
+}
+
+//ExampleCCMintArgs returns example cc args that create an amount of tokens in the given key
+func ExampleCCMintArgs(to string, amount int) [][]byte {
+	return [][]byte{[]byte("mint"), []byte(to), []byte(strconv.Itoa(amount))}
+}
+
+//ExampleCCBurnArgs returns example cc args that destroy an amount of tokens of the given key
+func ExampleCCBurnArgs(from string, amount int) [][]byte {
+	return [][]byte{[]byte("burn"), []byte(from), []byte(strconv.Itoa(amount))}
+}
+
+//ExampleCCDeleteArgs returns example cc args that delete the given key
+func ExampleCCDeleteArgs(key string) [][]byte {
+	return [][]byte{[]byte("delete"), []byte(key)}
+}
+
+//ExampleCCRangeArgs returns example cc args that list a page of keys in [startKey, endKey)
+func ExampleCCRangeArgs(startKey, endKey string, pageSize int32, bookmark string) [][]byte {
+	return [][]byte{[]byte("range"), []byte(startKey), []byte(endKey), []byte(strconv.Itoa(int(pageSize))), []byte(bookmark)}
+}
+
 //ExampleCCInitArgs returns example cc initialization args
 func ExampleCCInitArgs() [][]byte {
 	return initArgs
@@ -175,6 +232,11 @@ func GetChannelConfigPath(filename string) string {
 	return path.Join(goPath(), "src", Project, ChannelConfigPath, filename)
 }
 
+// GetConfigtxPath returns the path to configtx.yaml
+func GetConfigtxPath() string {
+	return path.Join(goPath(), "src", Project, ConfigtxPath)
+}
+
 // GetConfigPath returns the path to the named config fixture file
 func GetConfigPath(filename string) string {
 	const configPath = "fixtures/config"
@@ -187,10 +249,14 @@ func GetConfigOverridesPath(filename string) string {
 	return path.Join(goPath(), "src", Project, configPath, "overrides", filename)
 }
 
+// GetCryptogenConfigPath returns the path to cryptogen.yaml
+func GetCryptogenConfigPath() string {
+	return path.Join(goPath(), "src", Project, CryptogenConfigPath)
+}
+
 // GetCryptoConfigPath returns the path to the named crypto-config override fixture file
 func GetCryptoConfigPath(filename string) string {
-	const configPath = "fixtures/fabric/v1/crypto-config"
-	return path.Join(goPath(), "src", Project, configPath, filename)
+	return path.Join(goPath(), "src", Project, CryptoConfigPath, filename)
 }
 
 // goPath returns the current GOPATH. If the system
@@ -212,7 +278,8 @@ type OrgContext struct {
 	AnchorPeerConfigFile string
 }
 
-// CreateChannelAndUpdateAnchorPeers creates the channel and updates all of the anchor peers for all orgs
+// CreateChannelAndUpdateAnchorPeers creates the channel and updates all of the anchor peers for all orgs.
+// Failures are returned rather than reported to t, which may be nil outside of tests.
 func CreateChannelAndUpdateAnchorPeers(t *testing.T, sdk *fabsdk.FabricSDK, channelID string, channelConfigFile string, orgsContext []*OrgContext) error {
 	ordererCtx := sdk.Context(fabsdk.WithUser(AdminUser), fabsdk.WithOrg(OrdererOrgName))
 
@@ -238,7 +305,9 @@ func CreateChannelAndUpdateAnchorPeers(t *testing.T, sdk *fabsdk.FabricSDK, chan
 		return err
 	}
 
-	lastConfigBlock = WaitForOrdererConfigUpdate(t, orgsContext[0].ResMgmt, channelID, true, lastConfigBlock)
+	if lastConfigBlock, err = waitForConfigBlock(orgsContext[0].ResMgmt, channelID, ordererEndpoint, true, lastConfigBlock); err != nil {
+		return err
+	}
 
 	for _, orgCtx := range orgsContext {
 		req := resmgmt.SaveChannelRequest{
@@ -250,7 +319,9 @@ func CreateChannelAndUpdateAnchorPeers(t *testing.T, sdk *fabsdk.FabricSDK, chan
 			return err
 		}
 
-		lastConfigBlock = WaitForOrdererConfigUpdate(t, orgCtx.ResMgmt, channelID, false, lastConfigBlock)
+		if lastConfigBlock, err = waitForConfigBlock(orgCtx.ResMgmt, channelID, ordererEndpoint, false, lastConfigBlock); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -374,6 +445,7 @@ func DiscoverLocalPeers(ctxProvider contextAPI.ClientProvider, expectedPeers int
 }
 
 // EnsureChannelCreatedAndPeersJoined creates a channel, joins all peers in the given orgs to the channel and updates the anchor peers of each org.
+// t may be nil outside of tests.
 func EnsureChannelCreatedAndPeersJoined(t *testing.T, sdk *fabsdk.FabricSDK, channelID string, channelTxFile string, orgsContext []*OrgContext) error {
 	joined, err := IsJoinedChannel(channelID, orgsContext[0].ResMgmt, orgsContext[0].Peers[0])
 	if err != nil {
@@ -396,10 +468,17 @@ func EnsureChannelCreatedAndPeersJoined(t *testing.T, sdk *fabsdk.FabricSDK, cha
 // In Fabric 1.0 there is a bug that panics the orderer if more than one config update is added to the same block.
 // This function may be invoked after each config update as a workaround.
 func WaitForOrdererConfigUpdate(t *testing.T, client *resmgmt.Client, channelID string, genesis bool, lastConfigBlock uint64) uint64 {
+	blockNum, err := waitForConfigBlock(client, channelID, ordererEndpoint, genesis, lastConfigBlock)
+	require.NoError(t, err)
+	return blockNum
+}
+
+// waitForConfigBlock is like WaitForOrdererConfigUpdate but returns an error instead of failing a test
+func waitForConfigBlock(client *resmgmt.Client, channelID, orderer string, genesis bool, lastConfigBlock uint64) (uint64, error) {
 
 	blockNum, err := retry.NewInvoker(retry.New(retry.TestRetryOpts)).Invoke(
 		func() (interface{}, error) {
-			chConfig, err := client.QueryConfigFromOrderer(channelID, resmgmt.WithOrdererEndpoint("orderer.example.com"))
+			chConfig, err := client.QueryConfigFromOrderer(channelID, resmgmt.WithOrdererEndpoint(orderer))
 			if err != nil {
 				return nil, status.New(status.TestStatus, status.GenericTransient.ToInt32(), err.Error(), nil)
 			}
@@ -411,9 +490,11 @@ func WaitForOrdererConfigUpdate(t *testing.T, client *resmgmt.Client, channelID
 			return &currentBlock, nil
 		},
 	)
+	if err != nil {
+		return 0, errors.WithMessage(err, "waiting for config block failed")
+	}
 
-	require.NoError(t, err)
-	return *blockNum.(*uint64)
+	return *blockNum.(*uint64), nil
 }
 
 func queryInstalledCC(resMgmt *resmgmt.Client, ccName, ccVersion string, peers []fabAPI.Peer) (bool, error) {
@@ -492,45 +573,168 @@ func ResetKeys(t *testing.T, ctx contextAPI.ChannelProvider, chaincodeID, value
 //###################################################################################################################
 
 
-//ResetKeys resets given set of keys in  cc to given value
-func SetKeyData(ctx contextAPI.ChannelProvider, chaincodeID, value string, key string) fabAPI.TransactionID{
+// RetryPolicy controls how the ledger helpers retry.
+// Opts is passed to the SDK for every Execute and Query call, NotFound controls how long
+// a query keeps asking for a key that has not reached the queried peer yet.
+type RetryPolicy struct {
+	Opts     retry.Opts
+	NotFound retry.Opts
+}
+
+// DefaultRetryPolicy is the policy used by the helpers that do not take a context
+var DefaultRetryPolicy = RetryPolicy{
+	Opts: retry.DefaultChannelOpts,
+	NotFound: retry.Opts{
+		Attempts:       9,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+		BackoffFactor:  1,
+	},
+}
+
+//SetKeyData sets the given key value in cc and returns the committed transaction ID.
+//On failure a *LedgerError is returned.
+func SetKeyData(ctx contextAPI.ChannelProvider, chaincodeID, value string, key string) (fabAPI.TransactionID, error) {
 	chClient, err := channel.New(ctx)
-	if err != nil {print(err)}
+	if err != nil {
+		return "", newLedgerError("set", key, errors.WithMessage(err, "failed to create new channel client"))
+	}
 
+	return SetKeyDataWithContext(context.Background(), chClient, chaincodeID, value, key, DefaultRetryPolicy)
+}
+
+//SetKeyDataWithContext sets the given key value in cc. The call is abandoned when reqCtx is done.
+//On failure a *LedgerError is returned.
+func SetKeyDataWithContext(reqCtx context.Context, chClient *channel.Client, chaincodeID, value string, key string, policy RetryPolicy) (fabAPI.TransactionID, error) {
 	// Synchronous transaction
-	respone, e := chClient.Execute(
+	response, err := chClient.Execute(
 		channel.Request{
 			ChaincodeID: chaincodeID,
 			Fcn:         "invoke",
 			Args:        ExampleCCTxSetArgs(key, value),
 		},
-		channel.WithRetry(retry.DefaultChannelOpts))
-
-	if e != nil {print(e)}
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "set", key, err)
+	}
 
-	return respone.TransactionID
+	return response.TransactionID, nil
 }
 
+//GetValueFromKey queries the value of the given key in cc.
+//A key that is not found yet is retried since it may not have reached the queried peer.
+//On failure a *LedgerError is returned, with Kind ErrNotFound if the key does not exist.
+func GetValueFromKey(chClient *channel.Client, ccID, key string) (string, error) {
+	return GetValueFromKeyWithContext(context.Background(), chClient, ccID, key, DefaultRetryPolicy)
+}
 
-func GetValueFromKey(chClient *channel.Client,ccID, key string) string{
-
-	const (
-		maxRetries = 10
-		retrySleep = 500 * time.Millisecond
-	)
-
-	for r := 0; r < maxRetries; r++ {
+//GetValueFromKeyWithContext queries the value of the given key in cc, retrying a key that is
+//not found according to policy.NotFound. The call is abandoned when reqCtx is done.
+//On failure a *LedgerError is returned, with Kind ErrNotFound if the key does not exist.
+func GetValueFromKeyWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, policy RetryPolicy) (string, error) {
+	for attempt := 0; ; attempt++ {
 		response, err := chClient.Query(channel.Request{ChaincodeID: ccID, Fcn: "invoke", Args: ExampleCCQueryArgs(key)},
-			channel.WithRetry(retry.DefaultChannelOpts))
+			channel.WithParentContext(reqCtx),
+			channel.WithRetry(policy.Opts))
 		if err == nil {
-			actual := string(response.Payload)
-			if actual != "" {
-				return actual
-			}
+			return string(response.Payload), nil
 		}
 
-		time.Sleep(retrySleep)
+		ledgerErr := newLedgerErrorWithContext(reqCtx, "get", key, err)
+		if ledgerErr.Kind != ErrNotFound || attempt >= policy.NotFound.Attempts {
+			return "", ledgerErr
+		}
+
+		select {
+		case <-time.After(backoffPeriod(policy.NotFound, attempt)):
+		case <-reqCtx.Done():
+			return "", newLedgerErrorWithContext(reqCtx, "get", key, reqCtx.Err())
+		}
+	}
+}
+
+//MoveKeyData moves the given amount from one key to another in cc.
+//On failure a *LedgerError is returned.
+func MoveKeyData(chClient *channel.Client, ccID, from, to, amount string) (fabAPI.TransactionID, error) {
+	return MoveKeyDataWithContext(context.Background(), chClient, ccID, from, to, amount, DefaultRetryPolicy)
+}
+
+//MoveKeyDataWithContext moves the given amount from one key to another in cc.
+//The call is abandoned when reqCtx is done. On failure a *LedgerError is returned.
+func MoveKeyDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID, from, to, amount string, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCTxArgs(from, to, amount),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "move", from, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+//TransferKeyData moves funds among several keys of cc atomically.
+//On failure a *LedgerError is returned and none of the legs is applied.
+func TransferKeyData(chClient *channel.Client, ccID string, legs []TransferLeg) (fabAPI.TransactionID, error) {
+	return TransferKeyDataWithContext(context.Background(), chClient, ccID, legs, DefaultRetryPolicy)
+}
+
+//TransferKeyDataWithContext moves funds among several keys of cc atomically.
+//The call is abandoned when reqCtx is done. On failure a *LedgerError is returned.
+func TransferKeyDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, legs []TransferLeg, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	if len(legs) == 0 {
+		return "", errors.New("at least one transfer leg is required")
+	}
+
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCTransferArgs(legs),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "transfer", legs[0].From, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+//DeleteKeyData deletes the given key from cc.
+//On failure a *LedgerError is returned.
+func DeleteKeyData(chClient *channel.Client, ccID, key string) (fabAPI.TransactionID, error) {
+	return DeleteKeyDataWithContext(context.Background(), chClient, ccID, key, DefaultRetryPolicy)
+}
+
+//DeleteKeyDataWithContext deletes the given key from cc.
+//The call is abandoned when reqCtx is done. On failure a *LedgerError is returned.
+func DeleteKeyDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCDeleteArgs(key),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "delete", key, err)
 	}
 
-	return ""
-}
\ No newline at end of file
+	return response.TransactionID, nil
+}
+
+// backoffPeriod returns the wait before the given retry attempt, computed like the SDK retry handler
+func backoffPeriod(opts retry.Opts, attempt int) time.Duration {
+	backoff := float64(opts.InitialBackoff) * math.Pow(opts.BackoffFactor, float64(attempt))
+	if opts.MaxBackoff > 0 && backoff > float64(opts.MaxBackoff) {
+		return opts.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
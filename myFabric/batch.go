@@ -0,0 +1,169 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// Defaults for the BatchWriter
+const (
+	DefaultBatchInFlight    = 50
+	DefaultBatchMVCCRetries = 3
+)
+
+// KeyValue is a key value pair written by the BatchWriter
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// BatchResult is the outcome of writing a single key
+type BatchResult struct {
+	Key      string
+	TxID     fabAPI.TransactionID
+	Attempts int
+	Err      error
+}
+
+// BatchSummary sums up a BatchWriter run
+type BatchSummary struct {
+	Total       int
+	Succeeded   int
+	Failed      int
+	Retries     int
+	Elapsed     time.Duration
+	TxPerSecond float64
+}
+
+// BatchWriter sets many keys of example CC while keeping several transactions in flight,
+// so that the writes share blocks instead of each waiting for its own block to be cut.
+type BatchWriter struct {
+	ChaincodeID string
+	InFlight    int
+	MVCCRetries int
+	RetryPolicy RetryPolicy
+	submitter   *AsyncSubmitter
+}
+
+// NewBatchWriter creates a BatchWriter for cc using the given submitter
+func NewBatchWriter(submitter *AsyncSubmitter, chaincodeID string) *BatchWriter {
+	return &BatchWriter{
+		ChaincodeID: chaincodeID,
+		InFlight:    DefaultBatchInFlight,
+		MVCCRetries: DefaultBatchMVCCRetries,
+		RetryPolicy: DefaultRetryPolicy,
+		submitter:   submitter,
+	}
+}
+
+// Write sets all pairs received until the pairs channel is closed or ctx is done.
+// The result of every key is sent to results, if not nil, which must be drained by the caller.
+// A summary is returned once all transactions in flight have completed.
+func (w *BatchWriter) Write(ctx context.Context, pairs <-chan KeyValue, results chan<- BatchResult) BatchSummary {
+	inFlight := w.InFlight
+	if inFlight <= 0 {
+		inFlight = 1
+	}
+
+	var (
+		summary BatchSummary
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+	)
+	slots := make(chan struct{}, inFlight)
+	start := time.Now()
+
+	record := func(res BatchResult) {
+		mutex.Lock()
+		summary.Total++
+		summary.Retries += res.Attempts - 1
+		if res.Err != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		mutex.Unlock()
+
+		if results != nil {
+			results <- res
+		}
+	}
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case kv, ok := <-pairs:
+			if !ok {
+				break loop
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				record(BatchResult{Key: kv.Key, Err: newLedgerErrorWithContext(ctx, "set", kv.Key, ctx.Err())})
+				break loop
+			}
+
+			wg.Add(1)
+			go func(kv KeyValue) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				record(w.writeOne(ctx, kv))
+			}(kv)
+		}
+	}
+	wg.Wait()
+
+	summary.Elapsed = time.Since(start)
+	if summary.Elapsed > 0 {
+		summary.TxPerSecond = float64(summary.Succeeded) / summary.Elapsed.Seconds()
+	}
+	return summary
+}
+
+// writeOne sets a single key, resubmitting it when it hits an MVCC conflict
+func (w *BatchWriter) writeOne(ctx context.Context, kv KeyValue) BatchResult {
+	res := BatchResult{Key: kv.Key}
+
+	for {
+		res.Attempts++
+		txID, notifier, err := SetKeyDataAsync(ctx, w.submitter, w.ChaincodeID, kv.Value, kv.Key, w.RetryPolicy)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		res.TxID = txID
+
+		var st TxCommitStatus
+		select {
+		case st = <-notifier:
+		case <-ctx.Done():
+			res.Err = newLedgerErrorWithContext(ctx, "set", kv.Key, errors.Errorf("transaction [%s] abandoned", txID))
+			return res
+		}
+
+		if st.Err == nil {
+			res.Err = nil
+			return res
+		}
+
+		ledgerErr := newLedgerError("set", kv.Key, st.Err)
+		res.Err = ledgerErr
+		if ledgerErr.Kind != ErrMVCCConflict || res.Attempts > w.MVCCRetries {
+			return res
+		}
+		logger.Debugf("MVCC conflict setting key [%s] in transaction [%s], resubmitting", kv.Key, txID)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+func TestReadKeyValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []KeyValue
+		err   bool
+	}{
+		{"empty", "", nil, false},
+		{"pairs", "a,1\nb,2\n", []KeyValue{{"a", "1"}, {"b", "2"}}, false},
+		{"no final newline", "a,1\nb,2", []KeyValue{{"a", "1"}, {"b", "2"}}, false},
+		{"quoted", "\"a,b\",\"x \"\"y\"\"\"\nc,\n", []KeyValue{{"a,b", `x "y"`}, {"c", ""}}, false},
+		{"CRLF", "a,1\r\nb,2\r\n", []KeyValue{{"a", "1"}, {"b", "2"}}, false},
+		{"missing value", "a,1\nb\n", []KeyValue{{"a", "1"}}, true},
+		{"extra field", "a,1,x\n", nil, true},
+		{"bare quote", "a,1\"\n", nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pairs := make(chan KeyValue)
+			errCh := make(chan error, 1)
+			go func() {
+				defer close(pairs)
+				errCh <- readKeyValues(context.Background(), strings.NewReader(tc.input), pairs)
+			}()
+
+			var got []KeyValue
+			for kv := range pairs {
+				got = append(got, kv)
+			}
+			if err := <-errCh; (err != nil) != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReadKeyValuesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Nobody receives the pairs, reading must still stop
+	if err := readKeyValues(ctx, strings.NewReader("a,1\nb,2\n"), make(chan KeyValue)); err != nil {
+		t.Fatalf("expected a cancelled read to stop without error, got %s", err)
+	}
+}
+
+func TestBatchWriter(t *testing.T) {
+	cc := newFakeExampleCC(map[string]string{})
+	cc.fail["bad"] = ccErrorResponse(CCErrInvalidValue, "bad")
+
+	// Number of MVCC conflicts each key hits before it is committed
+	var mutex sync.Mutex
+	conflicts := map[string]int{"conflict": 2, "hot": 100}
+	peer := newMockPeer(cc.handle)
+	commit := func(txID string) pb.TxValidationCode {
+		mutex.Lock()
+		defer mutex.Unlock()
+		key := peer.argsOfTx(txID)[2]
+		if conflicts[key] > 0 {
+			conflicts[key]--
+			return pb.TxValidationCode_MVCC_READ_CONFLICT
+		}
+		return pb.TxValidationCode_VALID
+	}
+	submitter, err := NewAsyncSubmitter(newMockChannelProviderWithCommit(t, commit, peer), 0)
+	if err != nil {
+		t.Fatalf("creating submitter failed: %s", err)
+	}
+	defer submitter.Close()
+
+	w := NewBatchWriter(submitter, "examplecc")
+	w.InFlight = 2
+	w.MVCCRetries = 3
+	w.RetryPolicy = GatewayRetryPolicy
+
+	pairs := make(chan KeyValue)
+	go func() {
+		defer close(pairs)
+		if err := readKeyValues(context.Background(), strings.NewReader("a,1\nb,2\nconflict,3\nhot,4\nbad,5\n"), pairs); err != nil {
+			t.Errorf("reading input failed: %s", err)
+		}
+	}()
+	results := make(chan BatchResult, 5)
+	summary := w.Write(context.Background(), pairs, results)
+	close(results)
+
+	byKey := make(map[string]BatchResult)
+	for res := range results {
+		byKey[res.Key] = res
+	}
+	tests := []struct {
+		key      string
+		attempts int
+		kind     LedgerErrorKind
+	}{
+		{"a", 1, ErrUnknown},
+		{"b", 1, ErrUnknown},
+		{"conflict", 3, ErrUnknown},
+		{"hot", 4, ErrMVCCConflict},
+		{"bad", 1, ErrChaincode},
+	}
+	for _, tc := range tests {
+		res, ok := byKey[tc.key]
+		if !ok {
+			t.Fatalf("no result for key [%s]", tc.key)
+		}
+		if res.Attempts != tc.attempts {
+			t.Errorf("expected %d attempts for key [%s], got %d", tc.attempts, tc.key, res.Attempts)
+		}
+		if tc.kind == ErrUnknown {
+			if res.Err != nil || res.TxID == "" {
+				t.Errorf("expected key [%s] to be committed, got %+v", tc.key, res)
+			}
+		} else if !IsLedgerErrorKind(res.Err, tc.kind) {
+			t.Errorf("expected key [%s] to fail with %s, got %v", tc.key, tc.kind, res.Err)
+		}
+	}
+
+	if summary.Total != 5 || summary.Succeeded != 3 || summary.Failed != 2 || summary.Retries != 5 {
+		t.Fatalf("expected 5 total, 3 succeeded, 2 failed and 5 retries, got %+v", summary)
+	}
+	if summary.Elapsed <= 0 || summary.TxPerSecond <= 0 {
+		t.Fatalf("expected the elapsed time and throughput, got %+v", summary)
+	}
+	if v := cc.get("conflict"); v != "3" {
+		t.Fatalf("expected the resubmitted value to be written, got [%s]", v)
+	}
+}
+
+func TestBatchWriterCanceled(t *testing.T) {
+	submitter, err := NewAsyncSubmitter(newMockChannelProvider(t, newMockPeer(newFakeExampleCC(map[string]string{}).handle)), 0)
+	if err != nil {
+		t.Fatalf("creating submitter failed: %s", err)
+	}
+	defer submitter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pairs := make(chan KeyValue, 1)
+	pairs <- KeyValue{"a", "1"}
+	summary := NewBatchWriter(submitter, "examplecc").Write(ctx, pairs, nil)
+	if summary.Succeeded != 0 || summary.Total != summary.Failed {
+		t.Fatalf("expected nothing to be written after cancelling, got %+v", summary)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// allowObjectType prefixes the composite keys (channel, chaincode) of the chaincodes
+// that invokecc may call
+const allowObjectType = "invokeallow"
+
+// allowedCC is a chaincode on the invokecc allow-list
+type allowedCC struct {
+	ChannelID   string `json:"channelId"`
+	ChaincodeID string `json:"chaincodeId"`
+}
+
+func allowKey(stub shim.ChaincodeStubInterface, channelID, ccID string) (string, *ccError) {
+	compositeKey, err := stub.CreateCompositeKey(allowObjectType, []string{channelID, ccID})
+	if err != nil {
+		return "", newCCError(errCodeInvalidArgs, ccID, "Failed to create allow-list key: %s", err)
+	}
+	return compositeKey, nil
+}
+
+// isInvokeAllowed returns true if the chaincode on the channel is on the allow-list
+func isInvokeAllowed(stub shim.ChaincodeStubInterface, channelID, ccID string) (bool, *ccError) {
+	compositeKey, ccErr := allowKey(stub, channelID, ccID)
+	if ccErr != nil {
+		return false, ccErr
+	}
+	value, err := stub.GetState(compositeKey)
+	if err != nil {
+		return false, newCCError(errCodeStateFailure, ccID, "Failed to get allow-list: %s", err)
+	}
+	return value != nil, nil
+}
+
+// setInvokeAllowed adds a chaincode to the allow-list of invokecc or removes it, only admins may do so
+// arg0: chaincode ID, arg1: optional channel, the current channel if empty
+func (t *SimpleChaincode) setInvokeAllowed(stub shim.ChaincodeStubInterface, args []string, allow bool) pb.Response {
+	if len(args) < 1 || len(args) > 2 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a chaincode ID and optionally a channel")
+	}
+
+	ccID := args[0]
+	if ccID == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty chaincode ID")
+	}
+	channelID := stub.GetChannelID()
+	if len(args) == 2 && args[1] != "" {
+		channelID = args[1]
+	}
+
+	if !isAdmin(stub) {
+		return errorResponse(errCodeForbidden, ccID, "Only admins may change the allow-list")
+	}
+
+	compositeKey, ccErr := allowKey(stub, channelID, ccID)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if !allow {
+		if err := stub.DelState(compositeKey); err != nil {
+			return errorResponse(errCodeStateFailure, ccID, "Failed to delete allow-list entry: %s", err)
+		}
+		return shim.Success(nil)
+	}
+
+	entryJSON, err := json.Marshal(&allowedCC{ChannelID: channelID, ChaincodeID: ccID})
+	if err != nil {
+		return errorResponse(errCodeStateFailure, ccID, "Failed to marshal allow-list entry: %s", err)
+	}
+	if err := stub.PutState(compositeKey, entryJSON); err != nil {
+		return errorResponse(errCodeStateFailure, ccID, "Failed to put allow-list entry: %s", err)
+	}
+	return shim.Success(nil)
+}
+
+// queryInvokeAllowed returns the allow-list of invokecc as JSON
+func (t *SimpleChaincode) queryInvokeAllowed(stub shim.ChaincodeStubInterface) pb.Response {
+	iter, err := stub.GetStateByPartialCompositeKey(allowObjectType, []string{})
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to get allow-list: %s", err)
+	}
+	defer iter.Close()
+
+	entries := []allowedCC{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return errorResponse(errCodeStateFailure, "", "Failed to iterate allow-list: %s", err)
+		}
+		entry := allowedCC{}
+		if err := json.Unmarshal(kv.Value, &entry); err != nil {
+			return errorResponse(errCodeStateFailure, "", "Failed to unmarshal allow-list entry: %s", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to marshal allow-list: %s", err)
+	}
+	return shim.Success(entriesJSON)
+}
@@ -0,0 +1,291 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// docObjectType prefixes the composite keys (type, owner, id) holding documents, so that
+// all documents of a type, or of a type and owner, can be queried by partial key
+const docObjectType = "doc"
+
+// document is a JSON record stored under a composite key. Its owner is the common name of
+// the certificate of the client the document belongs to.
+type document struct {
+	DocType string          `json:"docType"`
+	Owner   string          `json:"owner"`
+	ID      string          `json:"id"`
+	Data    json.RawMessage `json:"data"`
+	// Creator is the client that wrote the document, only it and admins may change it
+	Creator *owner `json:"creator"`
+}
+
+// docPage is a page of documents returned by the document queries
+type docPage struct {
+	Records  []*document `json:"records"`
+	Count    int32       `json:"count"`
+	Bookmark string      `json:"bookmark"`
+}
+
+func docKey(stub shim.ChaincodeStubInterface, docType, docOwner, id string) (string, *ccError) {
+	if docType == "" || docOwner == "" || id == "" {
+		return "", newCCError(errCodeInvalidArgs, id, "Expecting a non empty type, owner and id")
+	}
+	compositeKey, err := stub.CreateCompositeKey(docObjectType, []string{docType, docOwner, id})
+	if err != nil {
+		return "", newCCError(errCodeInvalidArgs, id, "Failed to create document key: %s", err)
+	}
+	return compositeKey, nil
+}
+
+// getDoc returns the document stored under compositeKey, or nil if there is none
+func getDoc(stub shim.ChaincodeStubInterface, compositeKey, id string) (*document, *ccError) {
+	docBytes, err := stub.GetState(compositeKey)
+	if err != nil {
+		return nil, newCCError(errCodeStateFailure, id, "Failed to get document: %s", err)
+	}
+	if docBytes == nil {
+		return nil, nil
+	}
+
+	doc := &document{}
+	if err := json.Unmarshal(docBytes, doc); err != nil {
+		return nil, newCCError(errCodeStateFailure, id, "Failed to unmarshal document: %s", err)
+	}
+	return doc, nil
+}
+
+// checkDocCreator fails unless the caller created doc or holds the admin attribute
+func checkDocCreator(stub shim.ChaincodeStubInterface, doc *document) *ccError {
+	if isAdmin(stub) {
+		return nil
+	}
+	caller, ccErr := callerOwner(stub)
+	if ccErr != nil {
+		return ccErr
+	}
+	if doc.Creator == nil || *caller != *doc.Creator {
+		return newCCError(errCodeForbidden, doc.ID, "Caller is not the creator of the document")
+	}
+	return nil
+}
+
+// checkDocOwner fails unless docOwner is the common name of the certificate of the caller
+// or the caller holds the admin attribute, so that clients only write their own documents
+func checkDocOwner(stub shim.ChaincodeStubInterface, docOwner, id string) *ccError {
+	if isAdmin(stub) {
+		return nil
+	}
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil || cert == nil {
+		return newCCError(errCodeForbidden, id, "Failed to get the certificate of the caller: %v", err)
+	}
+	if cert.Subject.CommonName != docOwner {
+		return newCCError(errCodeForbidden, id, "Only admins may write documents of owners other than the caller")
+	}
+	return nil
+}
+
+// putDoc creates or replaces a document. The owner must be the caller unless it is an admin.
+// arg1: type, arg2: owner, arg3: id, arg4: JSON data
+func (t *SimpleChaincode) putDoc(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 5 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a type, an owner, an id and JSON data")
+	}
+
+	id := args[3]
+	compositeKey, ccErr := docKey(stub, args[1], args[2], id)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	data := []byte(args[4])
+	if !json.Valid(data) {
+		return errorResponse(errCodeInvalidValue, id, "Expecting JSON data")
+	}
+	if ccErr := checkDocOwner(stub, args[2], id); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	existing, ccErr := getDoc(stub, compositeKey, id)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if existing != nil {
+		if ccErr := checkDocCreator(stub, existing); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	}
+	caller, ccErr := callerOwner(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	doc := &document{DocType: args[1], Owner: args[2], ID: id, Data: data, Creator: caller}
+	if existing != nil {
+		// Admins update documents on behalf of their creator
+		doc.Creator = existing.Creator
+	}
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, id, "Failed to marshal document: %s", err)
+	}
+	if err := stub.PutState(compositeKey, docBytes); err != nil {
+		return errorResponse(errCodeStateFailure, id, "Failed to put document: %s", err)
+	}
+	return shim.Success(nil)
+}
+
+// getDocument returns a document as JSON
+// arg1: type, arg2: owner, arg3: id
+func (t *SimpleChaincode) getDocument(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a type, an owner and an id")
+	}
+
+	id := args[3]
+	compositeKey, ccErr := docKey(stub, args[1], args[2], id)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	docBytes, err := stub.GetState(compositeKey)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, id, "Failed to get document: %s", err)
+	}
+	if docBytes == nil {
+		return errorResponse(errCodeNotFound, id, "Document not found")
+	}
+	return shim.Success(docBytes)
+}
+
+// delDoc deletes a document, it is allowed for its creator and admins
+// arg1: type, arg2: owner, arg3: id
+func (t *SimpleChaincode) delDoc(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a type, an owner and an id")
+	}
+
+	id := args[3]
+	compositeKey, ccErr := docKey(stub, args[1], args[2], id)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	doc, ccErr := getDoc(stub, compositeKey, id)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if doc == nil {
+		return errorResponse(errCodeNotFound, id, "Document not found")
+	}
+	if ccErr := checkDocCreator(stub, doc); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if err := stub.DelState(compositeKey); err != nil {
+		return errorResponse(errCodeStateFailure, id, "Failed to delete document: %s", err)
+	}
+	return shim.Success(nil)
+}
+
+// parsePaging parses a page size and an optional bookmark
+func parsePaging(args []string) (int32, string, *ccError) {
+	if len(args) == 0 {
+		return 0, "", newCCError(errCodeInvalidArgs, "", "Expecting a page size")
+	}
+	pageSize, err := strconv.ParseInt(args[0], 10, 32)
+	if err != nil || pageSize <= 0 {
+		return 0, "", newCCError(errCodeInvalidValue, "", "Invalid page size, expecting a positive integer value")
+	}
+	bookmark := ""
+	if len(args) >= 2 {
+		bookmark = args[1]
+	}
+	return int32(pageSize), bookmark, nil
+}
+
+// docsByKey returns a page of the documents of a type, and of an owner if given, as JSON.
+// Pagination is only supported in queries, not in transactions.
+// arg1: type, arg2: owner or empty, arg3: page size, arg4: optional bookmark
+func (t *SimpleChaincode) docsByKey(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a type, an owner and a page size")
+	}
+	if args[1] == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty type")
+	}
+
+	attributes := []string{args[1]}
+	if args[2] != "" {
+		attributes = append(attributes, args[2])
+	}
+	pageSize, bookmark, ccErr := parsePaging(args[3:])
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(docObjectType, attributes, pageSize, bookmark)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to get documents by partial key: %s", err)
+	}
+	return docPageResponse(iter, metadata)
+}
+
+// queryDocs returns a page of the documents matching a CouchDB selector as JSON, it requires
+// CouchDB as state database. Documents are matched on their fields, e.g.
+// {"selector":{"docType":"account","owner":"alice"}}
+// arg1: query, arg2: page size, arg3: optional bookmark
+func (t *SimpleChaincode) queryDocs(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 3 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a query and a page size")
+	}
+	if !json.Valid([]byte(args[1])) {
+		return errorResponse(errCodeInvalidValue, "", "Expecting a JSON query")
+	}
+	pageSize, bookmark, ccErr := parsePaging(args[2:])
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	iter, metadata, err := stub.GetQueryResultWithPagination(args[1], pageSize, bookmark)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to execute query: %s", err)
+	}
+	return docPageResponse(iter, metadata)
+}
+
+// docPageResponse reads the documents of a query into a page, other values matched by
+// a rich query are skipped
+func docPageResponse(iter shim.StateQueryIteratorInterface, metadata *pb.QueryResponseMetadata) pb.Response {
+	defer iter.Close()
+
+	page := docPage{Records: []*document{}}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return errorResponse(errCodeStateFailure, "", "Failed to iterate documents: %s", err)
+		}
+		doc := &document{}
+		if err := json.Unmarshal(kv.Value, doc); err != nil || doc.DocType == "" {
+			continue
+		}
+		page.Records = append(page.Records, doc)
+	}
+	if metadata != nil {
+		page.Count = metadata.FetchedRecordsCount
+		page.Bookmark = metadata.Bookmark
+	}
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to marshal page: %s", err)
+	}
+	return shim.Success(pageJSON)
+}
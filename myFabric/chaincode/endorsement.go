@@ -0,0 +1,149 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// keyEndorsement is the key-level endorsement policy of a key returned by getep
+type keyEndorsement struct {
+	Key  string   `json:"key"`
+	Orgs []string `json:"orgs"`
+}
+
+// endorsementRoles maps the role argument of setep to the principal role of the policy
+var endorsementRoles = map[string]statebased.RoleType{
+	"member": statebased.RoleTypeMember,
+	"peer":   statebased.RoleTypePeer,
+}
+
+// setEndorsement sets the key-level endorsement policy of a key. Changes of the key must
+// then be endorsed by every given org instead of satisfying the chaincode policy. Without
+// orgs the policy is removed and the chaincode policy applies again. Only the owner of the
+// key or an admin may do so, and the change itself must satisfy the current policy of the key.
+// The policy also applies to the owner record of the key, so that chown needs the same endorsements.
+// arg1: key, arg2: comma separated MSP IDs, arg3: role of the endorsers, member (default) or peer
+func (t *SimpleChaincode) setEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key, MSP IDs and an optional role")
+	}
+
+	key := args[1]
+	if key == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
+	var orgs []string
+	for _, org := range strings.Split(args[2], ",") {
+		if org = strings.TrimSpace(org); org != "" {
+			orgs = append(orgs, org)
+		}
+	}
+	role := statebased.RoleTypeMember
+	if len(args) == 4 && args[3] != "" {
+		var ok bool
+		if role, ok = endorsementRoles[args[3]]; !ok {
+			return errorResponse(errCodeInvalidValue, key, "Invalid role [%s], expecting member or peer", args[3])
+		}
+	}
+
+	valbytes, err := stub.GetState(key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get state: %s", err)
+	}
+	if valbytes == nil {
+		return errorResponse(errCodeNotFound, key, "Entity not found")
+	}
+	if ccErr := checkOwnership(stub, key); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	o, ccErr := getOwner(stub, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	if len(orgs) == 0 {
+		if err := stub.SetStateValidationParameter(key, nil); err != nil {
+			return errorResponse(errCodeStateFailure, key, "Failed to remove endorsement policy: %s", err)
+		}
+		if o != nil {
+			if ccErr := setOwnerEndorsement(stub, key, nil); ccErr != nil {
+				return shim.Error(ccErr.Error())
+			}
+		}
+		return shim.Success(nil)
+	}
+
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to create endorsement policy: %s", err)
+	}
+	if err := ep.AddOrgs(role, orgs...); err != nil {
+		return errorResponse(errCodeInvalidValue, key, "Invalid endorsement policy: %s", err)
+	}
+	policy, err := ep.Policy()
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to marshal endorsement policy: %s", err)
+	}
+	if err := stub.SetStateValidationParameter(key, policy); err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to set endorsement policy: %s", err)
+	}
+	if o != nil {
+		if ccErr := setOwnerEndorsement(stub, key, policy); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	}
+	return shim.Success(nil)
+}
+
+// getEndorsement returns the orgs of the key-level endorsement policy of a key as JSON,
+// none if the chaincode policy applies
+// arg1: key
+func (t *SimpleChaincode) getEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key")
+	}
+
+	key := args[1]
+	if key == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
+
+	valbytes, err := stub.GetState(key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get state: %s", err)
+	}
+	if valbytes == nil {
+		return errorResponse(errCodeNotFound, key, "Entity not found")
+	}
+
+	policy, err := stub.GetStateValidationParameter(key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get endorsement policy: %s", err)
+	}
+	endorsement := keyEndorsement{Key: key, Orgs: []string{}}
+	if len(policy) > 0 {
+		ep, err := statebased.NewStateEP(policy)
+		if err != nil {
+			return errorResponse(errCodeStateFailure, key, "Failed to unmarshal endorsement policy: %s", err)
+		}
+		endorsement.Orgs = append(endorsement.Orgs, ep.ListOrgs()...)
+		sort.Strings(endorsement.Orgs)
+	}
+
+	endorsementJSON, err := json.Marshal(endorsement)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to marshal endorsement policy: %s", err)
+	}
+	return shim.Success(endorsementJSON)
+}
@@ -23,9 +23,9 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
@@ -33,6 +33,48 @@ import (
 
 var logger = shim.NewLogger("examplecc")
 
+// Error codes of the JSON errors returned by example CC
+const (
+	errCodeInvalidArgs       = "INVALID_ARGUMENTS"
+	errCodeInvalidValue      = "INVALID_VALUE"
+	errCodeNotFound          = "NOT_FOUND"
+	errCodeInsufficient      = "INSUFFICIENT_FUNDS"
+	errCodeForbidden         = "FORBIDDEN"
+	errCodeStateFailure      = "STATE_FAILURE"
+	errCodeEventFailure      = "EVENT_FAILURE"
+	errCodeInvokeFailure     = "INVOKE_FAILURE"
+	errCodeUnknownFunction   = "UNKNOWN_FUNCTION"
+	errCodeSupplyCapExceeded = "SUPPLY_CAP_EXCEEDED"
+	errCodeSupplyInvariant   = "SUPPLY_INVARIANT_VIOLATED"
+	errCodeTokenModeDisabled = "TOKEN_MODE_DISABLED"
+)
+
+// ccError is returned as JSON in the message of a failed response, so that clients
+// can tell the failures apart without matching messages
+type ccError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Key     string `json:"key,omitempty"`
+}
+
+func newCCError(code, key, format string, args ...interface{}) *ccError {
+	return &ccError{Code: code, Message: fmt.Sprintf(format, args...), Key: key}
+}
+
+// Error returns the JSON encoding of the error
+func (e *ccError) Error() string {
+	errJSON, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(errJSON)
+}
+
+// errorResponse returns a failed response carrying a JSON error
+func errorResponse(code, key, format string, args ...interface{}) pb.Response {
+	return shim.Error(newCCError(code, key, format, args...).Error())
+}
+
 // SimpleChaincode example simple Chaincode implementation
 type SimpleChaincode struct {
 }
@@ -41,12 +83,24 @@ type SimpleChaincode struct {
 func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	txID := stub.GetTxID()
 	logger.Debugf("[txID %s] ########### example_cc Init ###########\n", txID)
-	_, args := stub.GetFunctionAndParameters()
+	function, args := stub.GetFunctionAndParameters()
+
+	if function == "inittoken" {
+		if ccErr := t.initToken(stub, txID, args); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+		return shim.Success(nil)
+	}
 
-	err := t.reset(stub, txID, args)
+	// Instantiate and upgrade are governed by the lifecycle policy, so the keys
+	// are reset regardless of their owners and handed to the instantiating identity
+	err := t.reset(stub, txID, args, false)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+	if ccErr := clearToken(stub); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
 
 	if transientMap, err := stub.GetTransient(); err == nil {
 		if transientData, ok := transientMap["result"]; ok {
@@ -59,52 +113,90 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 }
 
 func (t *SimpleChaincode) resetCC(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Deletes an entity from its state
-	if err := t.reset(stub, stub.GetTxID(), args); err != nil {
+	// Resetting holdings would change the total supply
+	cfg, ccErr := getTokenConfig(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if cfg != nil {
+		return errorResponse(errCodeForbidden, "", "Reset is not allowed in token mode")
+	}
+
+	if err := t.reset(stub, stub.GetTxID(), args, true); err != nil {
 		return shim.Error(err.Error())
 	}
 	return shim.Success(nil)
 }
 
-func (t *SimpleChaincode) reset(stub shim.ChaincodeStubInterface, txID string, args []string) error {
+// reset sets two keys to the given holdings and records the caller as their owner.
+// If checkOwner is set the caller must be allowed to change existing keys.
+func (t *SimpleChaincode) reset(stub shim.ChaincodeStubInterface, txID string, args []string, checkOwner bool) *ccError {
 	var A, B string    // Entities
 	var Aval, Bval int // Asset holdings
 	var err error
 
 	if len(args) != 4 {
-		return errors.New("Incorrect number of arguments. Expecting 4")
+		return newCCError(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting 4")
 	}
 
 	// Initialize the chaincode
 	A = args[0]
+	if A == "" {
+		return newCCError(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
 	Aval, err = strconv.Atoi(args[1])
 	if err != nil {
-		return errors.New("Expecting integer value for asset holding")
+		return newCCError(errCodeInvalidValue, A, "Expecting integer value for asset holding")
 	}
 	B = args[2]
+	if B == "" {
+		return newCCError(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
 	Bval, err = strconv.Atoi(args[3])
 	if err != nil {
-		return errors.New("Expecting integer value for asset holding")
+		return newCCError(errCodeInvalidValue, B, "Expecting integer value for asset holding")
 	}
 	logger.Debugf("[txID %s] Aval = %d, Bval = %d\n", txID, Aval, Bval)
 
+	if checkOwner {
+		for _, key := range []string{A, B} {
+			valbytes, err := stub.GetState(key)
+			if err != nil {
+				return newCCError(errCodeStateFailure, key, "Failed to get state: %s", err)
+			}
+			if valbytes == nil {
+				continue
+			}
+			if ccErr := checkOwnership(stub, key); ccErr != nil {
+				return ccErr
+			}
+		}
+	}
+
 	// Write the state to the ledger
 	err = stub.PutState(A, []byte(strconv.Itoa(Aval)))
 	if err != nil {
-		return err
+		return newCCError(errCodeStateFailure, A, "Failed to put state: %s", err)
 	}
 
 	err = stub.PutState(B, []byte(strconv.Itoa(Bval)))
 	if err != nil {
-		return err
+		return newCCError(errCodeStateFailure, B, "Failed to put state: %s", err)
 	}
 
-	return nil
+	caller, ccErr := callerOwner(stub)
+	if ccErr != nil {
+		return ccErr
+	}
+	if ccErr := putOwner(stub, A, caller); ccErr != nil {
+		return ccErr
+	}
+	return putOwner(stub, B, caller)
 }
 
 // Query ...
 func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface) pb.Response {
-	return shim.Error("Unknown supported call")
+	return errorResponse(errCodeUnknownFunction, "", "Unknown supported call")
 }
 
 //set sets given key-value in state
@@ -112,12 +204,15 @@ func (t *SimpleChaincode) set(stub shim.ChaincodeStubInterface, args []string) p
 	var err error
 
 	if len(args) < 3 {
-		return shim.Error("Incorrect number of arguments. Expecting a key and a value")
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key and a value")
 	}
 
 	// Initialize the chaincode
 	key := args[1]
 	value := args[2]
+	if key == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
 	eventID := "testEvent"
 	if len(args) >= 4 {
 		eventID = args[3]
@@ -125,17 +220,44 @@ func (t *SimpleChaincode) set(stub shim.ChaincodeStubInterface, args []string) p
 
 	logger.Debugf("Setting value for key[%s]", key)
 
+	// Only the owner may overwrite an existing key, a new key is owned by the caller
+	valbytes, err := stub.GetState(key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get state: %s", err)
+	}
+
+	// In token mode balances only change through move, transfer, mint and burn
+	cfg, ccErr := getTokenConfig(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if cfg != nil {
+		if valbytes != nil || value != "0" {
+			return errorResponse(errCodeForbidden, key, "In token mode set may only create new accounts with value 0")
+		}
+		if ccErr := putTokenAccount(stub, key); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	}
+	if valbytes != nil {
+		if ccErr := checkOwnership(stub, key); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	} else if ccErr := claimOwnership(stub, key); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
 	// Write the state to the ledger
 	err = stub.PutState(key, []byte(value))
 	if err != nil {
-		logger.Errorf("Failed to set value for key[%s] : ", key, err)
-		return shim.Error(err.Error())
+		logger.Errorf("Failed to set value for key[%s] : %s", key, err)
+		return errorResponse(errCodeStateFailure, key, "Failed to put state: %s", err)
 	}
 
 	err = stub.SetEvent(eventID, []byte("Test Payload"))
 	if err != nil {
-		logger.Errorf("Failed to set event for key[%s] : ", key, err)
-		return shim.Error(err.Error())
+		logger.Errorf("Failed to set event for key[%s] : %s", key, err)
+		return errorResponse(errCodeEventFailure, key, "Failed to set event: %s", err)
 	}
 
 	return shim.Success(nil)
@@ -155,12 +277,24 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.resetCC(stub, args)
 	}
 
+	if function == "allowcc" || function == "disallowcc" {
+		return t.setInvokeAllowed(stub, args, function == "allowcc")
+	}
+
+	if function == "allowedcc" {
+		return t.queryInvokeAllowed(stub)
+	}
+
+	if function == "totalsupply" {
+		return t.totalSupply(stub)
+	}
+
 	if function != "invoke" {
-		return shim.Error("Unknown function call")
+		return errorResponse(errCodeUnknownFunction, "", "Unknown function call: %s", function)
 	}
 
 	if len(args) < 2 {
-		return shim.Error("Incorrect number of arguments. Expecting at least 2")
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting at least 2")
 	}
 
 	if args[0] == "delete" {
@@ -178,17 +312,87 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.set(stub, args)
 	}
 
+	if args[0] == "owner" {
+		// queries the owner of an entity
+		return t.queryOwner(stub, args)
+	}
+
+	if args[0] == "chown" {
+		// hands an entity over to another owner
+		return t.changeOwner(stub, args)
+	}
+
+	if args[0] == "transfer" {
+		// moves funds among several entities at once
+		return t.transfer(stub, args)
+	}
+
+	if args[0] == "range" {
+		// lists a page of entity states
+		return t.rangeQuery(stub, args)
+	}
+
+	if args[0] == "history" {
+		// queries every modification of an entity
+		return t.history(stub, args)
+	}
+
+	if args[0] == "putdoc" {
+		// creates or replaces a document
+		return t.putDoc(stub, args)
+	}
+
+	if args[0] == "getdoc" {
+		// queries a document
+		return t.getDocument(stub, args)
+	}
+
+	if args[0] == "deldoc" {
+		// deletes a document
+		return t.delDoc(stub, args)
+	}
+
+	if args[0] == "docs" {
+		// lists a page of the documents of a type and owner
+		return t.docsByKey(stub, args)
+	}
+
+	if args[0] == "querydocs" {
+		// lists a page of the documents matching a rich query
+		return t.queryDocs(stub, args)
+	}
+
+	if args[0] == "setep" {
+		// sets the key-level endorsement policy of an entity
+		return t.setEndorsement(stub, args)
+	}
+
+	if args[0] == "getep" {
+		// queries the key-level endorsement policy of an entity
+		return t.getEndorsement(stub, args)
+	}
+
+	if args[0] == "mint" {
+		// creates tokens in an entity, token mode only
+		return t.mint(stub, args)
+	}
+
+	if args[0] == "burn" {
+		// destroys tokens of an entity, token mode only
+		return t.burn(stub, args)
+	}
+
 	if args[0] == "move" {
 		eventID := "testEvent"
 		if len(args) >= 5 {
 			eventID = args[4]
 		}
 		if err := stub.SetEvent(eventID, []byte("Test Payload")); err != nil {
-			return shim.Error("Unable to set CC event: testEvent. Aborting transaction ...")
+			return errorResponse(errCodeEventFailure, "", "Unable to set CC event: %s. Aborting transaction ...", eventID)
 		}
 		return t.move(stub, args)
 	}
-	return shim.Error("Unknown action, check the first argument, must be one of 'delete', 'query', or 'move'")
+	return errorResponse(errCodeUnknownFunction, "", "Unknown action, check the first argument, must be one of 'delete', 'query', 'history', 'set', 'range', 'move', 'transfer', 'owner', 'chown', 'putdoc', 'getdoc', 'deldoc', 'docs', 'querydocs', 'setep', 'getep', 'mint' or 'burn'")
 }
 
 func (t *SimpleChaincode) move(stub shim.ChaincodeStubInterface, args []string) pb.Response {
@@ -199,50 +403,64 @@ func (t *SimpleChaincode) move(stub shim.ChaincodeStubInterface, args []string)
 	var X int          // Transaction value
 	var err error
 	if len(args) < 4 {
-		return shim.Error("Incorrect number of arguments. Expecting 4, function followed by 2 names and 1 value")
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting 4, function followed by 2 names and 1 value")
 	}
 
 	A = args[1]
 	B = args[2]
+	if A == "" || B == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting non empty keys")
+	}
+	if A == B {
+		return errorResponse(errCodeInvalidArgs, A, "Cannot move to the same key")
+	}
 
 	// Get the state from the ledger
-	// TODO: will be nice to have a GetAllState call to ledger
-	Avalbytes, err := stub.GetState(A)
-	if err != nil {
-		return shim.Error("Failed to get state")
+	Aval, ccErr := getBalance(stub, A)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
 	}
-	if Avalbytes == nil {
-		return shim.Error("Entity not found")
+	if ccErr = checkOwnership(stub, A); ccErr != nil {
+		return shim.Error(ccErr.Error())
 	}
-	Aval, _ = strconv.Atoi(string(Avalbytes))
 
-	Bvalbytes, err := stub.GetState(B)
-	if err != nil {
-		return shim.Error("Failed to get state")
-	}
-	if Bvalbytes == nil {
-		return shim.Error("Entity not found")
+	Bval, ccErr = getBalance(stub, B)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
 	}
-	Bval, _ = strconv.Atoi(string(Bvalbytes))
 
 	// Perform the execution
-	X, err = strconv.Atoi(args[3])
-	if err != nil {
-		return shim.Error("Invalid transaction amount, expecting a integer value")
+	X, ccErr = parseAmount(args[3])
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
 	}
+	if Aval < X {
+		return errorResponse(errCodeInsufficient, A, "Insufficient funds, balance %d is less than %d", Aval, X)
+	}
+	before := map[string]int{A: Aval, B: Bval}
 	Aval = Aval - X
 	Bval = Bval + X
 	logger.Debugf("[txID %s] Aval = %d, Bval = %d\n", txID, Aval, Bval)
 
+	cfg, ccErr := getTokenConfig(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if cfg != nil {
+		if ccErr := checkConservation(stub, cfg, before, map[string]int{A: Aval, B: Bval}); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	}
+
 	// Write the state back to the ledger
 	err = stub.PutState(A, []byte(strconv.Itoa(Aval)))
 	if err != nil {
-		return shim.Error(err.Error())
+		return errorResponse(errCodeStateFailure, A, "Failed to put state: %s", err)
 	}
 
 	err = stub.PutState(B, []byte(strconv.Itoa(Bval)))
 	if err != nil {
-		return shim.Error(err.Error())
+		return errorResponse(errCodeStateFailure, B, "Failed to put state: %s", err)
 	}
 
 	if transientMap, err := stub.GetTransient(); err == nil {
@@ -254,18 +472,208 @@ func (t *SimpleChaincode) move(stub shim.ChaincodeStubInterface, args []string)
 	return shim.Success(nil)
 }
 
-// Deletes an entity from state
+// getBalance reads the asset holding stored in key
+func getBalance(stub shim.ChaincodeStubInterface, key string) (int, *ccError) {
+	valbytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, newCCError(errCodeStateFailure, key, "Failed to get state: %s", err)
+	}
+	if valbytes == nil {
+		return 0, newCCError(errCodeNotFound, key, "Entity not found")
+	}
+	val, err := strconv.Atoi(string(valbytes))
+	if err != nil {
+		return 0, newCCError(errCodeInvalidValue, key, "Stored value is not an integer asset holding")
+	}
+	return val, nil
+}
+
+// parseAmount parses a transaction amount, which must not be negative
+func parseAmount(arg string) (int, *ccError) {
+	amount, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, newCCError(errCodeInvalidValue, "", "Invalid transaction amount, expecting a integer value")
+	}
+	if amount < 0 {
+		return 0, newCCError(errCodeInvalidValue, "", "Invalid transaction amount, expecting a non negative value")
+	}
+	return amount, nil
+}
+
+// transferLeg moves an amount from one key to another as part of a transfer
+type transferLeg struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+// transferEvent is the payload of the event emitted by transfer
+type transferEvent struct {
+	TxID string        `json:"txId"`
+	Legs []transferLeg `json:"legs"`
+}
+
+// transfer applies several moves atomically: either all legs are applied or none.
+// The legs are applied in order, so a key may pass on funds it received in an earlier leg.
+// arg1: legs in the form [{"from": "a", "to": "b", "amount": 10}, ...], arg2: event ID (optional, defaults to transferEvent)
+func (t *SimpleChaincode) transfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	txID := stub.GetTxID()
+	if len(args) != 2 && len(args) != 3 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting the transfer legs and an optional event ID")
+	}
+	eventID := "transferEvent"
+	if len(args) == 3 {
+		eventID = args[2]
+	}
+
+	var legs []transferLeg
+	if err := json.Unmarshal([]byte(args[1]), &legs); err != nil {
+		return errorResponse(errCodeInvalidArgs, "", "Invalid transfer legs: %s", err)
+	}
+	if len(legs) == 0 {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting at least one transfer leg")
+	}
+
+	// Balances are read once and updated in memory, then written back together
+	balances := make(map[string]int)
+	initial := make(map[string]int)
+	checked := make(map[string]bool)
+	var keys []string
+	balance := func(key string) (int, *ccError) {
+		if val, ok := balances[key]; ok {
+			return val, nil
+		}
+		val, ccErr := getBalance(stub, key)
+		if ccErr != nil {
+			return 0, ccErr
+		}
+		balances[key] = val
+		initial[key] = val
+		keys = append(keys, key)
+		return val, nil
+	}
+
+	for i, leg := range legs {
+		if leg.From == "" || leg.To == "" {
+			return errorResponse(errCodeInvalidArgs, "", "Leg %d: expecting non empty keys", i)
+		}
+		if leg.From == leg.To {
+			return errorResponse(errCodeInvalidArgs, leg.From, "Leg %d: cannot move to the same key", i)
+		}
+		if leg.Amount < 0 {
+			return errorResponse(errCodeInvalidValue, "", "Leg %d: invalid transaction amount, expecting a non negative value", i)
+		}
+
+		fromVal, ccErr := balance(leg.From)
+		if ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+		if !checked[leg.From] {
+			if ccErr := checkOwnership(stub, leg.From); ccErr != nil {
+				return shim.Error(ccErr.Error())
+			}
+			checked[leg.From] = true
+		}
+		toVal, ccErr := balance(leg.To)
+		if ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+		if fromVal < leg.Amount {
+			return errorResponse(errCodeInsufficient, leg.From, "Leg %d: insufficient funds, balance %d is less than %d", i, fromVal, leg.Amount)
+		}
+		balances[leg.From] = fromVal - leg.Amount
+		balances[leg.To] = toVal + leg.Amount
+	}
+
+	cfg, ccErr := getTokenConfig(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if cfg != nil {
+		if ccErr := checkConservation(stub, cfg, initial, balances); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	}
+
+	for _, key := range keys {
+		logger.Debugf("[txID %s] %s = %d\n", txID, key, balances[key])
+		if err := stub.PutState(key, []byte(strconv.Itoa(balances[key]))); err != nil {
+			return errorResponse(errCodeStateFailure, key, "Failed to put state: %s", err)
+		}
+	}
+
+	payload, err := json.Marshal(transferEvent{TxID: txID, Legs: legs})
+	if err != nil {
+		return errorResponse(errCodeEventFailure, "", "Failed to marshal event: %s", err)
+	}
+	if err := stub.SetEvent(eventID, payload); err != nil {
+		return errorResponse(errCodeEventFailure, "", "Failed to set event: %s", err)
+	}
+	return shim.Success(nil)
+}
+
+// deletedEvent is the payload of the event emitted by delete
+type deletedEvent struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Deletes an entity from state and emits an event holding its last value
+// arg1: key, arg2: event ID (optional, defaults to deleteEvent)
 func (t *SimpleChaincode) delete(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1")
+	if len(args) != 2 && len(args) != 3 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key and an optional event ID")
 	}
 
 	A := args[1]
+	if A == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
+	eventID := "deleteEvent"
+	if len(args) == 3 {
+		eventID = args[2]
+	}
+
+	Avalbytes, err := stub.GetState(A)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, A, "Failed to get state: %s", err)
+	}
+	if Avalbytes == nil {
+		return errorResponse(errCodeNotFound, A, "Entity not found")
+	}
+	if ccErr := checkOwnership(stub, A); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	// Deleting a token account with a balance would change the total supply
+	isAccount, ccErr := isTokenAccount(stub, A)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if isAccount {
+		if string(Avalbytes) != "0" {
+			return errorResponse(errCodeForbidden, A, "Only token accounts with value 0 may be deleted")
+		}
+		if ccErr := delTokenAccount(stub, A); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+	}
 
 	// Delete the key from the state in ledger
-	err := stub.DelState(A)
+	err = stub.DelState(A)
 	if err != nil {
-		return shim.Error("Failed to delete state")
+		return errorResponse(errCodeStateFailure, A, "Failed to delete state: %s", err)
+	}
+	if ccErr := delOwner(stub, A); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	payload, err := json.Marshal(deletedEvent{Key: A, Value: string(Avalbytes)})
+	if err != nil {
+		return errorResponse(errCodeEventFailure, A, "Failed to marshal event: %s", err)
+	}
+	if err := stub.SetEvent(eventID, payload); err != nil {
+		return errorResponse(errCodeEventFailure, A, "Failed to set event: %s", err)
 	}
 
 	return shim.Success(nil)
@@ -277,21 +685,22 @@ func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, args []string)
 	var err error
 
 	if len(args) != 2 {
-		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting name of the person to query")
 	}
 
 	A = args[1]
+	if A == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
 
 	// Get the state from the ledger
 	Avalbytes, err := stub.GetState(A)
 	if err != nil {
-		jsonResp := "{\"Error\":\"Failed to get state for " + A + "\"}"
-		return shim.Error(jsonResp)
+		return errorResponse(errCodeStateFailure, A, "Failed to get state for %s", A)
 	}
 
 	if Avalbytes == nil {
-		jsonResp := "{\"Error\":\"Nil amount for " + A + "\"}"
-		return shim.Error(jsonResp)
+		return errorResponse(errCodeNotFound, A, "Nil amount for %s", A)
 	}
 
 	jsonResp := "{\"Name\":\"" + A + "\",\"Amount\":\"" + string(Avalbytes) + "\"}"
@@ -299,6 +708,113 @@ func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, args []string)
 	return shim.Success(Avalbytes)
 }
 
+// keyModification is a change of a key returned by history
+type keyModification struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     string    `json:"value"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// history returns every modification of a key as JSON, oldest first
+// arg1: key
+func (t *SimpleChaincode) history(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key")
+	}
+
+	key := args[1]
+	if key == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
+
+	iter, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get history: %s", err)
+	}
+	defer iter.Close()
+
+	mods := []keyModification{}
+	for iter.HasNext() {
+		mod, err := iter.Next()
+		if err != nil {
+			return errorResponse(errCodeStateFailure, key, "Failed to iterate history: %s", err)
+		}
+		m := keyModification{TxID: mod.TxId, Value: string(mod.Value), IsDelete: mod.IsDelete}
+		if mod.Timestamp != nil {
+			m.Timestamp = time.Unix(mod.Timestamp.GetSeconds(), int64(mod.Timestamp.GetNanos())).UTC()
+		}
+		mods = append(mods, m)
+	}
+	if len(mods) == 0 {
+		return errorResponse(errCodeNotFound, key, "Key has no history")
+	}
+
+	modsJSON, err := json.Marshal(mods)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to marshal history: %s", err)
+	}
+	return shim.Success(modsJSON)
+}
+
+// stateRecord is a key and its value returned by range
+type stateRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// statePage is a page of state returned by range
+type statePage struct {
+	Records  []stateRecord `json:"records"`
+	Count    int32         `json:"count"`
+	Bookmark string        `json:"bookmark"`
+}
+
+// rangeQuery returns a page of the keys in [startKey, endKey) as JSON. Empty keys
+// mean an open range, the bookmark of the previous page continues the iteration.
+// arg1: start key, arg2: end key, arg3: page size, arg4: bookmark (optional)
+func (t *SimpleChaincode) rangeQuery(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting start key, end key and page size")
+	}
+
+	startKey := args[1]
+	endKey := args[2]
+	pageSize, err := strconv.ParseInt(args[3], 10, 32)
+	if err != nil || pageSize <= 0 {
+		return errorResponse(errCodeInvalidValue, "", "Invalid page size, expecting a positive integer value")
+	}
+	bookmark := ""
+	if len(args) >= 5 {
+		bookmark = args[4]
+	}
+
+	iter, metadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, startKey, "Failed to get state by range: %s", err)
+	}
+	defer iter.Close()
+
+	page := statePage{Records: []stateRecord{}}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return errorResponse(errCodeStateFailure, "", "Failed to iterate state: %s", err)
+		}
+		page.Records = append(page.Records, stateRecord{Key: kv.Key, Value: string(kv.Value)})
+	}
+	if metadata != nil {
+		page.Count = metadata.FetchedRecordsCount
+		page.Bookmark = metadata.Bookmark
+	}
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to marshal page: %s", err)
+	}
+	return shim.Success(pageJSON)
+}
+
 type argStruct struct {
 	Args []string `json:"Args"`
 }
@@ -311,27 +827,87 @@ func asBytes(args []string) [][]byte {
 	return bytes
 }
 
-// invokeCC invokes another chaincode
+// invokeCCResponse is the response of invokecc, it carries the response of the callee
+type invokeCCResponse struct {
+	ChaincodeID string `json:"chaincodeId"`
+	ChannelID   string `json:"channelId"`
+	Status      int32  `json:"status"`
+	Message     string `json:"message,omitempty"`
+	Payload     []byte `json:"payload"`
+}
+
+// invokeCC invokes another chaincode that is on the allow-list. Chaincodes on other channels
+// can only be queried, their state changes are not committed.
 // arg0: ID of chaincode to invoke
 // arg1: Chaincode arguments in the form: {"Args": ["arg0", "arg1",...]}
+// arg2: optional channel of the chaincode, the current channel if empty
+// arg3: optional "true" to record the call in the <txid>_invokedcc key
 func (t *SimpleChaincode) invokeCC(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) < 2 {
-		return shim.Error("Incorrect number of arguments. Expecting ID of chaincode to invoke and args")
+	if len(args) < 2 || len(args) > 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting ID of chaincode to invoke, args, and optionally a channel and a marker flag")
 	}
 
 	ccID := args[0]
 	invokeArgsJSON := args[1]
+	if ccID == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty chaincode ID")
+	}
+	channelID := stub.GetChannelID()
+	if len(args) >= 3 && args[2] != "" {
+		channelID = args[2]
+	}
+	marker := false
+	if len(args) == 4 {
+		var err error
+		if marker, err = strconv.ParseBool(args[3]); err != nil {
+			return errorResponse(errCodeInvalidValue, "", "Invalid marker flag, expecting true or false")
+		}
+	}
 
 	argStruct := argStruct{}
 	if err := json.Unmarshal([]byte(invokeArgsJSON), &argStruct); err != nil {
-		return shim.Error(fmt.Sprintf("Invalid invoke args: %s", err))
+		return errorResponse(errCodeInvalidArgs, "", "Invalid invoke args: %s", err)
+	}
+	if len(argStruct.Args) == 0 {
+		return errorResponse(errCodeInvalidArgs, "", "Invalid invoke args: expecting at least a function name")
 	}
 
-	if err := stub.PutState(stub.GetTxID()+"_invokedcc", []byte(ccID)); err != nil {
-		return shim.Error(fmt.Sprintf("Error putting state: %s", err))
+	allowed, ccErr := isInvokeAllowed(stub, channelID, ccID)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if !allowed {
+		return errorResponse(errCodeForbidden, ccID, "Chaincode [%s] on channel [%s] is not on the allow-list", ccID, channelID)
 	}
 
-	return stub.InvokeChaincode(ccID, asBytes(argStruct.Args), "")
+	if marker {
+		key := stub.GetTxID() + "_invokedcc"
+		if err := stub.PutState(key, []byte(ccID)); err != nil {
+			return errorResponse(errCodeStateFailure, key, "Error putting state: %s", err)
+		}
+	}
+
+	// The channel is only passed when it differs, so that the callee runs in this transaction
+	targetChannel := ""
+	if channelID != stub.GetChannelID() {
+		targetChannel = channelID
+	}
+	response := stub.InvokeChaincode(ccID, asBytes(argStruct.Args), targetChannel)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return errorResponse(errCodeInvokeFailure, ccID, "Invoking chaincode [%s] failed with status %d: %s", ccID, response.Status, response.Message)
+	}
+
+	responseJSON, err := json.Marshal(&invokeCCResponse{
+		ChaincodeID: ccID,
+		ChannelID:   channelID,
+		Status:      response.Status,
+		Message:     response.Message,
+		Payload:     response.Payload,
+	})
+	if err != nil {
+		return errorResponse(errCodeStateFailure, ccID, "Failed to marshal response: %s", err)
+	}
+	return shim.Success(responseJSON)
 }
 
 func main() {
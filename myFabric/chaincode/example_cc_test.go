@@ -0,0 +1,995 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const testChannelID = "testchannel"
+
+// attrOID is the certificate extension holding the attributes read by the cid library
+var attrOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+var (
+	user1 = testIdentity{MSPID: "Org1MSP", Name: "user1"}
+	user2 = testIdentity{MSPID: "Org1MSP", Name: "user2"}
+	admin = testIdentity{MSPID: "Org1MSP", Name: "admin", Admin: true}
+	user3 = testIdentity{MSPID: "Org2MSP", Name: "user3"}
+)
+
+// testIdentity is a client calling the chaincode
+type testIdentity struct {
+	MSPID string
+	Name  string
+	Admin bool
+}
+
+// serialize returns the serialized identity of a self signed certificate for the client
+func (id testIdentity) serialize(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key failed: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: id.Name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if id.Admin {
+		template.ExtraExtensions = []pkix.Extension{{Id: attrOID, Value: []byte(`{"attrs":{"` + adminAttribute + `":"true"}}`)}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate failed: %s", err)
+	}
+
+	sid, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   id.MSPID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatalf("marshalling identity failed: %s", err)
+	}
+	return sid
+}
+
+// testStub wraps a mock stub of example CC with the identities of the test clients
+type testStub struct {
+	*shim.MockStub
+	t          *testing.T
+	identities map[string][]byte
+	txCount    int
+	// lastTxID is the ID of the last transaction, the mock stub clears its TxID when it ends
+	lastTxID string
+}
+
+// newTestStub returns a mock stub of example CC instantiated by user1 with a=100 and b=200,
+// so user1 owns a and b
+func newTestStub(t *testing.T) *testStub {
+	stub := &testStub{
+		MockStub:   shim.NewMockStub("example_cc", new(SimpleChaincode)),
+		t:          t,
+		identities: make(map[string][]byte),
+	}
+	stub.ChannelID = testChannelID
+
+	stub.as(user1)
+	res := stub.MockInit(stub.nextTxID(), toBytes("init", "a", "100", "b", "200"))
+	if res.Status != shim.OK {
+		t.Fatalf("init failed: %s", res.Message)
+	}
+	stub.drainEvents()
+	return stub
+}
+
+// as makes the following calls as the given client
+func (s *testStub) as(id testIdentity) *testStub {
+	sid, ok := s.identities[id.Name]
+	if !ok {
+		sid = id.serialize(s.t)
+		s.identities[id.Name] = sid
+	}
+	s.Creator = sid
+	return s
+}
+
+func (s *testStub) nextTxID() string {
+	s.txCount++
+	s.lastTxID = fmt.Sprintf("tx%d", s.txCount)
+	return s.lastTxID
+}
+
+func (s *testStub) invoke(args ...string) pb.Response {
+	return s.MockInvoke(s.nextTxID(), toBytes(args...))
+}
+
+// lastEvent returns the last event emitted since the previous call, or nil
+func (s *testStub) lastEvent() *pb.ChaincodeEvent {
+	var last *pb.ChaincodeEvent
+	for {
+		select {
+		case ev := <-s.ChaincodeEventsChannel:
+			last = ev
+		default:
+			return last
+		}
+	}
+}
+
+func (s *testStub) drainEvents() {
+	s.lastEvent()
+}
+
+func (s *testStub) state(key string) string {
+	return string(s.State[key])
+}
+
+// owner returns the owner recorded for key, or nil if there is none
+func (s *testStub) owner(key string) *owner {
+	compositeKey, err := s.CreateCompositeKey(ownerObjectType, []string{key})
+	if err != nil {
+		s.t.Fatalf("creating owner key failed: %s", err)
+	}
+	ownerBytes := s.State[compositeKey]
+	if ownerBytes == nil {
+		return nil
+	}
+	o := &owner{}
+	if err := json.Unmarshal(ownerBytes, o); err != nil {
+		s.t.Fatalf("decoding owner of %s failed: %s", key, err)
+	}
+	return o
+}
+
+func toBytes(args ...string) [][]byte {
+	bytes := make([][]byte, len(args))
+	for i, arg := range args {
+		bytes[i] = []byte(arg)
+	}
+	return bytes
+}
+
+// errorCode returns the code of the JSON error of a failed response
+func errorCode(t *testing.T, res pb.Response) string {
+	if res.Status == shim.OK {
+		return ""
+	}
+	ccErr := &ccError{}
+	if err := json.Unmarshal([]byte(res.Message), ccErr); err != nil {
+		t.Fatalf("response message is not a JSON error: %s", res.Message)
+	}
+	return ccErr.Code
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode string
+	}{
+		{name: "valid", args: []string{"init", "a", "100", "b", "200"}},
+		{name: "missing args", args: []string{"init", "a", "100"}, wantCode: errCodeInvalidArgs},
+		{name: "empty key", args: []string{"init", "", "100", "b", "200"}, wantCode: errCodeInvalidArgs},
+		{name: "non integer value", args: []string{"init", "a", "x", "b", "200"}, wantCode: errCodeInvalidValue},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &testStub{MockStub: shim.NewMockStub("example_cc", new(SimpleChaincode)), t: t, identities: make(map[string][]byte)}
+			stub.as(user1)
+
+			res := stub.MockInit("init", toBytes(tc.args...))
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantCode != "" {
+				return
+			}
+			if stub.state("a") != "100" || stub.state("b") != "200" {
+				t.Fatalf("unexpected state a=%s b=%s", stub.state("a"), stub.state("b"))
+			}
+			for _, key := range []string{"a", "b"} {
+				if o := stub.owner(key); o == nil || *o != (owner{MSPID: user1.MSPID, Subject: "CN=" + user1.Name}) {
+					t.Fatalf("expected the instantiating user1 to own %s, got %+v", key, o)
+				}
+			}
+		})
+	}
+}
+
+func TestInitTransient(t *testing.T) {
+	stub := &testStub{MockStub: shim.NewMockStub("example_cc", new(SimpleChaincode)), t: t, identities: make(map[string][]byte)}
+	stub.as(user1)
+	stub.TransientMap = map[string][]byte{"result": []byte("transient result")}
+
+	res := stub.MockInit("init", toBytes("init", "a", "100", "b", "200"))
+	if res.Status != shim.OK {
+		t.Fatalf("init failed: %s", res.Message)
+	}
+	if string(res.Payload) != "transient result" {
+		t.Fatalf("expected the transient result as payload, got [%s]", res.Payload)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	tests := []struct {
+		name        string
+		caller      testIdentity
+		args        []string
+		wantCode    string
+		wantPayload string
+		wantState   map[string]string
+		wantEvent   string
+	}{
+		{name: "unknown function", args: []string{"foo", "a"}, wantCode: errCodeUnknownFunction},
+		{name: "missing action args", args: []string{"invoke", "query"}, wantCode: errCodeInvalidArgs},
+		{name: "unknown action", args: []string{"invoke", "foo", "a"}, wantCode: errCodeUnknownFunction},
+
+		{name: "query", args: []string{"invoke", "query", "a"}, wantPayload: "100"},
+		{name: "query missing key", args: []string{"invoke", "query", "x"}, wantCode: errCodeNotFound},
+		{name: "query empty key", args: []string{"invoke", "query", ""}, wantCode: errCodeInvalidArgs},
+		{name: "query extra args", args: []string{"invoke", "query", "a", "b"}, wantCode: errCodeInvalidArgs},
+
+		{name: "set new key", args: []string{"invoke", "set", "c", "5"}, wantState: map[string]string{"c": "5"}, wantEvent: "testEvent"},
+		{name: "set with event", args: []string{"invoke", "set", "c", "5", "myEvent"}, wantState: map[string]string{"c": "5"}, wantEvent: "myEvent"},
+		{name: "set own key", args: []string{"invoke", "set", "a", "7"}, wantState: map[string]string{"a": "7"}, wantEvent: "testEvent"},
+		{name: "set key of other owner", caller: user2, args: []string{"invoke", "set", "a", "7"}, wantCode: errCodeForbidden},
+		{name: "set key of other owner as admin", caller: admin, args: []string{"invoke", "set", "a", "7"}, wantState: map[string]string{"a": "7"}},
+		{name: "set missing value", args: []string{"invoke", "set", "c"}, wantCode: errCodeInvalidArgs},
+		{name: "set empty key", args: []string{"invoke", "set", "", "5"}, wantCode: errCodeInvalidArgs},
+
+		{name: "move", args: []string{"invoke", "move", "a", "b", "10"}, wantState: map[string]string{"a": "90", "b": "210"}, wantEvent: "testEvent"},
+		{name: "move with event", args: []string{"invoke", "move", "a", "b", "10", "moveEvent"}, wantState: map[string]string{"a": "90", "b": "210"}, wantEvent: "moveEvent"},
+		{name: "move all funds", args: []string{"invoke", "move", "a", "b", "100"}, wantState: map[string]string{"a": "0", "b": "300"}},
+		{name: "move overdraft", args: []string{"invoke", "move", "a", "b", "101"}, wantCode: errCodeInsufficient, wantState: map[string]string{"a": "100", "b": "200"}},
+		{name: "move negative amount", args: []string{"invoke", "move", "a", "b", "-1"}, wantCode: errCodeInvalidValue},
+		{name: "move non integer amount", args: []string{"invoke", "move", "a", "b", "x"}, wantCode: errCodeInvalidValue},
+		{name: "move from missing key", args: []string{"invoke", "move", "x", "b", "1"}, wantCode: errCodeNotFound},
+		{name: "move to missing key", args: []string{"invoke", "move", "a", "x", "1"}, wantCode: errCodeNotFound},
+		{name: "move to same key", args: []string{"invoke", "move", "a", "a", "1"}, wantCode: errCodeInvalidArgs},
+		{name: "move missing amount", args: []string{"invoke", "move", "a", "b"}, wantCode: errCodeInvalidArgs},
+		{name: "move from key of other owner", caller: user2, args: []string{"invoke", "move", "a", "b", "1"}, wantCode: errCodeForbidden},
+
+		{name: "transfer", args: []string{"invoke", "transfer", `[{"from":"a","to":"b","amount":10},{"from":"b","to":"a","amount":30}]`}, wantState: map[string]string{"a": "120", "b": "180"}, wantEvent: "transferEvent"},
+		{name: "transfer with event", args: []string{"invoke", "transfer", `[{"from":"a","to":"b","amount":10}]`, "txEvent"}, wantState: map[string]string{"a": "90", "b": "210"}, wantEvent: "txEvent"},
+		{name: "transfer overdraft in a later leg", args: []string{"invoke", "transfer", `[{"from":"a","to":"b","amount":10},{"from":"a","to":"b","amount":91}]`}, wantCode: errCodeInsufficient, wantState: map[string]string{"a": "100", "b": "200"}},
+		{name: "transfer no legs", args: []string{"invoke", "transfer", `[]`}, wantCode: errCodeInvalidArgs},
+		{name: "transfer invalid legs", args: []string{"invoke", "transfer", `{`}, wantCode: errCodeInvalidArgs},
+		{name: "transfer negative amount", args: []string{"invoke", "transfer", `[{"from":"a","to":"b","amount":-1}]`}, wantCode: errCodeInvalidValue},
+		{name: "transfer from key of other owner", caller: user2, args: []string{"invoke", "transfer", `[{"from":"a","to":"b","amount":1}]`}, wantCode: errCodeForbidden},
+
+		{name: "delete", args: []string{"invoke", "delete", "a"}, wantState: map[string]string{"a": ""}, wantEvent: "deleteEvent"},
+		{name: "delete with event", args: []string{"invoke", "delete", "a", "gone"}, wantState: map[string]string{"a": ""}, wantEvent: "gone"},
+		{name: "delete missing key", args: []string{"invoke", "delete", "x"}, wantCode: errCodeNotFound},
+		{name: "delete key of other owner", caller: user2, args: []string{"invoke", "delete", "a"}, wantCode: errCodeForbidden, wantState: map[string]string{"a": "100"}},
+
+		{name: "owner missing key", args: []string{"invoke", "owner", "x"}, wantCode: errCodeNotFound},
+		{name: "chown", args: []string{"invoke", "chown", "a", "Org2MSP", "CN=user3"}},
+		{name: "chown key of other owner", caller: user2, args: []string{"invoke", "chown", "a", "Org1MSP", "CN=user2"}, wantCode: errCodeForbidden},
+		{name: "chown missing subject", args: []string{"invoke", "chown", "a", "Org2MSP", ""}, wantCode: errCodeInvalidArgs},
+
+		// The mock stub does not support pagination, only the arguments are checked
+		{name: "range invalid page size", args: []string{"invoke", "range", "", "", "0"}, wantCode: errCodeInvalidValue},
+		{name: "range missing page size", args: []string{"invoke", "range", "", ""}, wantCode: errCodeInvalidArgs},
+		{name: "history missing key", args: []string{"invoke", "history", ""}, wantCode: errCodeInvalidArgs},
+		{name: "docs invalid page size", args: []string{"invoke", "docs", "account", "", "x"}, wantCode: errCodeInvalidValue},
+		{name: "querydocs invalid query", args: []string{"invoke", "querydocs", "{", "10"}, wantCode: errCodeInvalidValue},
+
+		{name: "putdoc", args: []string{"invoke", "putdoc", "account", "user1", "1", `{"balance":10}`}},
+		{name: "putdoc invalid data", args: []string{"invoke", "putdoc", "account", "user1", "1", `{`}, wantCode: errCodeInvalidValue},
+		{name: "putdoc empty id", args: []string{"invoke", "putdoc", "account", "user1", "", `{}`}, wantCode: errCodeInvalidArgs},
+		{name: "putdoc of other owner", args: []string{"invoke", "putdoc", "account", "user2", "1", `{}`}, wantCode: errCodeForbidden},
+		{name: "putdoc of other owner as admin", caller: admin, args: []string{"invoke", "putdoc", "account", "user2", "1", `{}`}},
+		{name: "getdoc missing", args: []string{"invoke", "getdoc", "account", "user1", "1"}, wantCode: errCodeNotFound},
+		{name: "deldoc missing", args: []string{"invoke", "deldoc", "account", "user1", "1"}, wantCode: errCodeNotFound},
+
+		{name: "reset", args: []string{"reset", "a", "1", "b", "2"}, wantState: map[string]string{"a": "1", "b": "2"}},
+		{name: "reset keys of other owner", caller: user2, args: []string{"reset", "a", "1", "b", "2"}, wantCode: errCodeForbidden, wantState: map[string]string{"a": "100"}},
+		{name: "reset keys of other owner as admin", caller: admin, args: []string{"reset", "a", "1", "b", "2"}, wantState: map[string]string{"a": "1", "b": "2"}},
+		{name: "reset new keys", caller: user2, args: []string{"reset", "c", "1", "d", "2"}, wantState: map[string]string{"c": "1", "d": "2"}},
+		{name: "reset missing args", args: []string{"reset", "a", "1"}, wantCode: errCodeInvalidArgs},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newTestStub(t)
+			caller := tc.caller
+			if caller.Name == "" {
+				caller = user1
+			}
+
+			res := stub.as(caller).invoke(tc.args...)
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantPayload != "" && string(res.Payload) != tc.wantPayload {
+				t.Fatalf("expected payload [%s], got [%s]", tc.wantPayload, res.Payload)
+			}
+			for key, value := range tc.wantState {
+				if got := stub.state(key); got != value {
+					t.Fatalf("expected %s=[%s], got [%s]", key, value, got)
+				}
+			}
+			if tc.wantEvent != "" {
+				ev := stub.lastEvent()
+				if ev == nil || ev.EventName != tc.wantEvent {
+					t.Fatalf("expected event [%s], got %v", tc.wantEvent, ev)
+				}
+			}
+		})
+	}
+}
+
+// historyStub is a test stub answering GetHistoryForKey from history, which the mock stub
+// of the shim does not implement. It invokes the chaincode itself, so that the chaincode
+// calls the methods of historyStub.
+type historyStub struct {
+	*testStub
+	args    [][]byte
+	history map[string][]*queryresult.KeyModification
+	// err fails GetHistoryForKey, iterErr the iteration of the history
+	err, iterErr error
+}
+
+func (s *historyStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *historyStub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+func (s *historyStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+func (s *historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &historyIterator{mods: s.history[key], err: s.iterErr}, nil
+}
+
+func (s *historyStub) invoke(args ...string) pb.Response {
+	txID := s.nextTxID()
+	s.args = toBytes(args...)
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+	return new(SimpleChaincode).Invoke(s)
+}
+
+// historyIterator iterates the modifications of a key, failing on the last one if err is set
+type historyIterator struct {
+	mods []*queryresult.KeyModification
+	err  error
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.mods) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if len(it.mods) == 0 {
+		return nil, fmt.Errorf("no more modifications")
+	}
+	mod := it.mods[0]
+	it.mods = it.mods[1:]
+	if len(it.mods) == 0 && it.err != nil {
+		return nil, it.err
+	}
+	return mod, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
+
+func TestHistory(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	history := map[string][]*queryresult.KeyModification{
+		"a": {
+			{TxId: "tx1", Value: []byte("100"), Timestamp: &timestamp.Timestamp{Seconds: created.Unix(), Nanos: int32(created.Nanosecond())}},
+			{TxId: "tx2", Value: []byte("90"), Timestamp: &timestamp.Timestamp{Seconds: created.Unix() + 60}},
+			{TxId: "tx3", IsDelete: true},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		err      error
+		iterErr  error
+		wantCode string
+		want     []keyModification
+	}{
+		{name: "history", key: "a", want: []keyModification{
+			{TxID: "tx1", Value: "100", Timestamp: created},
+			{TxID: "tx2", Value: "90", Timestamp: created.Add(time.Minute).Truncate(time.Second)},
+			{TxID: "tx3", IsDelete: true},
+		}},
+		{name: "no history", key: "x", wantCode: errCodeNotFound},
+		{name: "history failure", key: "a", err: fmt.Errorf("history database disabled"), wantCode: errCodeStateFailure},
+		{name: "iteration failure", key: "a", iterErr: fmt.Errorf("iterator closed"), wantCode: errCodeStateFailure},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &historyStub{testStub: newTestStub(t), history: history, err: tc.err, iterErr: tc.iterErr}
+
+			res := stub.invoke("invoke", "history", tc.key)
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantCode != "" {
+				return
+			}
+			var mods []keyModification
+			if err := json.Unmarshal(res.Payload, &mods); err != nil {
+				t.Fatalf("invalid history: %s", err)
+			}
+			if len(mods) != len(tc.want) {
+				t.Fatalf("expected %d modifications, got %d", len(tc.want), len(mods))
+			}
+			for i, mod := range mods {
+				want := tc.want[i]
+				if mod.TxID != want.TxID || mod.Value != want.Value || mod.IsDelete != want.IsDelete || !mod.Timestamp.Equal(want.Timestamp) {
+					t.Fatalf("expected modification %d to be %+v, got %+v", i, want, mod)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteEventPayload(t *testing.T) {
+	stub := newTestStub(t)
+
+	if res := stub.invoke("invoke", "delete", "a"); res.Status != shim.OK {
+		t.Fatalf("delete failed: %s", res.Message)
+	}
+	ev := stub.lastEvent()
+	if ev == nil {
+		t.Fatal("expected a delete event")
+	}
+	deleted := deletedEvent{}
+	if err := json.Unmarshal(ev.Payload, &deleted); err != nil {
+		t.Fatalf("invalid event payload: %s", err)
+	}
+	if deleted.Key != "a" || deleted.Value != "100" {
+		t.Fatalf("unexpected event payload %+v", deleted)
+	}
+	if res := stub.invoke("invoke", "owner", "a"); errorCode(t, res) != errCodeNotFound {
+		t.Fatalf("expected the owner to be deleted with the key, got %s", res.Payload)
+	}
+}
+
+func TestMoveTransient(t *testing.T) {
+	stub := newTestStub(t)
+	stub.TransientMap = map[string][]byte{"result": []byte("moved")}
+
+	res := stub.invoke("invoke", "move", "a", "b", "1")
+	if res.Status != shim.OK {
+		t.Fatalf("move failed: %s", res.Message)
+	}
+	if string(res.Payload) != "moved" {
+		t.Fatalf("expected the transient result as payload, got [%s]", res.Payload)
+	}
+}
+
+// TestInitOwnership checks that Init and reset hand their keys to the caller, and that only
+// admins may change keys without owner
+func TestInitOwnership(t *testing.T) {
+	stub := newTestStub(t)
+
+	// Other clients may not use the keys of the instantiating client
+	if res := stub.as(user2).invoke("invoke", "move", "a", "b", "10"); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user2 to be forbidden to move from a key of user1, got %s", res.Message)
+	}
+	if res := stub.as(user2).invoke("invoke", "delete", "b"); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user2 to be forbidden to delete a key of user1, got %s", res.Message)
+	}
+	if res := stub.as(user2).invoke("invoke", "chown", "a", user2.MSPID, "CN="+user2.Name); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user2 to be forbidden to take a key of user1, got %s", res.Message)
+	}
+
+	// The caller of reset owns the keys, including those that were new
+	if res := stub.as(user1).invoke("invoke", "chown", "a", user2.MSPID, "CN="+user2.Name); res.Status != shim.OK {
+		t.Fatalf("chown failed: %s", res.Message)
+	}
+	if res := stub.as(user2).invoke("reset", "a", "1", "c", "2"); res.Status != shim.OK {
+		t.Fatalf("reset failed: %s", res.Message)
+	}
+	for _, key := range []string{"a", "c"} {
+		if o := stub.owner(key); o == nil || o.Subject != "CN="+user2.Name {
+			t.Fatalf("expected user2 to own %s after its reset, got %+v", key, o)
+		}
+	}
+
+	// An upgrade hands the keys to the upgrading client regardless of their owners
+	if res := stub.as(user1).MockInit(stub.nextTxID(), toBytes("init", "a", "100", "b", "200")); res.Status != shim.OK {
+		t.Fatalf("upgrade failed: %s", res.Message)
+	}
+	if o := stub.owner("a"); o == nil || o.Subject != "CN="+user1.Name {
+		t.Fatalf("expected the upgrade to hand a to user1, got %+v", o)
+	}
+
+	// A key without owner, written before owners were recorded, is only changed by admins
+	stub.State["x"] = []byte("50")
+	for _, args := range [][]string{{"move", "x", "a", "1"}, {"set", "x", "1"}, {"delete", "x"}, {"chown", "x", user1.MSPID, "CN=" + user1.Name}} {
+		if res := stub.as(user1).invoke(append([]string{"invoke"}, args...)...); errorCode(t, res) != errCodeForbidden {
+			t.Fatalf("expected %s of a key without owner to be forbidden, got %s", args[0], res.Message)
+		}
+	}
+	if res := stub.as(admin).invoke("invoke", "move", "x", "a", "1"); res.Status != shim.OK {
+		t.Fatalf("expected an admin to move from a key without owner: %s", res.Message)
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	stub := newTestStub(t)
+
+	// user2 owns the keys it creates
+	if res := stub.as(user2).invoke("invoke", "set", "c", "5"); res.Status != shim.OK {
+		t.Fatalf("set failed: %s", res.Message)
+	}
+	if res := stub.as(user1).invoke("invoke", "set", "c", "6"); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user1 to be forbidden to change c, got %s", res.Message)
+	}
+
+	// after chown the new owner may change the key and the old one may not
+	if res := stub.as(user1).invoke("invoke", "chown", "a", user2.MSPID, "CN="+user2.Name); res.Status != shim.OK {
+		t.Fatalf("chown failed: %s", res.Message)
+	}
+	if res := stub.as(user2).invoke("invoke", "move", "a", "b", "1"); res.Status != shim.OK {
+		t.Fatalf("expected the new owner to move funds: %s", res.Message)
+	}
+	if res := stub.as(user1).invoke("invoke", "move", "a", "b", "1"); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected the old owner to be forbidden, got %s", res.Message)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	stub := newTestStub(t)
+
+	if res := stub.invoke("invoke", "putdoc", "account", "user1", "1", `{"balance":10}`); res.Status != shim.OK {
+		t.Fatalf("putdoc failed: %s", res.Message)
+	}
+	res := stub.invoke("invoke", "getdoc", "account", "user1", "1")
+	if res.Status != shim.OK {
+		t.Fatalf("getdoc failed: %s", res.Message)
+	}
+	doc := &document{}
+	if err := json.Unmarshal(res.Payload, doc); err != nil {
+		t.Fatalf("invalid document: %s", err)
+	}
+	if doc.DocType != "account" || doc.Owner != "user1" || doc.ID != "1" || string(doc.Data) != `{"balance":10}` {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	if doc.Creator == nil || doc.Creator.Subject != "CN=user1" {
+		t.Fatalf("expected user1 as creator, got %+v", doc.Creator)
+	}
+
+	if res := stub.as(user2).invoke("invoke", "putdoc", "account", "user1", "1", `{}`); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user2 to be forbidden to replace the document, got %s", res.Message)
+	}
+	if res := stub.as(user2).invoke("invoke", "deldoc", "account", "user1", "1"); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user2 to be forbidden to delete the document, got %s", res.Message)
+	}
+	// The owner is the caller, other clients cannot write documents in its name
+	if res := stub.as(user2).invoke("invoke", "putdoc", "account", "user1", "2", `{}`); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected user2 to be forbidden to create a document of user1, got %s", res.Message)
+	}
+	if res := stub.as(admin).invoke("invoke", "putdoc", "account", "user1", "1", `{"balance":20}`); res.Status != shim.OK {
+		t.Fatalf("expected an admin to replace the document: %s", res.Message)
+	}
+	res = stub.invoke("invoke", "getdoc", "account", "user1", "1")
+	if err := json.Unmarshal(res.Payload, doc); err != nil || doc.Creator == nil || doc.Creator.Subject != "CN=user1" || string(doc.Data) != `{"balance":20}` {
+		t.Fatalf("expected the admin to keep user1 as creator, got %+v: %v", doc, err)
+	}
+
+	if res := stub.as(user1).invoke("invoke", "deldoc", "account", "user1", "1"); res.Status != shim.OK {
+		t.Fatalf("deldoc failed: %s", res.Message)
+	}
+	if res := stub.invoke("invoke", "getdoc", "account", "user1", "1"); errorCode(t, res) != errCodeNotFound {
+		t.Fatalf("expected the document to be deleted, got %s", res.Payload)
+	}
+}
+
+func TestKeyEndorsement(t *testing.T) {
+	stub := newTestStub(t)
+
+	getOrgs := func() []string {
+		res := stub.invoke("invoke", "getep", "a")
+		if res.Status != shim.OK {
+			t.Fatalf("getep failed: %s", res.Message)
+		}
+		endorsement := keyEndorsement{}
+		if err := json.Unmarshal(res.Payload, &endorsement); err != nil {
+			t.Fatalf("invalid endorsement policy: %s", err)
+		}
+		return endorsement.Orgs
+	}
+
+	if orgs := getOrgs(); len(orgs) != 0 {
+		t.Fatalf("expected no key-level policy, got %v", orgs)
+	}
+	if res := stub.invoke("invoke", "setep", "a", "Org2MSP, Org1MSP"); res.Status != shim.OK {
+		t.Fatalf("setep failed: %s", res.Message)
+	}
+	if orgs := getOrgs(); strings.Join(orgs, ",") != "Org1MSP,Org2MSP" {
+		t.Fatalf("expected Org1MSP and Org2MSP, got %v", orgs)
+	}
+	if res := stub.invoke("invoke", "setep", "a", ""); res.Status != shim.OK {
+		t.Fatalf("clearing the policy failed: %s", res.Message)
+	}
+	if orgs := getOrgs(); len(orgs) != 0 {
+		t.Fatalf("expected the policy to be removed, got %v", orgs)
+	}
+
+	tests := []struct {
+		name     string
+		caller   testIdentity
+		args     []string
+		wantCode string
+	}{
+		{name: "peer role", caller: user1, args: []string{"invoke", "setep", "a", "Org1MSP", "peer"}},
+		{name: "invalid role", caller: user1, args: []string{"invoke", "setep", "a", "Org1MSP", "client"}, wantCode: errCodeInvalidValue},
+		{name: "not owner", caller: user2, args: []string{"invoke", "setep", "a", "Org1MSP"}, wantCode: errCodeForbidden},
+		{name: "missing key", caller: user1, args: []string{"invoke", "setep", "missing", "Org1MSP"}, wantCode: errCodeNotFound},
+		{name: "missing orgs", caller: user1, args: []string{"invoke", "setep", "a"}, wantCode: errCodeInvalidArgs},
+		{name: "get missing key", caller: user1, args: []string{"invoke", "getep", "missing"}, wantCode: errCodeNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := stub.as(tc.caller).invoke(tc.args...); errorCode(t, res) != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, errorCode(t, res), res.Message)
+			}
+		})
+	}
+}
+
+// TestOwnerEndorsement checks that the key-level endorsement policy of a key also applies to
+// its owner record, so that chown cannot bypass it
+func TestOwnerEndorsement(t *testing.T) {
+	stub := newTestStub(t)
+	policies := func(key string) ([]byte, []byte) {
+		ownerKey, err := stub.CreateCompositeKey(ownerObjectType, []string{key})
+		if err != nil {
+			t.Fatalf("creating owner key failed: %s", err)
+		}
+		policy, _ := stub.GetStateValidationParameter(key)
+		ownerPolicy, _ := stub.GetStateValidationParameter(ownerKey)
+		return policy, ownerPolicy
+	}
+
+	if res := stub.as(user1).invoke("invoke", "setep", "a", "Org1MSP,Org2MSP"); res.Status != shim.OK {
+		t.Fatalf("setep failed: %s", res.Message)
+	}
+	if policy, ownerPolicy := policies("a"); len(policy) == 0 || !bytes.Equal(policy, ownerPolicy) {
+		t.Fatalf("expected the owner of a to carry the policy of a, got %x and %x", policy, ownerPolicy)
+	}
+	if res := stub.invoke("invoke", "chown", "a", user2.MSPID, "CN="+user2.Name); res.Status != shim.OK {
+		t.Fatalf("chown failed: %s", res.Message)
+	}
+	if policy, ownerPolicy := policies("a"); !bytes.Equal(policy, ownerPolicy) {
+		t.Fatalf("expected the new owner of a to carry the policy of a, got %x and %x", policy, ownerPolicy)
+	}
+	if res := stub.as(user2).invoke("invoke", "setep", "a", ""); res.Status != shim.OK {
+		t.Fatalf("clearing the policy failed: %s", res.Message)
+	}
+	if policy, ownerPolicy := policies("a"); len(policy) != 0 || len(ownerPolicy) != 0 {
+		t.Fatalf("expected the policies of a and its owner to be removed, got %x and %x", policy, ownerPolicy)
+	}
+
+	// A key without owner gets the policy on the owner record when it is handed over
+	stub.State["x"] = []byte("50")
+	if res := stub.as(admin).invoke("invoke", "setep", "x", "Org2MSP"); res.Status != shim.OK {
+		t.Fatalf("setep failed: %s", res.Message)
+	}
+	if _, ownerPolicy := policies("x"); len(ownerPolicy) != 0 {
+		t.Fatalf("expected no policy without owner, got %x", ownerPolicy)
+	}
+	if res := stub.invoke("invoke", "chown", "x", user1.MSPID, "CN="+user1.Name); res.Status != shim.OK {
+		t.Fatalf("chown failed: %s", res.Message)
+	}
+	if policy, ownerPolicy := policies("x"); len(policy) == 0 || !bytes.Equal(policy, ownerPolicy) {
+		t.Fatalf("expected the owner of x to carry the policy of x, got %x and %x", policy, ownerPolicy)
+	}
+}
+
+// newTokenStub returns a mock stub of example CC in token mode issued by Org1MSP with
+// a cap of 1000 and a=100, b=200 owned by user1
+func newTokenStub(t *testing.T) *testStub {
+	stub := newTestStub(t)
+	res := stub.MockInit(stub.nextTxID(), toBytes("inittoken", user1.MSPID, "2", "1000", "a", "100", "b", "200"))
+	if res.Status != shim.OK {
+		t.Fatalf("inittoken failed: %s", res.Message)
+	}
+	return stub
+}
+
+// supply returns the token configuration queried by totalsupply
+func (s *testStub) supply() tokenConfig {
+	res := s.invoke("totalsupply")
+	if res.Status != shim.OK {
+		s.t.Fatalf("totalsupply failed: %s", res.Message)
+	}
+	cfg := tokenConfig{}
+	if err := json.Unmarshal(res.Payload, &cfg); err != nil {
+		s.t.Fatalf("invalid token config: %s", err)
+	}
+	return cfg
+}
+
+func TestInitToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode string
+	}{
+		{name: "valid", args: []string{"inittoken", "Org1MSP", "2", "1000", "a", "100", "b", "200"}},
+		{name: "missing holdings", args: []string{"inittoken", "Org1MSP", "2", "1000"}, wantCode: errCodeInvalidArgs},
+		{name: "odd holdings", args: []string{"inittoken", "Org1MSP", "2", "1000", "a", "100", "b"}, wantCode: errCodeInvalidArgs},
+		{name: "empty issuer", args: []string{"inittoken", "", "2", "1000", "a", "100"}, wantCode: errCodeInvalidArgs},
+		{name: "invalid decimals", args: []string{"inittoken", "Org1MSP", "19", "1000", "a", "100"}, wantCode: errCodeInvalidValue},
+		{name: "invalid cap", args: []string{"inittoken", "Org1MSP", "2", "0", "a", "100"}, wantCode: errCodeInvalidValue},
+		{name: "duplicate key", args: []string{"inittoken", "Org1MSP", "2", "1000", "a", "100", "a", "1"}, wantCode: errCodeInvalidArgs},
+		{name: "cap exceeded", args: []string{"inittoken", "Org1MSP", "2", "250", "a", "100", "b", "200"}, wantCode: errCodeSupplyCapExceeded},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newTestStub(t)
+
+			res := stub.MockInit(stub.nextTxID(), toBytes(tc.args...))
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantCode != "" {
+				return
+			}
+			if cfg := stub.supply(); cfg != (tokenConfig{Issuer: "Org1MSP", Decimals: 2, Cap: 1000, TotalSupply: 300}) {
+				t.Fatalf("unexpected token config %+v", cfg)
+			}
+		})
+	}
+
+	// a plain init disables token mode again
+	stub := newTokenStub(t)
+	if res := stub.MockInit(stub.nextTxID(), toBytes("init", "a", "100", "b", "200")); res.Status != shim.OK {
+		t.Fatalf("init failed: %s", res.Message)
+	}
+	if res := stub.invoke("totalsupply"); errorCode(t, res) != errCodeTokenModeDisabled {
+		t.Fatalf("expected token mode to be disabled, got %s", res.Message)
+	}
+}
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		caller     testIdentity
+		args       []string
+		wantCode   string
+		wantSupply int
+		wantState  map[string]string
+	}{
+		{name: "move", caller: user1, args: []string{"invoke", "move", "a", "b", "10"}, wantSupply: 300, wantState: map[string]string{"a": "90", "b": "210"}},
+		{name: "transfer", caller: user1, args: []string{"invoke", "transfer", `[{"from":"a","to":"b","amount":10},{"from":"b","to":"a","amount":5}]`}, wantSupply: 300, wantState: map[string]string{"a": "95", "b": "205"}},
+		{name: "move from non account", caller: admin, args: []string{"invoke", "move", "x", "a", "1"}, wantCode: errCodeSupplyInvariant},
+		{name: "mint", caller: user1, args: []string{"invoke", "mint", "b", "700"}, wantSupply: 1000, wantState: map[string]string{"b": "900"}},
+		{name: "mint over cap", caller: user1, args: []string{"invoke", "mint", "b", "701"}, wantCode: errCodeSupplyCapExceeded},
+		{name: "mint by other MSP", caller: user3, args: []string{"invoke", "mint", "b", "1"}, wantCode: errCodeForbidden},
+		{name: "mint to non account", caller: user1, args: []string{"invoke", "mint", "x", "1"}, wantCode: errCodeSupplyInvariant},
+		{name: "mint to missing key", caller: user1, args: []string{"invoke", "mint", "missing", "1"}, wantCode: errCodeNotFound},
+		{name: "burn", caller: user1, args: []string{"invoke", "burn", "a", "40"}, wantSupply: 260, wantState: map[string]string{"a": "60"}},
+		{name: "burn too much", caller: user1, args: []string{"invoke", "burn", "a", "101"}, wantCode: errCodeInsufficient},
+		{name: "burn by other MSP", caller: user3, args: []string{"invoke", "burn", "a", "1"}, wantCode: errCodeForbidden},
+		{name: "burn of other owner", caller: user2, args: []string{"invoke", "burn", "a", "1"}, wantCode: errCodeForbidden},
+		{name: "set new account", caller: user2, args: []string{"invoke", "set", "c", "0"}, wantSupply: 300, wantState: map[string]string{"c": "0"}},
+		{name: "set balance", caller: user2, args: []string{"invoke", "set", "c", "5"}, wantCode: errCodeForbidden},
+		{name: "set existing account", caller: user1, args: []string{"invoke", "set", "a", "0"}, wantCode: errCodeForbidden},
+		{name: "reset", caller: user1, args: []string{"reset", "a", "1", "b", "2"}, wantCode: errCodeForbidden},
+		{name: "delete account with balance", caller: user1, args: []string{"invoke", "delete", "a"}, wantCode: errCodeForbidden},
+		{name: "delete non account", caller: admin, args: []string{"invoke", "delete", "x"}, wantSupply: 300, wantState: map[string]string{"x": ""}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newTokenStub(t)
+			// x is a plain key that is not part of the supply
+			stub.State["x"] = []byte("50")
+
+			res := stub.as(tc.caller).invoke(tc.args...)
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantCode != "" {
+				return
+			}
+			for key, value := range tc.wantState {
+				if got := stub.state(key); got != value {
+					t.Fatalf("expected %s=%s, got %s", key, value, got)
+				}
+			}
+			if cfg := stub.supply(); cfg.TotalSupply != tc.wantSupply {
+				t.Fatalf("expected total supply %d, got %d", tc.wantSupply, cfg.TotalSupply)
+			}
+		})
+	}
+}
+
+func TestTokenDeleteEmptyAccount(t *testing.T) {
+	stub := newTokenStub(t)
+
+	if res := stub.invoke("invoke", "move", "a", "b", "100"); res.Status != shim.OK {
+		t.Fatalf("move failed: %s", res.Message)
+	}
+	if res := stub.invoke("invoke", "delete", "a"); res.Status != shim.OK {
+		t.Fatalf("delete failed: %s", res.Message)
+	}
+	// a key created again by set is a new empty account
+	if res := stub.invoke("invoke", "set", "a", "0"); res.Status != shim.OK {
+		t.Fatalf("set failed: %s", res.Message)
+	}
+	if cfg := stub.supply(); cfg.TotalSupply != 300 {
+		t.Fatalf("expected total supply 300, got %d", cfg.TotalSupply)
+	}
+}
+
+func TestTokenMintToNewAccount(t *testing.T) {
+	stub := newTokenStub(t)
+
+	// the issuer mints into an account its owner created, which keeps its owner
+	if res := stub.as(user2).invoke("invoke", "set", "c", "0"); res.Status != shim.OK {
+		t.Fatalf("set failed: %s", res.Message)
+	}
+	if res := stub.as(user1).invoke("invoke", "mint", "c", "50"); res.Status != shim.OK {
+		t.Fatalf("mint failed: %s", res.Message)
+	}
+	if got := stub.state("c"); got != "50" {
+		t.Fatalf("expected c=50, got %s", got)
+	}
+	if cfg := stub.supply(); cfg.TotalSupply != 350 {
+		t.Fatalf("expected total supply 350, got %d", cfg.TotalSupply)
+	}
+	if res := stub.as(user2).invoke("invoke", "move", "c", "a", "10"); res.Status != shim.OK {
+		t.Fatalf("expected the owner to move the minted tokens, got %s", res.Message)
+	}
+}
+
+// calleeCC is a chaincode called by invokecc, it returns its args or fails if asked to
+type calleeCC struct{}
+
+func (c *calleeCC) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (c *calleeCC) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function == "fail" {
+		return shim.Error("callee failed")
+	}
+	return shim.Success([]byte(function + ":" + strings.Join(args, ",")))
+}
+
+func TestInvokeCC(t *testing.T) {
+	const otherChannelID = "otherchannel"
+
+	tests := []struct {
+		name        string
+		allow       []string
+		args        []string
+		wantCode    string
+		wantChannel string
+		wantPayload string
+		wantMarker  bool
+	}{
+		{name: "not allowed", args: []string{"invokecc", "callee", `{"Args":["get","a"]}`}, wantCode: errCodeForbidden},
+		{name: "allowed", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":["get","a"]}`}, wantChannel: testChannelID, wantPayload: "get:a"},
+		{name: "marker", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":["get"]}`, "", "true"}, wantChannel: testChannelID, wantPayload: "get:", wantMarker: true},
+		{name: "invalid marker", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":["get"]}`, "", "x"}, wantCode: errCodeInvalidValue},
+		{name: "other channel", allow: []string{"callee", otherChannelID}, args: []string{"invokecc", "callee", `{"Args":["get"]}`, otherChannelID}, wantChannel: otherChannelID, wantPayload: "get:"},
+		{name: "other channel not allowed", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":["get"]}`, otherChannelID}, wantCode: errCodeForbidden},
+		{name: "callee failure", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":["fail"]}`}, wantCode: errCodeInvokeFailure},
+		{name: "invalid args", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":`}, wantCode: errCodeInvalidArgs},
+		{name: "no function", allow: []string{"callee"}, args: []string{"invokecc", "callee", `{"Args":[]}`}, wantCode: errCodeInvalidArgs},
+		{name: "empty chaincode", args: []string{"invokecc", "", `{"Args":["get"]}`}, wantCode: errCodeInvalidArgs},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newTestStub(t)
+			stub.MockPeerChaincode("callee", shim.NewMockStub("callee", new(calleeCC)))
+			stub.MockPeerChaincode("callee/"+otherChannelID, shim.NewMockStub("callee", new(calleeCC)))
+
+			if len(tc.allow) > 0 {
+				if res := stub.as(admin).invoke(append([]string{"allowcc"}, tc.allow...)...); res.Status != shim.OK {
+					t.Fatalf("allowcc failed: %s", res.Message)
+				}
+			}
+
+			res := stub.as(user1).invoke(tc.args...)
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			_, hasMarker := stub.State[stub.lastTxID+"_invokedcc"]
+			if hasMarker != tc.wantMarker {
+				t.Fatalf("expected marker %t, got %t", tc.wantMarker, hasMarker)
+			}
+			if tc.wantCode != "" {
+				return
+			}
+
+			resp := &invokeCCResponse{}
+			if err := json.Unmarshal(res.Payload, resp); err != nil {
+				t.Fatalf("invalid response: %s", err)
+			}
+			if resp.ChaincodeID != "callee" || resp.ChannelID != tc.wantChannel || resp.Status != shim.OK || string(resp.Payload) != tc.wantPayload {
+				t.Fatalf("unexpected response %+v", resp)
+			}
+		})
+	}
+}
+
+func TestInvokeCCAllowList(t *testing.T) {
+	stub := newTestStub(t)
+
+	if res := stub.as(user1).invoke("allowcc", "callee"); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected non admins to be forbidden to change the allow-list, got %s", res.Message)
+	}
+	if res := stub.as(admin).invoke("allowcc", "callee"); res.Status != shim.OK {
+		t.Fatalf("allowcc failed: %s", res.Message)
+	}
+
+	res := stub.invoke("allowedcc")
+	if res.Status != shim.OK {
+		t.Fatalf("allowedcc failed: %s", res.Message)
+	}
+	var entries []allowedCC
+	if err := json.Unmarshal(res.Payload, &entries); err != nil {
+		t.Fatalf("invalid allow-list: %s", err)
+	}
+	if len(entries) != 1 || entries[0].ChaincodeID != "callee" || entries[0].ChannelID != testChannelID {
+		t.Fatalf("unexpected allow-list %+v", entries)
+	}
+
+	if res := stub.as(admin).invoke("disallowcc", "callee"); res.Status != shim.OK {
+		t.Fatalf("disallowcc failed: %s", res.Message)
+	}
+	if res := stub.as(user1).invoke("invokecc", "callee", `{"Args":["get"]}`); errorCode(t, res) != errCodeForbidden {
+		t.Fatalf("expected the removed chaincode to be forbidden, got %s", res.Message)
+	}
+}
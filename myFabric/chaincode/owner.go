@@ -0,0 +1,211 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// ownerObjectType prefixes the composite keys holding the owner of a key
+	ownerObjectType = "owner"
+	// adminAttribute is the certificate attribute that allows changing keys of any owner
+	adminAttribute = "examplecc.admin"
+)
+
+// owner identifies the client that created a key
+type owner struct {
+	MSPID   string `json:"mspId"`
+	Subject string `json:"subject"`
+}
+
+// callerOwner returns the identity of the client that submitted the transaction
+func callerOwner(stub shim.ChaincodeStubInterface) (*owner, *ccError) {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return nil, newCCError(errCodeForbidden, "", "Failed to get the MSP ID of the caller: %s", err)
+	}
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil || cert == nil {
+		return nil, newCCError(errCodeForbidden, "", "Failed to get the certificate of the caller: %v", err)
+	}
+	return &owner{MSPID: mspID, Subject: cert.Subject.String()}, nil
+}
+
+// isAdmin returns true if the caller holds the admin attribute
+func isAdmin(stub shim.ChaincodeStubInterface) bool {
+	return cid.AssertAttributeValue(stub, adminAttribute, "true") == nil
+}
+
+func ownerKey(stub shim.ChaincodeStubInterface, key string) (string, *ccError) {
+	compositeKey, err := stub.CreateCompositeKey(ownerObjectType, []string{key})
+	if err != nil {
+		return "", newCCError(errCodeInvalidArgs, key, "Failed to create owner key: %s", err)
+	}
+	return compositeKey, nil
+}
+
+// getOwner returns the owner recorded for key, or nil if there is none
+func getOwner(stub shim.ChaincodeStubInterface, key string) (*owner, *ccError) {
+	compositeKey, ccErr := ownerKey(stub, key)
+	if ccErr != nil {
+		return nil, ccErr
+	}
+	ownerBytes, err := stub.GetState(compositeKey)
+	if err != nil {
+		return nil, newCCError(errCodeStateFailure, key, "Failed to get owner: %s", err)
+	}
+	if ownerBytes == nil {
+		return nil, nil
+	}
+
+	o := &owner{}
+	if err := json.Unmarshal(ownerBytes, o); err != nil {
+		return nil, newCCError(errCodeStateFailure, key, "Failed to unmarshal owner: %s", err)
+	}
+	return o, nil
+}
+
+func putOwner(stub shim.ChaincodeStubInterface, key string, o *owner) *ccError {
+	compositeKey, ccErr := ownerKey(stub, key)
+	if ccErr != nil {
+		return ccErr
+	}
+	ownerBytes, err := json.Marshal(o)
+	if err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to marshal owner: %s", err)
+	}
+	if err := stub.PutState(compositeKey, ownerBytes); err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to put owner: %s", err)
+	}
+
+	policy, err := stub.GetStateValidationParameter(key)
+	if err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to get endorsement policy: %s", err)
+	}
+	if len(policy) == 0 {
+		return nil
+	}
+	return setOwnerEndorsement(stub, key, policy)
+}
+
+// setOwnerEndorsement puts the key-level endorsement policy of key on the record of its owner,
+// so that handing the key over needs the same endorsements as changing it
+func setOwnerEndorsement(stub shim.ChaincodeStubInterface, key string, policy []byte) *ccError {
+	compositeKey, ccErr := ownerKey(stub, key)
+	if ccErr != nil {
+		return ccErr
+	}
+	if err := stub.SetStateValidationParameter(compositeKey, policy); err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to set endorsement policy of owner: %s", err)
+	}
+	return nil
+}
+
+func delOwner(stub shim.ChaincodeStubInterface, key string) *ccError {
+	compositeKey, ccErr := ownerKey(stub, key)
+	if ccErr != nil {
+		return ccErr
+	}
+	if err := stub.DelState(compositeKey); err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to delete owner: %s", err)
+	}
+	return nil
+}
+
+// claimOwnership records the caller as the owner of key unless it already has one
+func claimOwnership(stub shim.ChaincodeStubInterface, key string) *ccError {
+	o, ccErr := getOwner(stub, key)
+	if ccErr != nil || o != nil {
+		return ccErr
+	}
+	caller, ccErr := callerOwner(stub)
+	if ccErr != nil {
+		return ccErr
+	}
+	return putOwner(stub, key, caller)
+}
+
+// checkOwnership fails unless the caller owns key or holds the admin attribute.
+// Keys without a recorded owner, written before owners were recorded, may only be
+// changed by admins.
+func checkOwnership(stub shim.ChaincodeStubInterface, key string) *ccError {
+	if isAdmin(stub) {
+		return nil
+	}
+
+	o, ccErr := getOwner(stub, key)
+	if ccErr != nil {
+		return ccErr
+	}
+	if o == nil {
+		return newCCError(errCodeForbidden, key, "Key has no owner, only admins may change it")
+	}
+	caller, ccErr := callerOwner(stub)
+	if ccErr != nil {
+		return ccErr
+	}
+	if *caller != *o {
+		return newCCError(errCodeForbidden, key, "Caller is not the owner of the key")
+	}
+	return nil
+}
+
+// queryOwner returns the owner of a key as JSON
+// arg1: key
+func (t *SimpleChaincode) queryOwner(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key")
+	}
+
+	o, ccErr := getOwner(stub, args[1])
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if o == nil {
+		return errorResponse(errCodeNotFound, args[1], "Key has no owner")
+	}
+
+	ownerJSON, err := json.Marshal(o)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, args[1], "Failed to marshal owner: %s", err)
+	}
+	return shim.Success(ownerJSON)
+}
+
+// changeOwner hands a key over to another identity, it is allowed for the owner and admins
+// arg1: key, arg2: MSP ID of the new owner, arg3: certificate subject of the new owner
+func (t *SimpleChaincode) changeOwner(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key, an MSP ID and a subject")
+	}
+
+	key := args[1]
+	if args[2] == "" || args[3] == "" {
+		return errorResponse(errCodeInvalidArgs, key, "Expecting a non empty MSP ID and subject")
+	}
+
+	valbytes, err := stub.GetState(key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get state: %s", err)
+	}
+	if valbytes == nil {
+		return errorResponse(errCodeNotFound, key, "Entity not found")
+	}
+
+	if ccErr := checkOwnership(stub, key); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if ccErr := putOwner(stub, key, &owner{MSPID: args[2], Subject: args[3]}); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	return shim.Success(nil)
+}
@@ -0,0 +1,367 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// tokenObjectType prefixes the composite keys of the token configuration and accounts
+	tokenObjectType = "token"
+	// maxTokenDecimals is the largest decimal precision of a token
+	maxTokenDecimals = 18
+)
+
+// tokenConfig is stored when example CC was initialized in token mode. Balances are integer
+// amounts of the smallest unit, Decimals only tells clients how to display them.
+type tokenConfig struct {
+	Issuer      string `json:"issuer"`
+	Decimals    int    `json:"decimals"`
+	Cap         int    `json:"cap"`
+	TotalSupply int    `json:"totalSupply"`
+}
+
+// tokenEvent is the payload of the events emitted by mint and burn
+type tokenEvent struct {
+	Key         string `json:"key"`
+	Amount      int    `json:"amount"`
+	TotalSupply int    `json:"totalSupply"`
+}
+
+func tokenConfigKey(stub shim.ChaincodeStubInterface) (string, *ccError) {
+	compositeKey, err := stub.CreateCompositeKey(tokenObjectType, []string{"config"})
+	if err != nil {
+		return "", newCCError(errCodeInvalidArgs, "", "Failed to create token config key: %s", err)
+	}
+	return compositeKey, nil
+}
+
+func tokenAccountKey(stub shim.ChaincodeStubInterface, key string) (string, *ccError) {
+	compositeKey, err := stub.CreateCompositeKey(tokenObjectType, []string{"account", key})
+	if err != nil {
+		return "", newCCError(errCodeInvalidArgs, key, "Failed to create token account key: %s", err)
+	}
+	return compositeKey, nil
+}
+
+// getTokenConfig returns the token configuration, or nil if token mode is disabled
+func getTokenConfig(stub shim.ChaincodeStubInterface) (*tokenConfig, *ccError) {
+	compositeKey, ccErr := tokenConfigKey(stub)
+	if ccErr != nil {
+		return nil, ccErr
+	}
+	cfgBytes, err := stub.GetState(compositeKey)
+	if err != nil {
+		return nil, newCCError(errCodeStateFailure, "", "Failed to get token config: %s", err)
+	}
+	if cfgBytes == nil {
+		return nil, nil
+	}
+
+	cfg := &tokenConfig{}
+	if err := json.Unmarshal(cfgBytes, cfg); err != nil {
+		return nil, newCCError(errCodeStateFailure, "", "Failed to unmarshal token config: %s", err)
+	}
+	return cfg, nil
+}
+
+func putTokenConfig(stub shim.ChaincodeStubInterface, cfg *tokenConfig) *ccError {
+	compositeKey, ccErr := tokenConfigKey(stub)
+	if ccErr != nil {
+		return ccErr
+	}
+	cfgBytes, err := json.Marshal(cfg)
+	if err != nil {
+		return newCCError(errCodeStateFailure, "", "Failed to marshal token config: %s", err)
+	}
+	if err := stub.PutState(compositeKey, cfgBytes); err != nil {
+		return newCCError(errCodeStateFailure, "", "Failed to put token config: %s", err)
+	}
+	return nil
+}
+
+// requireTokenConfig returns the token configuration or an error if token mode is disabled
+func requireTokenConfig(stub shim.ChaincodeStubInterface) (*tokenConfig, *ccError) {
+	cfg, ccErr := getTokenConfig(stub)
+	if ccErr != nil {
+		return nil, ccErr
+	}
+	if cfg == nil {
+		return nil, newCCError(errCodeTokenModeDisabled, "", "Example CC was not initialized in token mode")
+	}
+	return cfg, nil
+}
+
+// isTokenAccount returns true if the key holds a balance counted in the total supply
+func isTokenAccount(stub shim.ChaincodeStubInterface, key string) (bool, *ccError) {
+	compositeKey, ccErr := tokenAccountKey(stub, key)
+	if ccErr != nil {
+		return false, ccErr
+	}
+	value, err := stub.GetState(compositeKey)
+	if err != nil {
+		return false, newCCError(errCodeStateFailure, key, "Failed to get token account: %s", err)
+	}
+	return value != nil, nil
+}
+
+func putTokenAccount(stub shim.ChaincodeStubInterface, key string) *ccError {
+	compositeKey, ccErr := tokenAccountKey(stub, key)
+	if ccErr != nil {
+		return ccErr
+	}
+	if err := stub.PutState(compositeKey, []byte{0}); err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to put token account: %s", err)
+	}
+	return nil
+}
+
+func delTokenAccount(stub shim.ChaincodeStubInterface, key string) *ccError {
+	compositeKey, ccErr := tokenAccountKey(stub, key)
+	if ccErr != nil {
+		return ccErr
+	}
+	if err := stub.DelState(compositeKey); err != nil {
+		return newCCError(errCodeStateFailure, key, "Failed to delete token account: %s", err)
+	}
+	return nil
+}
+
+// clearToken disables token mode. The balances are kept, but they are no longer accounts.
+func clearToken(stub shim.ChaincodeStubInterface) *ccError {
+	iter, err := stub.GetStateByPartialCompositeKey(tokenObjectType, []string{})
+	if err != nil {
+		return newCCError(errCodeStateFailure, "", "Failed to get token state: %s", err)
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return newCCError(errCodeStateFailure, "", "Failed to iterate token state: %s", err)
+		}
+		if err := stub.DelState(kv.Key); err != nil {
+			return newCCError(errCodeStateFailure, "", "Failed to delete token state: %s", err)
+		}
+	}
+	return nil
+}
+
+// initToken initializes example CC in token mode. The total supply is the sum of the
+// initial holdings, it may later be changed by mint and burn within the cap.
+// args: issuer MSP ID, decimals, cap, then key and amount pairs of the initial holdings
+func (t *SimpleChaincode) initToken(stub shim.ChaincodeStubInterface, txID string, args []string) *ccError {
+	if len(args) < 5 || len(args)%2 != 1 {
+		return newCCError(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting issuer, decimals, cap and key and amount pairs")
+	}
+
+	cfg := &tokenConfig{Issuer: args[0]}
+	if cfg.Issuer == "" {
+		return newCCError(errCodeInvalidArgs, "", "Expecting a non empty issuer MSP ID")
+	}
+	decimals, err := strconv.Atoi(args[1])
+	if err != nil || decimals < 0 || decimals > maxTokenDecimals {
+		return newCCError(errCodeInvalidValue, "", "Invalid decimals, expecting an integer value from 0 to %d", maxTokenDecimals)
+	}
+	cfg.Decimals = decimals
+	supplyCap, err := strconv.Atoi(args[2])
+	if err != nil || supplyCap <= 0 {
+		return newCCError(errCodeInvalidValue, "", "Invalid cap, expecting a positive integer value")
+	}
+	cfg.Cap = supplyCap
+
+	holdings := make(map[string]int)
+	var keys []string
+	for i := 3; i < len(args); i += 2 {
+		key := args[i]
+		if key == "" {
+			return newCCError(errCodeInvalidArgs, "", "Expecting a non empty key")
+		}
+		if _, ok := holdings[key]; ok {
+			return newCCError(errCodeInvalidArgs, key, "Duplicate key in initial holdings")
+		}
+		amount, ccErr := parseAmount(args[i+1])
+		if ccErr != nil {
+			ccErr.Key = key
+			return ccErr
+		}
+		if amount > cfg.Cap-cfg.TotalSupply {
+			return newCCError(errCodeSupplyCapExceeded, key, "Initial holdings exceed the cap of %d", cfg.Cap)
+		}
+		cfg.TotalSupply += amount
+		holdings[key] = amount
+		keys = append(keys, key)
+	}
+	logger.Debugf("[txID %s] token issuer = %s, cap = %d, total supply = %d\n", txID, cfg.Issuer, cfg.Cap, cfg.TotalSupply)
+
+	// Accounts of an earlier token mode must not count in the new supply
+	if ccErr := clearToken(stub); ccErr != nil {
+		return ccErr
+	}
+	for _, key := range keys {
+		if err := stub.PutState(key, []byte(strconv.Itoa(holdings[key]))); err != nil {
+			return newCCError(errCodeStateFailure, key, "Failed to put state: %s", err)
+		}
+		if ccErr := putTokenAccount(stub, key); ccErr != nil {
+			return ccErr
+		}
+		if ccErr := claimOwnership(stub, key); ccErr != nil {
+			return ccErr
+		}
+	}
+	return putTokenConfig(stub, cfg)
+}
+
+// checkIssuer returns an error if the caller is not a member of the issuer MSP
+func checkIssuer(stub shim.ChaincodeStubInterface, cfg *tokenConfig) *ccError {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return newCCError(errCodeForbidden, "", "Failed to get the MSP ID of the caller: %s", err)
+	}
+	if mspID != cfg.Issuer {
+		return newCCError(errCodeForbidden, "", "Only members of the issuer MSP [%s] may mint and burn", cfg.Issuer)
+	}
+	return nil
+}
+
+// checkConservation verifies that a move of funds conserves tokens within its transaction:
+// all keys must be token accounts, their balances must add up to the same sum before and
+// after and none may exceed the total supply. It does not check the sum of all accounts,
+// which is not tracked. That sum stays equal to the total supply because only init, mint
+// and burn change the supply, together with the balance, and every other change of a
+// balance is a move that passes this check.
+func checkConservation(stub shim.ChaincodeStubInterface, cfg *tokenConfig, before, after map[string]int) *ccError {
+	sumBefore, sumAfter := 0, 0
+	for key, val := range before {
+		isAccount, ccErr := isTokenAccount(stub, key)
+		if ccErr != nil {
+			return ccErr
+		}
+		if !isAccount {
+			return newCCError(errCodeSupplyInvariant, key, "Key is not a token account")
+		}
+		newVal, ok := after[key]
+		if !ok || newVal < 0 || newVal > cfg.TotalSupply {
+			return newCCError(errCodeSupplyInvariant, key, "Balance %d is out of the range of the total supply %d", newVal, cfg.TotalSupply)
+		}
+		sumBefore += val
+		sumAfter += newVal
+	}
+	if len(after) != len(before) || sumBefore != sumAfter {
+		return newCCError(errCodeSupplyInvariant, "", "Balances changed from %d to %d in total", sumBefore, sumAfter)
+	}
+	return nil
+}
+
+// mint creates an amount of tokens in an account, only the issuer may do so. Tokens are only
+// minted into existing accounts, which their owner creates by setting them to 0, so that
+// the issuer cannot become the owner of the account it mints into.
+// arg1: key, arg2: amount, arg3: event ID (optional, defaults to mintEvent)
+func (t *SimpleChaincode) mint(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return t.changeSupply(stub, args, true)
+}
+
+// burn destroys an amount of tokens of an account, only the issuer may do so and the
+// account must be owned by the caller
+// arg1: key, arg2: amount, arg3: event ID (optional, defaults to burnEvent)
+func (t *SimpleChaincode) burn(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return t.changeSupply(stub, args, false)
+}
+
+func (t *SimpleChaincode) changeSupply(stub shim.ChaincodeStubInterface, args []string, mint bool) pb.Response {
+	if len(args) != 3 && len(args) != 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a key, an amount and an optional event ID")
+	}
+
+	key := args[1]
+	if key == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+	}
+	amount, ccErr := parseAmount(args[2])
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	eventID := "burnEvent"
+	if mint {
+		eventID = "mintEvent"
+	}
+	if len(args) == 4 {
+		eventID = args[3]
+	}
+
+	cfg, ccErr := requireTokenConfig(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if ccErr := checkIssuer(stub, cfg); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	val, ccErr := getBalance(stub, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	isAccount, ccErr := isTokenAccount(stub, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	if !isAccount {
+		return errorResponse(errCodeSupplyInvariant, key, "Key is not a token account")
+	}
+
+	if mint {
+		if amount > cfg.Cap-cfg.TotalSupply {
+			return errorResponse(errCodeSupplyCapExceeded, key, "Minting %d exceeds the cap of %d, total supply is %d", amount, cfg.Cap, cfg.TotalSupply)
+		}
+		val += amount
+		cfg.TotalSupply += amount
+	} else {
+		if ccErr := checkOwnership(stub, key); ccErr != nil {
+			return shim.Error(ccErr.Error())
+		}
+		if val < amount {
+			return errorResponse(errCodeInsufficient, key, "Insufficient funds, balance %d is less than %d", val, amount)
+		}
+		val -= amount
+		cfg.TotalSupply -= amount
+	}
+
+	if err := stub.PutState(key, []byte(strconv.Itoa(val))); err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to put state: %s", err)
+	}
+	if ccErr := putTokenConfig(stub, cfg); ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	payload, err := json.Marshal(tokenEvent{Key: key, Amount: amount, TotalSupply: cfg.TotalSupply})
+	if err != nil {
+		return errorResponse(errCodeEventFailure, key, "Failed to marshal event: %s", err)
+	}
+	if err := stub.SetEvent(eventID, payload); err != nil {
+		return errorResponse(errCodeEventFailure, key, "Failed to set event: %s", err)
+	}
+	return shim.Success(nil)
+}
+
+// totalSupply returns the token configuration as JSON
+func (t *SimpleChaincode) totalSupply(stub shim.ChaincodeStubInterface) pb.Response {
+	cfg, ccErr := requireTokenConfig(stub)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, "", "Failed to marshal token config: %s", err)
+	}
+	return shim.Success(cfgJSON)
+}
@@ -0,0 +1,1968 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+	"myFabric/configtxgen"
+	"myFabric/cryptogen"
+)
+
+// CLI exit codes
+const (
+	exitOK             = 0
+	exitFailure        = 1
+	exitNetworkError   = 2
+	exitChaincodeError = 3
+)
+
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+)
+
+// cliOptions holds the flags shared by all commands
+type cliOptions struct {
+	ChannelID   string
+	ChaincodeID string
+	OrgName     string
+	UserName    string
+	Output      string
+	Timeout     time.Duration
+	Attempts    int
+	stdout      io.Writer
+}
+
+// retryPolicy returns the retry policy selected by the flags
+func (opts *cliOptions) retryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	policy.Opts.Attempts = opts.Attempts
+	return policy
+}
+
+// cliField is a single named value of a command result
+type cliField struct {
+	Name  string
+	Value interface{}
+}
+
+// cliResult is an ordered list of fields returned by a command
+type cliResult []cliField
+
+// MarshalJSON encodes the result as a JSON object keeping the field order
+func (r cliResult) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, f := range r {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteString(":")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+// cliRunFunc runs a command with the shared options and its positional args
+type cliRunFunc func(ctx context.Context, opts *cliOptions, args []string) (cliResult, error)
+
+// cliCommand describes a CLI sub command. A command with flags of its own registers them
+// in Flags, which returns the run function bound to their values, the others only set Run.
+type cliCommand struct {
+	Usage   string
+	Summary string
+	NArgs   int
+	// ChaincodeID is the default of the -cc flag, example CC if empty
+	ChaincodeID string
+	Flags       func(fs *flag.FlagSet) cliRunFunc
+	Run         cliRunFunc
+}
+
+var cliCommands = map[string]*cliCommand{
+	"init": {
+		Usage:   "init",
+		Summary: "create and join the channel, install and instantiate example CC, or reconcile a network manifest",
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &initOptions{}
+			fs.StringVar(&o.Manifest, "manifest", "", "YAML network manifest to reconcile, e.g. "+NetworkManifestPath)
+			fs.BoolVar(&o.MultiOrg, "multi-org", false, "create "+orgChannelID+" for Org1 and Org2 and endorse example CC by both, use -channel "+orgChannelID+" afterwards")
+			return o.run
+		},
+	},
+	"update-config": {
+		Usage:   "update-config",
+		Summary: "change the config of the channel, signed by the admins of the given orgs",
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &updateConfigOptions{}
+			fs.UintVar(&o.BatchCount, "batch-count", 0, "max number of transactions in a block")
+			fs.UintVar(&o.MaxBytes, "absolute-max-bytes", 0, "absolute max bytes of a block")
+			fs.UintVar(&o.PrefBytes, "preferred-max-bytes", 0, "preferred max bytes of a block")
+			fs.DurationVar(&o.BatchTime, "batch-timeout", 0, "time to wait before cutting a block")
+			fs.StringVar(&o.AddOrg, "add-org", "", "add an org to the channel, given as <msp id>=<msp dir>")
+			fs.StringVar(&o.Anchors, "anchors", "", "comma separated host:port anchor peers of the added org")
+			fs.StringVar(&o.ACL, "acl", "", "comma separated <resource>=<policy ref> ACLs, an empty policy ref removes the ACL")
+			fs.StringVar(&o.Policy, "policy", "", "set a policy, given as <group path>/<name>=<policy>, e.g. Application/Admins=OR('Org1MSP.admin')")
+			fs.StringVar(&o.Signers, "signers", "", "comma separated orgs whose admins sign the update (default the org)")
+			fs.BoolVar(&o.DryRun, "dry-run", false, "only print what the update would change")
+			return o.run
+		},
+	},
+	"cryptogen": {
+		Usage:   "cryptogen",
+		Summary: "generate the crypto material of the orgs of cryptogen.yaml and the env.sh of the CA keys",
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &cryptogenOptions{}
+			fs.StringVar(&o.Cryptogen, "config", GetCryptogenConfigPath(), "cryptogen.yaml to read")
+			fs.StringVar(&o.OutPath, "out", GetCryptoConfigPath(""), "directory to write the material to, existing orgs are kept")
+			fs.BoolVar(&o.Clean, "clean", false, "remove the directory first and regenerate all orgs")
+			return o.run
+		},
+	},
+	"channel-tx": {
+		Usage:   "channel-tx <channel>",
+		Summary: "generate the creation transaction and anchor peer updates of a channel from configtx.yaml",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &channelTxOptions{}
+			fs.StringVar(&o.Profile, "profile", "OneOrgChannel", "channel profile of configtx.yaml")
+			fs.StringVar(&o.Configtx, "configtx", GetConfigtxPath(), "configtx.yaml to read")
+			fs.StringVar(&o.OutPath, "out", GetChannelConfigPath(""), "directory to write <channel>.tx and <channel><org>anchors.tx to")
+			return o.run
+		},
+	},
+	"genesis-block": {
+		Usage:   "genesis-block <system channel>",
+		Summary: "generate the orderer genesis block from configtx.yaml",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &genesisBlockOptions{}
+			fs.StringVar(&o.Profile, "profile", "TwoOrgsOrdererGenesis", "orderer genesis profile of configtx.yaml")
+			fs.StringVar(&o.Configtx, "configtx", GetConfigtxPath(), "configtx.yaml to read")
+			fs.StringVar(&o.OutPath, "out", GetChannelConfigPath("twoorgs.genesis.block"), "file to write the block to")
+			return o.run
+		},
+	},
+	"set": {
+		Usage:   "set <key> <value>",
+		Summary: "set the value of a key",
+		NArgs:   2,
+		Run:     runSetCmd,
+	},
+	"get": {
+		Usage:   "get <key>",
+		Summary: "query the value of a key",
+		NArgs:   1,
+		Run:     runGetCmd,
+	},
+	"move": {
+		Usage:   "move <from> <to> <amount>",
+		Summary: "move an amount from one key to another",
+		NArgs:   3,
+		Run:     runMoveCmd,
+	},
+	"transfer": {
+		Usage:   "transfer <from:to:amount,...>",
+		Summary: "apply several moves atomically in a single transaction",
+		NArgs:   1,
+		Run:     runTransferCmd,
+	},
+	"delete": {
+		Usage:   "delete <key>",
+		Summary: "delete a key",
+		NArgs:   1,
+		Run:     runDeleteCmd,
+	},
+	"owner": {
+		Usage:   "owner <key>",
+		Summary: "query the owner of a key",
+		NArgs:   1,
+		Run:     runOwnerCmd,
+	},
+	"chown": {
+		Usage:   "chown <key> <msp id> <subject>",
+		Summary: "hand a key over to another owner",
+		NArgs:   3,
+		Run:     runChownCmd,
+	},
+	"put-doc": {
+		Usage:   "put-doc <type> <owner> <id> <json>",
+		Summary: "create or replace a JSON document",
+		NArgs:   4,
+		Run:     runPutDocCmd,
+	},
+	"get-doc": {
+		Usage:   "get-doc <type> <owner> <id>",
+		Summary: "query a JSON document",
+		NArgs:   3,
+		Run:     runGetDocCmd,
+	},
+	"delete-doc": {
+		Usage:   "delete-doc <type> <owner> <id>",
+		Summary: "delete a JSON document",
+		NArgs:   3,
+		Run:     runDeleteDocCmd,
+	},
+	"docs": {
+		Usage:   "docs",
+		Summary: "query a page of the documents of a type and owner, or matching a CouchDB selector",
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &docsOptions{}
+			fs.StringVar(&o.DocType, "type", "", "document type")
+			fs.StringVar(&o.DocOwner, "owner", "", "document owner, all owners if empty")
+			fs.StringVar(&o.Selector, "selector", "", "CouchDB query, replaces -type and -owner")
+			fs.IntVar(&o.PageSize, "page-size", DefaultDocumentPageSize, "number of documents of a page")
+			fs.StringVar(&o.Bookmark, "bookmark", "", "bookmark of the previous page")
+			fs.BoolVar(&o.All, "all", false, "query all pages")
+			return o.run
+		},
+	},
+	"invokecc": {
+		Usage:   "invokecc <chaincode> <json args>",
+		Summary: "call another chaincode through example CC with args like [\"query\",\"a\"]",
+		NArgs:   2,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &invokeCCOptions{}
+			fs.StringVar(&o.Target, "target-channel", "", "channel of the called chaincode (default the channel of example CC)")
+			fs.BoolVar(&o.Marker, "marker", false, "record the call in the <txid>_invokedcc key")
+			fs.BoolVar(&o.Query, "query", false, "query instead of submitting a transaction")
+			return o.run
+		},
+	},
+	"allow-cc": {
+		Usage:   "allow-cc <chaincode>",
+		Summary: "allow example CC to call a chaincode through invokecc, admins only",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &allowCCOptions{}
+			fs.StringVar(&o.Target, "target-channel", "", "channel of the chaincode (default the channel of example CC)")
+			fs.BoolVar(&o.Remove, "remove", false, "remove the chaincode from the allow-list")
+			return o.run
+		},
+	},
+	"allowed-cc": {
+		Usage:   "allowed-cc",
+		Summary: "list the chaincodes example CC may call through invokecc",
+		Run:     runAllowedCCCmd,
+	},
+	"endorsement": {
+		Usage:   "endorsement <key>",
+		Summary: "query the orgs that must endorse changes of a key",
+		NArgs:   1,
+		Run:     runEndorsementCmd,
+	},
+	"set-endorsement": {
+		Usage:   "set-endorsement <key> <msp id,...>",
+		Summary: "require the endorsement of every given org for changes of a key, owner only",
+		NArgs:   2,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &setEndorsementOptions{}
+			fs.StringVar(&o.Role, "role", EndorsementRoleMember, "role of the endorsers: member|peer")
+			return o.run
+		},
+	},
+	"clear-endorsement": {
+		Usage:   "clear-endorsement <key>",
+		Summary: "remove the key-level endorsement policy of a key, owner only",
+		NArgs:   1,
+		Run:     runClearEndorsementCmd,
+	},
+	"mint": {
+		Usage:   "mint <key> <amount>",
+		Summary: "create tokens in a key, issuer only, the amount may have decimals",
+		NArgs:   2,
+		Run:     runMintCmd,
+	},
+	"burn": {
+		Usage:   "burn <key> <amount>",
+		Summary: "destroy tokens of a key, issuer only, the amount may have decimals",
+		NArgs:   2,
+		Run:     runBurnCmd,
+	},
+	"total-supply": {
+		Usage:   "total-supply",
+		Summary: "query the issuer, decimals, cap and total supply of example CC in token mode",
+		Run:     runTotalSupplyCmd,
+	},
+	"register": {
+		Usage:   "register <name>",
+		Summary: "register a user with the CA of the org",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &registerOptions{}
+			fs.StringVar(&o.Secret, "secret", "", "enrollment secret, generated by the CA if empty")
+			fs.StringVar(&o.Affiliation, "affiliation", "", "affiliation of the user (default <org>.department1)")
+			fs.BoolVar(&o.Admin, "admin", false, "allow the user to change keys of any owner")
+			return o.run
+		},
+	},
+	"enroll": {
+		Usage:   "enroll <name>",
+		Summary: "enroll a registered user and store its certificate",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &enrollOptions{}
+			fs.StringVar(&o.Secret, "secret", "", "enrollment secret")
+			fs.BoolVar(&o.Admin, "admin", false, "require the admin attribute in the certificate")
+			return o.run
+		},
+	},
+	"query-ledger": {
+		Usage:   "query-ledger",
+		Summary: "query the channel height and current block hash",
+		Run:     runQueryLedgerCmd,
+	},
+	"block": {
+		Usage:   "block <number|hash>",
+		Summary: "query and decode a block by number, or by hex hash with -hash",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &blockOptions{}
+			fs.BoolVar(&o.ByHash, "hash", false, "the argument is a hex encoded block hash")
+			return o.run
+		},
+	},
+	"tx": {
+		Usage:   "tx <txid>",
+		Summary: "query and decode a transaction",
+		NArgs:   1,
+		Run:     runTxCmd,
+	},
+	"export": {
+		Usage:   "export <file|->",
+		Summary: "append every transaction of the channel to a JSON Lines or CSV file",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &exportOptions{}
+			fs.StringVar(&o.Format, "format", ExportJSONLines, "export format: jsonl|csv")
+			fs.StringVar(&o.Checkpoint, "checkpoint", "", "store the last exported block in this file and resume after it")
+			fs.BoolVar(&o.Follow, "follow", false, "keep exporting new blocks until interrupted")
+			return o.run
+		},
+	},
+	"audit": {
+		Usage:   "audit <key> <file|->",
+		Summary: "write every change of a key with the client that made it to a JSON or CSV report",
+		NArgs:   2,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &auditOptions{}
+			fs.StringVar(&o.Format, "format", AuditJSON, "report format: json|csv")
+			return o.run
+		},
+	},
+	"snapshot": {
+		Usage:   "snapshot <file>",
+		Summary: "save all keys and values of example CC to a file",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &snapshotOptions{}
+			fs.IntVar(&o.PageSize, "page-size", DefaultSnapshotPageSize, "number of keys fetched by each query")
+			return o.run
+		},
+	},
+	"diff": {
+		Usage:   "diff <snapshot>",
+		Summary: "compare a snapshot with the live ledger, or with another snapshot given by -against",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &diffOptions{}
+			fs.StringVar(&o.Against, "against", "", "compare with this snapshot instead of the live ledger")
+			fs.IntVar(&o.PageSize, "page-size", DefaultSnapshotPageSize, "number of keys fetched by each query")
+			return o.run
+		},
+	},
+	"init-pvt": {
+		Usage:       "init-pvt",
+		Summary:     "install and instantiate example pvt CC with the collections of a definition file",
+		ChaincodeID: GenerateExamplePvtID(false),
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &initPvtOptions{}
+			fs.StringVar(&o.Collections, "collections", CollectionConfigPath, "YAML file defining the private data collections")
+			return o.run
+		},
+	},
+	"put-private": {
+		Usage:       "put-private <collection> <key> <value>",
+		Summary:     "set the value of a key of a private data collection",
+		NArgs:       3,
+		ChaincodeID: GenerateExamplePvtID(false),
+		Run:         runPutPrivateCmd,
+	},
+	"get-private": {
+		Usage:       "get-private <collection> <key>",
+		Summary:     "query the value of a key of a private data collection",
+		NArgs:       2,
+		ChaincodeID: GenerateExamplePvtID(false),
+		Run:         runGetPrivateCmd,
+	},
+	"delete-private": {
+		Usage:       "delete-private <collection> <key>",
+		Summary:     "delete a key of a private data collection",
+		NArgs:       2,
+		ChaincodeID: GenerateExamplePvtID(false),
+		Run:         runDeletePrivateCmd,
+	},
+	"verify-private": {
+		Usage:       "verify-private <collection> <key> <value>",
+		Summary:     "check a value against the hash of a key of a private data collection",
+		NArgs:       3,
+		ChaincodeID: GenerateExamplePvtID(false),
+		Run:         runVerifyPrivateCmd,
+	},
+	"batch-set": {
+		Usage:   "batch-set <csv file|->",
+		Summary: "set all key,value records of a CSV file with several transactions in flight",
+		NArgs:   1,
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &batchSetOptions{}
+			fs.IntVar(&o.InFlight, "inflight", DefaultBatchInFlight, "number of transactions in flight")
+			fs.IntVar(&o.MVCCRetries, "mvcc-retries", DefaultBatchMVCCRetries, "number of resubmissions of a key that hits an MVCC conflict")
+			fs.StringVar(&o.ReportPath, "report", "", "write the result of every key as CSV to this file")
+			return o.run
+		},
+	},
+	"serve": {
+		Usage:   "serve",
+		Summary: "serve the REST gateway",
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &serveOptions{}
+			fs.StringVar(&o.Addr, "addr", ":8080", "HTTP listen address")
+			return o.run
+		},
+	},
+	"watch": {
+		Usage:   "watch",
+		Summary: "print the chaincode events of example CC until interrupted",
+		Flags: func(fs *flag.FlagSet) cliRunFunc {
+			o := &watchOptions{}
+			fs.StringVar(&o.EventFilter, "filter", DefaultEventFilter, "regular expression matching the event names")
+			fs.StringVar(&o.Checkpoint, "checkpoint", "", "store the last event in this file and resume after it")
+			fs.DurationVar(&o.Reconnect, "reconnect-delay", DefaultReconnectDelay, "delay before reconnecting a lost event connection")
+			return o.run
+		},
+	},
+}
+
+// runCLI parses the command line, runs the selected command and returns the process exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitFailure
+	}
+
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command: %s\n", args[0])
+		printUsage(stderr)
+		return exitFailure
+	}
+
+	opts := &cliOptions{stdout: stdout}
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.ChannelID, "channel", channelID, "channel ID")
+	ccID := cmd.ChaincodeID
+	if ccID == "" {
+		ccID = GenerateExampleID(false)
+	}
+	fs.StringVar(&opts.ChaincodeID, "cc", ccID, "chaincode ID")
+	fs.StringVar(&opts.OrgName, "org", org1Name, "organization name")
+	fs.StringVar(&opts.UserName, "user", org1User, "user name")
+	fs.StringVar(&opts.Output, "output", outputTable, "output format: json|table")
+	fs.DurationVar(&opts.Timeout, "timeout", 0, "abandon the command after this duration (0 means no timeout)")
+	fs.IntVar(&opts.Attempts, "attempts", retry.DefaultAttempts, "number of retry attempts for each ledger call")
+	run := cmd.Run
+	if cmd.Flags != nil {
+		run = cmd.Flags(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: myFabric %s [flags]\n", cmd.Usage)
+		fs.PrintDefaults()
+	}
+	cmdArgs, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return exitFailure
+	}
+
+	if opts.Output != outputJSON && opts.Output != outputTable {
+		fmt.Fprintf(stderr, "invalid output format: %s\n", opts.Output)
+		return exitFailure
+	}
+	if len(cmdArgs) != cmd.NArgs {
+		fs.Usage()
+		return exitFailure
+	}
+
+	// Interrupting the command cancels the ledger calls in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	result, err := run(ctx, opts, cmdArgs)
+	code := exitCodeFor(err)
+	if err != nil {
+		writeError(stdout, stderr, opts.Output, code, err)
+		return code
+	}
+
+	if err := writeResult(stdout, opts.Output, result); err != nil {
+		fmt.Fprintf(stderr, "failed to write result: %s\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// parseFlags parses flags given before, between or after the positional args and returns
+// the positional args. Args following "--" are positional even if they start with "-".
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: myFabric <command> [flags] [args]")
+	fmt.Fprintln(w, "flags may also follow the args, use -- before an arg starting with -")
+	fmt.Fprintln(w, "commands:")
+
+	var names []string
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", cliCommands[name].Usage, cliCommands[name].Summary)
+	}
+	tw.Flush()
+}
+
+func writeResult(w io.Writer, output string, result cliResult) error {
+	if output == outputJSON {
+		return json.NewEncoder(w).Encode(Status{Code: exitOK, Message: result})
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, f := range result {
+		fmt.Fprintf(tw, "%s\t%v\n", f.Name, f.Value)
+	}
+	return tw.Flush()
+}
+
+func writeError(stdout, stderr io.Writer, output string, code int, err error) {
+	if output == outputJSON {
+		if e := json.NewEncoder(stdout).Encode(Status{Code: code, Message: err.Error()}); e == nil {
+			return
+		}
+	}
+	fmt.Fprintf(stderr, "Error: %s\n", err)
+}
+
+// exitCodeFor tells network errors apart from chaincode errors
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	switch LedgerErrorKindOf(err) {
+	case ErrNotFound, ErrChaincode, ErrEndorsement, ErrMVCCConflict, ErrInvalidTransaction, ErrForbidden:
+		return exitChaincodeError
+	case ErrTimeout, ErrConnection:
+		return exitNetworkError
+	}
+	return exitFailure
+}
+
+// connect creates the SDK and the shared channel client for the selected channel, org and user
+func connect(opts *cliOptions) error {
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to create new SDK")
+	}
+	mainSDK = sdk
+
+	org1ChannelClientContext = mainSDK.ChannelContext(opts.ChannelID, fabsdk.WithUser(opts.UserName), fabsdk.WithOrg(opts.OrgName))
+
+	chClient, err = channel.New(org1ChannelClientContext)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to create new channel client")
+	}
+	return nil
+}
+
+// initOptions are the flags of the init command
+type initOptions struct {
+	Manifest string
+	MultiOrg bool
+}
+
+// runner returns the runner preparing the network, the multi-org runner defaults to the org
+// channel unless another channel is given
+func (o *initOptions) runner(opts *cliOptions) (*Runner, error) {
+	r := NewWithExampleCC()
+	if o.MultiOrg {
+		r = NewMultiOrgWithExampleCC()
+	}
+	if !o.MultiOrg || opts.ChannelID != channelID {
+		r.ChannelID = opts.ChannelID
+	}
+	r.Org1Name = opts.OrgName
+	r.Org1User = opts.UserName
+	if o.Manifest != "" {
+		m, err := LoadNetworkManifest(o.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		r.Manifest = m
+	}
+	return r, nil
+}
+
+func (o *initOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	r, err := o.runner(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Prepare(); err != nil {
+		return nil, err
+	}
+	defer r.SDK().Close()
+
+	mainSDK = r.SDK()
+	mainTestSetup = r.TestSetup()
+	mainChaincodeID = r.ExampleChaincodeID()
+
+	result := cliResult{
+		{"channel", mainTestSetup.ChannelID},
+		{"org", mainTestSetup.OrgID},
+		{"chaincode", mainChaincodeID},
+	}
+	if r.MultiOrg {
+		orgNames := make([]string, len(r.OrgContexts()))
+		for i, orgCtx := range r.OrgContexts() {
+			orgNames[i] = orgCtx.OrgID
+		}
+		if opts.Output == outputJSON {
+			return append(result, cliField{"orgs", orgNames}), nil
+		}
+		return append(result, cliField{"orgs", strings.Join(orgNames, ",")}), nil
+	}
+	if r.Manifest == nil {
+		return result, nil
+	}
+	if opts.Output == outputJSON {
+		return append(result, cliField{"changes", r.Changes()}), nil
+	}
+	result = append(result, cliField{"changes", len(r.Changes())})
+	for i, change := range r.Changes() {
+		result = append(result, cliField{fmt.Sprintf("change %d", i+1), change})
+	}
+	return result, nil
+}
+
+// updateConfigOptions are the flags of the update-config command
+type updateConfigOptions struct {
+	BatchCount uint
+	MaxBytes   uint
+	PrefBytes  uint
+	BatchTime  time.Duration
+	AddOrg     string
+	Anchors    string
+	ACL        string
+	Policy     string
+	Signers    string
+	DryRun     bool
+}
+
+func (o *updateConfigOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	signerOrgs := splitList(o.Signers)
+	if len(signerOrgs) == 0 {
+		signerOrgs = []string{opts.OrgName}
+	}
+
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create new SDK")
+	}
+	defer sdk.Close()
+
+	signers := make([]*OrgContext, len(signerOrgs))
+	for i, orgName := range signerOrgs {
+		if signers[i], err = NewConfigSigner(sdk, orgName, AdminUser); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := FetchChannelConfig(signers[0].CtxProvider, opts.ChannelID, ordererEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyConfigFlags(config, o); err != nil {
+		return nil, err
+	}
+	update, err := config.Update()
+	if err != nil {
+		return nil, err
+	}
+
+	result := cliResult{{"channel", opts.ChannelID}, {"block", config.BlockNumber}}
+	if !o.DryRun {
+		blockNum, err := config.Submit(signers)
+		if err != nil {
+			return nil, err
+		}
+		result = cliResult{{"channel", opts.ChannelID}, {"block", blockNum}, {"signers", strings.Join(signerOrgs, ",")}}
+	}
+
+	paths := ConfigUpdatePaths(update)
+	if opts.Output == outputJSON {
+		return append(result, cliField{"changes", paths}), nil
+	}
+	result = append(result, cliField{"changes", len(paths)})
+	for i, path := range paths {
+		result = append(result, cliField{fmt.Sprintf("change %d", i+1), path})
+	}
+	return result, nil
+}
+
+// applyConfigFlags applies the changes selected by the flags of update-config
+func applyConfigFlags(config *ChannelConfig, o *updateConfigOptions) error {
+	if o.BatchCount > 0 || o.MaxBytes > 0 || o.PrefBytes > 0 {
+		if err := config.SetBatchSize(uint32(o.BatchCount), uint32(o.MaxBytes), uint32(o.PrefBytes)); err != nil {
+			return err
+		}
+	}
+	if o.BatchTime != 0 {
+		if err := config.SetBatchTimeout(o.BatchTime); err != nil {
+			return err
+		}
+	}
+	if o.AddOrg != "" {
+		parts := strings.SplitN(o.AddOrg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.Errorf("invalid -add-org [%s], expecting <msp id>=<msp dir>", o.AddOrg)
+		}
+		if err := config.AddOrg(parts[0], parts[1], splitList(o.Anchors)...); err != nil {
+			return err
+		}
+	}
+	for _, acl := range splitList(o.ACL) {
+		parts := strings.SplitN(acl, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return errors.Errorf("invalid ACL [%s], expecting <resource>=<policy ref>", acl)
+		}
+		if err := config.SetACL(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+	if o.Policy != "" {
+		parts := strings.SplitN(o.Policy, "=", 2)
+		i := strings.LastIndexByte(parts[0], '/')
+		if len(parts) != 2 || i < 0 || i == len(parts[0])-1 {
+			return errors.Errorf("invalid -policy [%s], expecting <group path>/<name>=<policy>", o.Policy)
+		}
+		if err := config.SetPolicy(parts[0][:i], parts[0][i+1:], parts[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated flag value, skipping empty elements
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// cryptogenOptions are the flags of the cryptogen command
+type cryptogenOptions struct {
+	Cryptogen string
+	OutPath   string
+	Clean     bool
+}
+
+func (o *cryptogenOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	config, err := cryptogen.LoadConfig(o.Cryptogen)
+	if err != nil {
+		return nil, err
+	}
+	if o.Clean {
+		if err := os.RemoveAll(o.OutPath); err != nil {
+			return nil, errors.Wrap(err, "removing crypto config directory failed")
+		}
+	}
+
+	orgDirs, err := cryptogen.Generate(config, o.OutPath)
+	if err != nil {
+		return nil, err
+	}
+	envFile, err := writeCAKeyEnv(config, o.OutPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := cliResult{{"dir", o.OutPath}, {"env", envFile}}
+	if opts.Output == outputJSON {
+		return append(result, cliField{"generated", orgDirs}), nil
+	}
+	for i, orgDir := range orgDirs {
+		result = append(result, cliField{fmt.Sprintf("org %d", i+1), orgDir})
+	}
+	return result, nil
+}
+
+// writeCAKeyEnv writes env.sh of the crypto config directory, exporting the key file of the
+// CA of every peer org the way the docker env expects it: <ORG>CA1_FABRIC_CA_SERVER_CA_KEYFILE
+func writeCAKeyEnv(config *cryptogen.Config, outDir string) (string, error) {
+	var env strings.Builder
+	env.WriteString("#!/bin/bash\n")
+	for _, org := range config.PeerOrgs {
+		keyFile, err := cryptogen.CAKeyFile(cryptogen.PeerOrgDir(outDir, org.Domain))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&env, "export %sCA1_FABRIC_CA_SERVER_CA_KEYFILE=/etc/hyperledger/fabric-ca-server-config/%s\n",
+			strings.ToUpper(org.Name), keyFile)
+	}
+
+	envFile := filepath.Join(outDir, "env.sh")
+	if err := ioutil.WriteFile(envFile, []byte(env.String()), 0755); err != nil {
+		return "", errors.Wrap(err, "writing env.sh failed")
+	}
+	return envFile, nil
+}
+
+// channelTxOptions are the flags of the channel-tx command
+type channelTxOptions struct {
+	Profile  string
+	Configtx string
+	OutPath  string
+}
+
+func (o *channelTxOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	profile, err := configtxgen.LoadProfile(o.Configtx, o.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	channelID := args[0]
+	tx, err := configtxgen.ChannelCreateTx(profile, channelID)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filepath.Join(o.OutPath, channelID+".tx")}
+	if err := ioutil.WriteFile(files[0], tx, 0644); err != nil {
+		return nil, errors.Wrap(err, "writing channel transaction failed")
+	}
+
+	for _, org := range profile.Application.Organizations {
+		if len(org.AnchorPeers) == 0 {
+			continue
+		}
+		tx, err := configtxgen.AnchorPeersTx(profile, channelID, org.Name)
+		if err != nil {
+			return nil, err
+		}
+		file := filepath.Join(o.OutPath, channelID+org.Name+"anchors.tx")
+		if err := ioutil.WriteFile(file, tx, 0644); err != nil {
+			return nil, errors.Wrap(err, "writing anchor peer update failed")
+		}
+		files = append(files, file)
+	}
+
+	result := cliResult{{"channel", channelID}, {"profile", o.Profile}}
+	if opts.Output == outputJSON {
+		return append(result, cliField{"files", files}), nil
+	}
+	for i, file := range files {
+		result = append(result, cliField{fmt.Sprintf("file %d", i+1), file})
+	}
+	return result, nil
+}
+
+// genesisBlockOptions are the flags of the genesis-block command
+type genesisBlockOptions struct {
+	Profile  string
+	Configtx string
+	OutPath  string
+}
+
+func (o *genesisBlockOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	profile, err := configtxgen.LoadProfile(o.Configtx, o.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := configtxgen.GenesisBlock(profile, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(o.OutPath, block, 0644); err != nil {
+		return nil, errors.Wrap(err, "writing genesis block failed")
+	}
+	return cliResult{{"channel", args[0]}, {"profile", o.Profile}, {"file", o.OutPath}}, nil
+}
+
+func runSetCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := SetKeyDataWithContext(ctx, chClient, opts.ChaincodeID, args[1], args[0], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"value", args[1]}, {"txid", txID}}, nil
+}
+
+func runGetCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	val, err := GetValueFromKeyWithContext(ctx, chClient, opts.ChaincodeID, args[0], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"value", val}}, nil
+}
+
+func runMoveCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := MoveKeyDataWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], args[2], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"from", args[0]}, {"to", args[1]}, {"amount", args[2]}, {"txid", txID}}, nil
+}
+
+func runTransferCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	legs, err := parseTransferLegs(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := TransferKeyDataWithContext(ctx, chClient, opts.ChaincodeID, legs, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"legs", len(legs)}, {"txid", txID}}, nil
+}
+
+// parseTransferLegs parses legs in the form from:to:amount,from:to:amount
+func parseTransferLegs(arg string) ([]TransferLeg, error) {
+	var legs []TransferLeg
+	for _, legArg := range strings.Split(arg, ",") {
+		parts := strings.Split(legArg, ":")
+		if len(parts) != 3 {
+			return nil, errors.Errorf("invalid transfer leg [%s], expecting from:to:amount", legArg)
+		}
+		amount, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid amount in transfer leg [%s]", legArg)
+		}
+		legs = append(legs, TransferLeg{From: parts[0], To: parts[1], Amount: amount})
+	}
+	return legs, nil
+}
+
+func runDeleteCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := DeleteKeyDataWithContext(ctx, chClient, opts.ChaincodeID, args[0], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"txid", txID}}, nil
+}
+
+func runOwnerCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	owner, err := GetKeyOwnerWithContext(ctx, chClient, opts.ChaincodeID, args[0], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"mspId", owner.MSPID}, {"subject", owner.Subject}}, nil
+}
+
+func runChownCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	owner := KeyOwner{MSPID: args[1], Subject: args[2]}
+	txID, err := ChangeKeyOwnerWithContext(ctx, chClient, opts.ChaincodeID, args[0], owner, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"mspId", owner.MSPID}, {"subject", owner.Subject}, {"txid", txID}}, nil
+}
+
+// initPvtOptions are the flags of the init-pvt command
+type initPvtOptions struct {
+	Collections string
+}
+
+func (o *initPvtOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	collConfigs, err := CollectionConfigsFromFile(o.Collections)
+	if err != nil {
+		return nil, err
+	}
+
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create new SDK")
+	}
+	defer sdk.Close()
+
+	if err := PrepareExamplePvtCC(sdk, fabsdk.WithUser(org1AdminUser), opts.OrgName, opts.ChannelID, opts.ChaincodeID, collConfigs...); err != nil {
+		return nil, err
+	}
+	return cliResult{{"channel", opts.ChannelID}, {"chaincode", opts.ChaincodeID}, {"collections", len(collConfigs)}}, nil
+}
+
+func runPutPrivateCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := PutPrivateDataWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], []byte(args[2]), opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"collection", args[0]}, {"key", args[1]}, {"txid", txID}}, nil
+}
+
+func runGetPrivateCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	value, err := GetPrivateDataWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"collection", args[0]}, {"key", args[1]}, {"value", string(value)}}, nil
+}
+
+func runDeletePrivateCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := DeletePrivateDataWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"collection", args[0]}, {"key", args[1]}, {"txid", txID}}, nil
+}
+
+func runVerifyPrivateCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	v, err := VerifyPrivateDataWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], []byte(args[2]), opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"collection", v.Collection}, {"key", v.Key}, {"hash", v.Hash}, {"match", v.Match}}, nil
+}
+
+func runPutDocCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	doc := &Document{DocType: args[0], Owner: args[1], ID: args[2], Data: json.RawMessage(args[3])}
+	txID, err := PutDocumentWithContext(ctx, chClient, opts.ChaincodeID, doc, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"type", doc.DocType}, {"owner", doc.Owner}, {"id", doc.ID}, {"txid", txID}}, nil
+}
+
+func runGetDocCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	doc, err := GetDocumentWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], args[2], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	if opts.Output == outputJSON {
+		return cliResult{{"document", doc}}, nil
+	}
+	result := cliResult{{"type", doc.DocType}, {"owner", doc.Owner}, {"id", doc.ID}, {"data", string(doc.Data)}}
+	if doc.Creator != nil {
+		result = append(result, cliField{"creator", doc.Creator.MSPID + " " + doc.Creator.Subject})
+	}
+	return result, nil
+}
+
+func runDeleteDocCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := DeleteDocumentWithContext(ctx, chClient, opts.ChaincodeID, args[0], args[1], args[2], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"type", args[0]}, {"owner", args[1]}, {"id", args[2]}, {"txid", txID}}, nil
+}
+
+// docsOptions are the flags of the docs command
+type docsOptions struct {
+	DocType  string
+	DocOwner string
+	Selector string
+	PageSize int
+	Bookmark string
+	All      bool
+}
+
+func (o *docsOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	q := DocumentQuery{
+		DocType:  o.DocType,
+		Owner:    o.DocOwner,
+		Selector: o.Selector,
+		PageSize: int32(o.PageSize),
+		Bookmark: o.Bookmark,
+	}
+	if q.DocType == "" && q.Selector == "" {
+		return nil, errors.New("either -type or -selector is required")
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	page := &DocumentPage{}
+	if o.All {
+		docs, err := QueryAllDocumentsWithContext(ctx, chClient, opts.ChaincodeID, q, opts.retryPolicy())
+		if err != nil {
+			return nil, err
+		}
+		page.Records = docs
+		page.Count = int32(len(docs))
+	} else {
+		var err error
+		if page, err = QueryDocumentsWithContext(ctx, chClient, opts.ChaincodeID, q, opts.retryPolicy()); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Output == outputJSON {
+		return cliResult{{"page", page}}, nil
+	}
+	result := cliResult{{"count", page.Count}, {"bookmark", page.Bookmark}}
+	for _, doc := range page.Records {
+		result = append(result, cliField{doc.DocType + "/" + doc.Owner + "/" + doc.ID, string(doc.Data)})
+	}
+	return result, nil
+}
+
+// invokeCCOptions are the flags of the invokecc command
+type invokeCCOptions struct {
+	Target string
+	Marker bool
+	Query  bool
+}
+
+func (o *invokeCCOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	var ccArgs []string
+	if err := json.Unmarshal([]byte(args[1]), &ccArgs); err != nil {
+		return nil, errors.Wrap(err, "invalid args, expecting a JSON array of strings")
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	target := InvokeTarget{ChaincodeID: args[0], ChannelID: o.Target, Args: ccArgs, Marker: o.Marker}
+	result, txID, err := InvokeChaincodeWithContext(ctx, chClient, opts.ChaincodeID, target, !o.Query, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{
+		{"chaincode", result.ChaincodeID},
+		{"channel", result.ChannelID},
+		{"status", result.Status},
+		{"message", result.Message},
+		{"payload", string(result.Payload)},
+		{"txid", txID},
+	}, nil
+}
+
+// allowCCOptions are the flags of the allow-cc command
+type allowCCOptions struct {
+	Target string
+	Remove bool
+}
+
+func (o *allowCCOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := SetInvokeAllowedWithContext(ctx, chClient, opts.ChaincodeID, args[0], o.Target, !o.Remove, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"chaincode", args[0]}, {"allowed", !o.Remove}, {"txid", txID}}, nil
+}
+
+func runAllowedCCCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	allowed, err := GetInvokeAllowListWithContext(ctx, chClient, opts.ChaincodeID, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	if opts.Output == outputJSON {
+		return cliResult{{"allowed", allowed}}, nil
+	}
+	result := cliResult{{"count", len(allowed)}}
+	for _, a := range allowed {
+		result = append(result, cliField{a.ChaincodeID, a.ChannelID})
+	}
+	return result, nil
+}
+
+func runEndorsementCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	endorsement, err := GetKeyEndorsementWithContext(ctx, chClient, opts.ChaincodeID, args[0], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	if opts.Output == outputJSON {
+		return cliResult{{"key", endorsement.Key}, {"orgs", endorsement.Orgs}}, nil
+	}
+	orgs := strings.Join(endorsement.Orgs, ",")
+	if orgs == "" {
+		orgs = "(chaincode policy)"
+	}
+	return cliResult{{"key", endorsement.Key}, {"orgs", orgs}}, nil
+}
+
+// setEndorsementOptions are the flags of the set-endorsement command
+type setEndorsementOptions struct {
+	Role string
+}
+
+func (o *setEndorsementOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if o.Role != EndorsementRoleMember && o.Role != EndorsementRolePeer {
+		return nil, errors.Errorf("invalid role [%s], expecting member or peer", o.Role)
+	}
+	var mspIDs []string
+	for _, mspID := range strings.Split(args[1], ",") {
+		if mspID = strings.TrimSpace(mspID); mspID != "" {
+			mspIDs = append(mspIDs, mspID)
+		}
+	}
+	if len(mspIDs) == 0 {
+		return nil, errors.New("expecting at least one MSP ID, use clear-endorsement to remove the policy")
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := SetKeyEndorsementWithContext(ctx, chClient, opts.ChaincodeID, args[0], mspIDs, o.Role, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"orgs", strings.Join(mspIDs, ",")}, {"role", o.Role}, {"txid", txID}}, nil
+}
+
+func runClearEndorsementCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	txID, err := SetKeyEndorsementWithContext(ctx, chClient, opts.ChaincodeID, args[0], nil, "", opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"txid", txID}}, nil
+}
+
+func runMintCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	return runChangeSupplyCmd(ctx, opts, args, true)
+}
+
+func runBurnCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	return runChangeSupplyCmd(ctx, opts, args, false)
+}
+
+// runChangeSupplyCmd mints or burns, the amount is given in the decimals of the token
+func runChangeSupplyCmd(ctx context.Context, opts *cliOptions, args []string, mint bool) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	info, err := GetTokenInfoWithContext(ctx, chClient, opts.ChaincodeID, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	amount, err := ParseTokenAmount(args[1], info.Decimals)
+	if err != nil {
+		return nil, err
+	}
+
+	var txID fabAPI.TransactionID
+	if mint {
+		txID, err = MintWithContext(ctx, chClient, opts.ChaincodeID, args[0], amount, opts.retryPolicy())
+	} else {
+		txID, err = BurnWithContext(ctx, chClient, opts.ChaincodeID, args[0], amount, opts.retryPolicy())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"amount", FormatTokenAmount(amount, info.Decimals)}, {"txid", txID}}, nil
+}
+
+func runTotalSupplyCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	info, err := GetTokenInfoWithContext(ctx, chClient, opts.ChaincodeID, opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{
+		{"issuer", info.Issuer},
+		{"decimals", info.Decimals},
+		{"cap", FormatTokenAmount(info.Cap, info.Decimals)},
+		{"totalSupply", FormatTokenAmount(info.TotalSupply, info.Decimals)},
+	}, nil
+}
+
+// registerOptions are the flags of the register command
+type registerOptions struct {
+	Secret      string
+	Affiliation string
+	Admin       bool
+}
+
+func (o *registerOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create new SDK")
+	}
+	defer sdk.Close()
+
+	secret, err := RegisterUser(sdk, opts.OrgName, UserRegistration{
+		Name:        args[0],
+		Secret:      o.Secret,
+		Affiliation: o.Affiliation,
+		Admin:       o.Admin,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"name", args[0]}, {"org", opts.OrgName}, {"secret", secret}, {"admin", o.Admin}}, nil
+}
+
+// enrollOptions are the flags of the enroll command
+type enrollOptions struct {
+	Secret string
+	Admin  bool
+}
+
+func (o *enrollOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if o.Secret == "" {
+		return nil, errors.New("-secret is required")
+	}
+
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create new SDK")
+	}
+	defer sdk.Close()
+
+	if err := EnrollUser(sdk, opts.OrgName, args[0], o.Secret, o.Admin); err != nil {
+		return nil, err
+	}
+	return cliResult{{"name", args[0]}, {"org", opts.OrgName}}, nil
+}
+
+func runQueryLedgerCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	explorer, err := NewExplorer(org1ChannelClientContext)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := explorer.ChainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{
+		{"channel", opts.ChannelID},
+		{"height", info.Height},
+		{"currentBlockHash", info.CurrentBlockHash},
+		{"previousBlockHash", info.PreviousBlockHash},
+		{"endorser", info.Endorser},
+	}, nil
+}
+
+// blockOptions are the flags of the block command
+type blockOptions struct {
+	ByHash bool
+}
+
+func (o *blockOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	var number uint64
+	if !o.ByHash {
+		n, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid block number [%s]", args[0])
+		}
+		number = n
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	explorer, err := NewExplorer(org1ChannelClientContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var block *BlockInfo
+	if o.ByHash {
+		block, err = explorer.BlockByHash(ctx, args[0])
+	} else {
+		block, err = explorer.BlockByNumber(ctx, number)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Output == outputJSON {
+		return cliResult{{"block", block}}, nil
+	}
+	result := cliResult{
+		{"number", block.Number},
+		{"hash", block.Hash},
+		{"previousHash", block.PreviousHash},
+		{"dataHash", block.DataHash},
+		{"transactions", len(block.Transactions)},
+	}
+	for _, tx := range block.Transactions {
+		result = append(result, cliField{tx.TxID, txSummary(tx)})
+	}
+	return result, nil
+}
+
+func runTxCmd(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	explorer, err := NewExplorer(org1ChannelClientContext)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := explorer.Transaction(ctx, fabAPI.TransactionID(args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Output == outputJSON {
+		return cliResult{{"transaction", tx}}, nil
+	}
+	result := cliResult{
+		{"txid", tx.TxID},
+		{"type", tx.Type},
+		{"channel", tx.ChannelID},
+		{"timestamp", tx.Timestamp.Format(time.RFC3339)},
+		{"blockNumber", tx.BlockNumber},
+		{"validationCode", tx.ValidationCode},
+	}
+	if tx.Creator != nil {
+		result = append(result, cliField{"creator", tx.Creator.MSPID + " " + tx.Creator.Subject})
+	}
+	for _, action := range tx.Actions {
+		result = append(result,
+			cliField{"chaincode", action.Chaincode + ":" + action.ChaincodeVersion},
+			cliField{"function", action.Function},
+			cliField{"args", strings.Join(action.Args, " ")},
+		)
+		for _, rws := range action.ReadWriteSets {
+			for _, r := range rws.Reads {
+				result = append(result, cliField{"read", rws.Namespace + "/" + r.Key})
+			}
+			for _, w := range rws.Writes {
+				if w.IsDelete {
+					result = append(result, cliField{"delete", rws.Namespace + "/" + w.Key})
+				} else {
+					result = append(result, cliField{"write", rws.Namespace + "/" + w.Key + " = " + w.Value})
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// txSummary describes a transaction on a single table row
+func txSummary(tx *TxInfo) string {
+	summary := tx.Type + " " + tx.ValidationCode
+	for _, action := range tx.Actions {
+		summary += " " + action.Chaincode + "." + action.Function + "(" + strings.Join(action.Args, ",") + ")"
+	}
+	return summary
+}
+
+// serveOptions are the flags of the serve command
+type serveOptions struct {
+	Addr string
+}
+
+func (o *serveOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	bc, err := NewBlockchain(opts.ChaincodeID)
+	if err != nil {
+		return nil, err
+	}
+	bc.RetryPolicy = opts.retryPolicy()
+
+	logger.Infof("REST gateway listening on %s", o.Addr)
+	if err := bc.ListenAndServe(ctx, o.Addr); err != nil {
+		return nil, errors.WithMessage(err, "REST gateway failed")
+	}
+	return cliResult{{"addr", o.Addr}}, nil
+}
+
+// watchOptions are the flags of the watch command
+type watchOptions struct {
+	EventFilter string
+	Checkpoint  string
+	Reconnect   time.Duration
+}
+
+func (o *watchOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	watcher, err := NewChaincodeEventWatcher(org1ChannelClientContext, opts.ChaincodeID, o.EventFilter)
+	if err != nil {
+		return nil, err
+	}
+	watcher.CheckpointPath = o.Checkpoint
+	watcher.ReconnectDelay = o.Reconnect
+
+	count := 0
+	err = watcher.Watch(ctx, func(ev *fabAPI.CCEvent) error {
+		count++
+		return writeEvent(opts.stdout, opts.Output, ev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"events", count}}, nil
+}
+
+// writeEvent prints a chaincode event as a JSON line or a table row
+func writeEvent(w io.Writer, output string, ev *fabAPI.CCEvent) error {
+	if output == outputJSON {
+		return json.NewEncoder(w).Encode(cliResult{
+			{"blockNumber", ev.BlockNumber},
+			{"txid", ev.TxID},
+			{"event", ev.EventName},
+			{"payload", string(ev.Payload)},
+			{"source", ev.SourceURL},
+		})
+	}
+	_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", ev.BlockNumber, ev.TxID, ev.EventName, ev.Payload)
+	return err
+}
+
+// exportOptions are the flags of the export command
+type exportOptions struct {
+	Format     string
+	Checkpoint string
+	Follow     bool
+}
+
+func (o *exportOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	out := opts.stdout
+	newFile := o.Checkpoint == ""
+	if args[0] != "-" {
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening export file failed")
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, errors.Wrap(err, "opening export file failed")
+		}
+		newFile = fi.Size() == 0
+		out = f
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	exporter, err := NewLedgerExporter(org1ChannelClientContext, out, o.Format)
+	if err != nil {
+		return nil, err
+	}
+	exporter.CheckpointPath = o.Checkpoint
+	if newFile {
+		if err := exporter.WriteCSVHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Follow {
+		if err := exporter.Follow(ctx); err != nil {
+			return nil, err
+		}
+		return cliResult{{"file", args[0]}}, nil
+	}
+
+	blocks, err := exporter.Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cliResult{{"file", args[0]}, {"blocks", blocks}}, nil
+}
+
+// auditOptions are the flags of the audit command
+type auditOptions struct {
+	Format string
+}
+
+func (o *auditOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if o.Format != AuditJSON && o.Format != AuditCSV {
+		return nil, errors.Errorf("invalid audit report format [%s]", o.Format)
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	explorer, err := NewExplorer(org1ChannelClientContext)
+	if err != nil {
+		return nil, err
+	}
+	report, err := AuditKey(ctx, chClient, explorer, opts.ChannelID, opts.ChaincodeID, args[0], opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	out := opts.stdout
+	if args[1] != "-" {
+		f, err := os.Create(args[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "creating audit report failed")
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := WriteAuditReport(out, o.Format, report); err != nil {
+		return nil, err
+	}
+	return cliResult{{"key", args[0]}, {"file", args[1]}, {"changes", len(report.Records)}}, nil
+}
+
+// snapshotOptions are the flags of the snapshot command
+type snapshotOptions struct {
+	PageSize int
+}
+
+func (o *snapshotOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	snapshot, err := TakeSnapshot(ctx, chClient, opts.ChannelID, opts.ChaincodeID, int32(o.PageSize), opts.retryPolicy())
+	if err != nil {
+		return nil, err
+	}
+	if err := SaveSnapshot(args[0], snapshot); err != nil {
+		return nil, err
+	}
+	return cliResult{{"file", args[0]}, {"keys", len(snapshot.Records)}, {"takenAt", snapshot.TakenAt.Format(time.RFC3339)}}, nil
+}
+
+// diffOptions are the flags of the diff command
+type diffOptions struct {
+	Against  string
+	PageSize int
+}
+
+func (o *diffOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	from, err := LoadSnapshot(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var to *Snapshot
+	if o.Against != "" {
+		if to, err = LoadSnapshot(o.Against); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := connect(opts); err != nil {
+			return nil, err
+		}
+		defer mainSDK.Close()
+
+		if to, err = TakeSnapshot(ctx, chClient, opts.ChannelID, opts.ChaincodeID, int32(o.PageSize), opts.retryPolicy()); err != nil {
+			return nil, err
+		}
+	}
+
+	diff := DiffSnapshots(from, to)
+	if opts.Output == outputJSON {
+		return cliResult{{"diff", diff}}, nil
+	}
+
+	result := cliResult{{"added", len(diff.Added)}, {"removed", len(diff.Removed)}, {"changed", len(diff.Changed)}}
+	for _, r := range diff.Added {
+		result = append(result, cliField{"+ " + r.Key, r.Value})
+	}
+	for _, r := range diff.Removed {
+		result = append(result, cliField{"- " + r.Key, r.Value})
+	}
+	for _, c := range diff.Changed {
+		result = append(result, cliField{"~ " + c.Key, c.OldValue + " -> " + c.NewValue})
+	}
+	return result, nil
+}
+
+// batchSetOptions are the flags of the batch-set command
+type batchSetOptions struct {
+	InFlight    int
+	MVCCRetries int
+	ReportPath  string
+}
+
+func (o *batchSetOptions) run(ctx context.Context, opts *cliOptions, args []string) (cliResult, error) {
+	in := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "opening input file failed")
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var report *csv.Writer
+	if o.ReportPath != "" {
+		f, err := os.Create(o.ReportPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating report file failed")
+		}
+		defer f.Close()
+		report = csv.NewWriter(f)
+		defer report.Flush()
+	}
+
+	if err := connect(opts); err != nil {
+		return nil, err
+	}
+	defer mainSDK.Close()
+
+	submitter, err := NewAsyncSubmitter(org1ChannelClientContext, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer submitter.Close()
+
+	writer := NewBatchWriter(submitter, opts.ChaincodeID)
+	writer.InFlight = o.InFlight
+	writer.MVCCRetries = o.MVCCRetries
+	writer.RetryPolicy = opts.retryPolicy()
+
+	pairs := make(chan KeyValue)
+	results := make(chan BatchResult)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(pairs)
+		readErr <- readKeyValues(ctx, in, pairs)
+	}()
+
+	summaryCh := make(chan BatchSummary, 1)
+	go func() {
+		summaryCh <- writer.Write(ctx, pairs, results)
+		close(results)
+	}()
+
+	var failed []cliResult
+	for res := range results {
+		if res.Err != nil {
+			failed = append(failed, cliResult{{"key", res.Key}, {"error", res.Err.Error()}})
+		}
+		if report != nil {
+			errMsg := ""
+			if res.Err != nil {
+				errMsg = res.Err.Error()
+			}
+			if err := report.Write([]string{res.Key, string(res.TxID), strconv.Itoa(res.Attempts), errMsg}); err != nil {
+				logger.Warnf("failed to write report: %s", err)
+			}
+		}
+	}
+	summary := <-summaryCh
+
+	if err := <-readErr; err != nil {
+		return nil, err
+	}
+
+	result := cliResult{
+		{"total", summary.Total},
+		{"succeeded", summary.Succeeded},
+		{"failed", summary.Failed},
+		{"retries", summary.Retries},
+		{"elapsed", summary.Elapsed.String()},
+		{"txPerSecond", fmt.Sprintf("%.2f", summary.TxPerSecond)},
+	}
+	if opts.Output == outputJSON {
+		result = append(result, cliField{"failures", failed})
+	}
+	return result, nil
+}
+
+// readKeyValues sends the key,value records of a CSV stream to pairs
+func readKeyValues(ctx context.Context, in io.Reader, pairs chan<- KeyValue) error {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = 2
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading input failed")
+		}
+
+		select {
+		case pairs <- KeyValue{Key: record[0], Value: record[1]}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		profile string
+		want    []string
+	}{
+		{"flags first", []string{"-profile", "p", "mychannel"}, "p", []string{"mychannel"}},
+		{"flags last", []string{"mychannel", "-profile", "p"}, "p", []string{"mychannel"}},
+		{"flags between", []string{"a", "-profile=p", "b"}, "p", []string{"a", "b"}},
+		{"no flags", []string{"a", "b"}, "", []string{"a", "b"}},
+		{"dash arg", []string{"a", "-", "-profile", "p"}, "p", []string{"a", "-"}},
+		{"args after --", []string{"a", "--", "-5", "-profile"}, "", []string{"a", "-5", "-profile"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			profile := fs.String("profile", "", "")
+			args, err := parseFlags(fs, tc.args)
+			if err != nil {
+				t.Fatalf("parseFlags failed: %s", err)
+			}
+			if !reflect.DeepEqual(args, tc.want) {
+				t.Fatalf("expected args %v, got %v", tc.want, args)
+			}
+			if *profile != tc.profile {
+				t.Fatalf("expected profile [%s], got [%s]", tc.profile, *profile)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if _, err := parseFlags(fs, []string{"a", "-unknown"}); err == nil {
+		t.Fatal("expected an unknown flag after the args to fail")
+	}
+}
+
+func TestRunCLIUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		stderr string
+	}{
+		{"no command", nil, "usage: myFabric <command>"},
+		{"unknown command", []string{"nope"}, "unknown command: nope"},
+		{"missing arg", []string{"get"}, "usage: myFabric get <key>"},
+		{"extra arg", []string{"get", "a", "b"}, "usage: myFabric get <key>"},
+		{"flag after arg", []string{"get", "a", "-output", "xml"}, "invalid output format: xml"},
+		{"flag of other command", []string{"get", "a", "-profile", "p"}, "flag provided but not defined: -profile"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCLI(tc.args, &stdout, &stderr); code != exitFailure {
+				t.Fatalf("expected exit code %d, got %d", exitFailure, code)
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) {
+				t.Fatalf("expected stderr to contain [%s], got [%s]", tc.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestInitRunner(t *testing.T) {
+	tests := []struct {
+		name      string
+		multiOrg  bool
+		channelID string
+		want      string
+	}{
+		{name: "single org", channelID: channelID, want: channelID},
+		{name: "single org other channel", channelID: "otherchannel", want: "otherchannel"},
+		{name: "multi-org", multiOrg: true, channelID: channelID, want: orgChannelID},
+		{name: "multi-org other channel", multiOrg: true, channelID: "otherchannel", want: "otherchannel"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &initOptions{MultiOrg: tc.multiOrg}
+			r, err := o.runner(&cliOptions{ChannelID: tc.channelID, OrgName: org1Name, UserName: org1User})
+			if err != nil {
+				t.Fatalf("creating runner failed: %s", err)
+			}
+			if r.ChannelID != tc.want {
+				t.Fatalf("expected channel [%s], got [%s]", tc.want, r.ChannelID)
+			}
+			if r.MultiOrg != tc.multiOrg {
+				t.Fatalf("expected multi-org %t, got %t", tc.multiOrg, r.MultiOrg)
+			}
+		})
+	}
+}
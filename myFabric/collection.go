@@ -0,0 +1,117 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/common/cauthdsl"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// CollectionConfigPath is the relative path to the collection definitions of example pvt CC
+var CollectionConfigPath = "fixtures/config/collections.yaml"
+
+// CollectionDefinition is a private data collection as written in a collection definition file
+type CollectionDefinition struct {
+	Name string `yaml:"name"`
+	// Policy selects the member orgs of the collection, e.g. OR('Org1MSP.member')
+	Policy            string `yaml:"policy"`
+	RequiredPeerCount int32  `yaml:"requiredPeerCount"`
+	MaxPeerCount      int32  `yaml:"maxPeerCount"`
+	// BlockToLive is the number of blocks after which the private data is purged, 0 keeps it forever
+	BlockToLive    uint64 `yaml:"blockToLive"`
+	MemberOnlyRead bool   `yaml:"memberOnlyRead"`
+}
+
+// collectionDefinitions is the layout of a collection definition file
+type collectionDefinitions struct {
+	Collections []CollectionDefinition `yaml:"collections"`
+}
+
+// LoadCollectionDefinitions reads the collection definitions of a YAML file
+func LoadCollectionDefinitions(path string) ([]CollectionDefinition, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading collection definitions [%s] failed", path)
+	}
+
+	defs := &collectionDefinitions{}
+	if err := yaml.UnmarshalStrict(data, defs); err != nil {
+		return nil, errors.Wrapf(err, "decoding collection definitions [%s] failed", path)
+	}
+	return defs.Collections, nil
+}
+
+// NewCollectionConfig builds the collection config passed to chaincode instantiate and upgrade
+func NewCollectionConfig(def CollectionDefinition) (*cb.CollectionConfig, error) {
+	if def.Name == "" {
+		return nil, errors.New("collection name is required")
+	}
+	if def.RequiredPeerCount < 0 {
+		return nil, errors.Errorf("requiredPeerCount of collection [%s] must not be negative", def.Name)
+	}
+	if def.MaxPeerCount < def.RequiredPeerCount {
+		return nil, errors.Errorf("maxPeerCount of collection [%s] must not be less than requiredPeerCount", def.Name)
+	}
+
+	policy, err := cauthdsl.FromString(def.Policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid member orgs policy [%s] of collection [%s]", def.Policy, def.Name)
+	}
+
+	return &cb.CollectionConfig{
+		Payload: &cb.CollectionConfig_StaticCollectionConfig{
+			StaticCollectionConfig: &cb.StaticCollectionConfig{
+				Name: def.Name,
+				MemberOrgsPolicy: &cb.CollectionPolicyConfig{
+					Payload: &cb.CollectionPolicyConfig_SignaturePolicy{
+						SignaturePolicy: policy,
+					},
+				},
+				RequiredPeerCount: def.RequiredPeerCount,
+				MaximumPeerCount:  def.MaxPeerCount,
+				BlockToLive:       def.BlockToLive,
+				MemberOnlyRead:    def.MemberOnlyRead,
+			},
+		},
+	}, nil
+}
+
+// NewCollectionConfigs builds the collection configs of several definitions, the collection
+// names must be unique
+func NewCollectionConfigs(defs []CollectionDefinition) ([]*cb.CollectionConfig, error) {
+	names := make(map[string]bool, len(defs))
+	configs := make([]*cb.CollectionConfig, 0, len(defs))
+	for _, def := range defs {
+		if names[def.Name] {
+			return nil, errors.Errorf("duplicate collection [%s]", def.Name)
+		}
+		names[def.Name] = true
+
+		config, err := NewCollectionConfig(def)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// CollectionConfigsFromFile builds the collection configs defined in a YAML file
+func CollectionConfigsFromFile(path string) ([]*cb.CollectionConfig, error) {
+	defs, err := LoadCollectionDefinitions(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, errors.Errorf("no collections defined in [%s]", path)
+	}
+	return NewCollectionConfigs(defs)
+}
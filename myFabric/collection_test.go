@@ -0,0 +1,126 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectionConfigsFromFile(t *testing.T) {
+	configs, err := CollectionConfigsFromFile(CollectionConfigPath)
+	if err != nil {
+		t.Fatalf("building collection configs failed: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		orgs        int
+		required    int32
+		max         int32
+		blockToLive uint64
+		memberOnly  bool
+	}{
+		{"collection1", 1, 0, 1, 1000, true},
+		{"collection2", 2, 1, 2, 0, false},
+	}
+	if len(configs) != len(tests) {
+		t.Fatalf("expected %d collections, got %d", len(tests), len(configs))
+	}
+	for i, tc := range tests {
+		c := configs[i].GetStaticCollectionConfig()
+		if c == nil {
+			t.Fatalf("expected a static collection config for %s", tc.name)
+		}
+		if c.Name != tc.name || c.RequiredPeerCount != tc.required || c.MaximumPeerCount != tc.max ||
+			c.BlockToLive != tc.blockToLive || c.MemberOnlyRead != tc.memberOnly {
+			t.Errorf("unexpected config of %s: %+v", tc.name, c)
+		}
+		policy := c.MemberOrgsPolicy.GetSignaturePolicy()
+		if policy == nil || len(policy.Identities) != tc.orgs {
+			t.Errorf("expected a signature policy of %d orgs for %s, got %v", tc.orgs, tc.name, policy)
+		}
+	}
+}
+
+func TestNewCollectionConfig(t *testing.T) {
+	valid := CollectionDefinition{Name: "c", Policy: "OR('Org1MSP.member')", RequiredPeerCount: 1, MaxPeerCount: 2}
+	tests := []struct {
+		name    string
+		change  func(def *CollectionDefinition)
+		errText string
+	}{
+		{"valid", func(*CollectionDefinition) {}, ""},
+		{"no peers", func(def *CollectionDefinition) { def.RequiredPeerCount, def.MaxPeerCount = 0, 0 }, ""},
+		{"missing name", func(def *CollectionDefinition) { def.Name = "" }, "collection name is required"},
+		{"negative required count", func(def *CollectionDefinition) { def.RequiredPeerCount = -1 }, "must not be negative"},
+		{"negative counts", func(def *CollectionDefinition) { def.RequiredPeerCount, def.MaxPeerCount = -2, -1 }, "must not be negative"},
+		{"max less than required", func(def *CollectionDefinition) { def.MaxPeerCount = 0 }, "must not be less than requiredPeerCount"},
+		{"bad policy", func(def *CollectionDefinition) { def.Policy = "OR('Org1MSP.member'" }, "invalid member orgs policy"},
+		{"unknown role", func(def *CollectionDefinition) { def.Policy = "OR('Org1MSP.owner')" }, "invalid member orgs policy"},
+		{"empty policy", func(def *CollectionDefinition) { def.Policy = "" }, "invalid member orgs policy"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			def := valid
+			tc.change(&def)
+			config, err := NewCollectionConfig(def)
+			if tc.errText == "" {
+				if err != nil || config.GetStaticCollectionConfig().Name != def.Name {
+					t.Fatalf("expected config of %s, got %v: %v", def.Name, config, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errText) {
+				t.Fatalf("expected error containing [%s], got %v", tc.errText, err)
+			}
+		})
+	}
+}
+
+func TestCollectionConfigsFromFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		errText string
+	}{
+		{"duplicate names", "collections:\n- {name: c, policy: \"OR('Org1MSP.member')\"}\n- {name: c, policy: \"OR('Org2MSP.member')\"}\n", "duplicate collection [c]"},
+		{"negative count", "collections:\n- {name: c, policy: \"OR('Org1MSP.member')\", requiredPeerCount: -1}\n", "must not be negative"},
+		{"max less than required", "collections:\n- {name: c, policy: \"OR('Org1MSP.member')\", requiredPeerCount: 2, maxPeerCount: 1}\n", "must not be less than requiredPeerCount"},
+		{"bad policy", "collections:\n- {name: c, policy: \"AND(\"}\n", "invalid member orgs policy"},
+		{"unknown field", "collections:\n- {name: c, policy: \"OR('Org1MSP.member')\", maxPeers: 1}\n", "decoding collection definitions"},
+		{"no collections", "collections: []\n", "no collections defined"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "collections.yaml")
+			if err := ioutil.WriteFile(path, []byte(tc.yaml), 0644); err != nil {
+				t.Fatalf("writing definitions failed: %s", err)
+			}
+			if _, err := CollectionConfigsFromFile(path); err == nil || !strings.Contains(err.Error(), tc.errText) {
+				t.Fatalf("expected error containing [%s], got %v", tc.errText, err)
+			}
+		})
+	}
+
+	if _, err := CollectionConfigsFromFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected a missing file to fail")
+	}
+}
+
+// TestExamplePvtCCValueKey pins the transient field of private values to the one example pvt CC reads
+func TestExamplePvtCCValueKey(t *testing.T) {
+	key, ok := chaincodeConstants(t, "pvtchaincode/example_pvt_cc.go")["transientValueKey"]
+	if !ok {
+		t.Fatal("example pvt CC declares no transientValueKey")
+	}
+	if key != examplePvtCCValueKey {
+		t.Fatalf("expected transientValueKey [%s] of example pvt CC to equal examplePvtCCValueKey [%s]", key, examplePvtCCValueKey)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package configtxgen
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// TopLevel is the part of configtx.yaml read by the generator. Only the profiles are used,
+// the other sections are usually referenced by profiles through YAML anchors.
+type TopLevel struct {
+	Profiles map[string]*Profile `yaml:"Profiles"`
+}
+
+// Profile is a profile of configtx.yaml. A channel profile names a consortium and the
+// application orgs, an orderer genesis profile has orderer and consortiums sections.
+type Profile struct {
+	Consortium   string                 `yaml:"Consortium"`
+	Application  *Application           `yaml:"Application"`
+	Orderer      *Orderer               `yaml:"Orderer"`
+	Consortiums  map[string]*Consortium `yaml:"Consortiums"`
+	Capabilities map[string]bool        `yaml:"Capabilities"`
+	Policies     map[string]*Policy     `yaml:"Policies"`
+}
+
+// Policy is a policy of configtx.yaml, Type is Signature or ImplicitMeta
+type Policy struct {
+	Type string `yaml:"Type"`
+	Rule string `yaml:"Rule"`
+}
+
+// Consortium is a consortium of an orderer genesis profile
+type Consortium struct {
+	Organizations []*Organization `yaml:"Organizations"`
+}
+
+// Application is the application section of a channel profile
+type Application struct {
+	Organizations []*Organization    `yaml:"Organizations"`
+	Capabilities  map[string]bool    `yaml:"Capabilities"`
+	Policies      map[string]*Policy `yaml:"Policies"`
+	ACLs          map[string]string  `yaml:"ACLs"`
+}
+
+// Organization is an org of configtx.yaml. MSPDir is made absolute when the profile is loaded.
+type Organization struct {
+	Name        string             `yaml:"Name"`
+	ID          string             `yaml:"ID"`
+	MSPDir      string             `yaml:"MSPDir"`
+	Policies    map[string]*Policy `yaml:"Policies"`
+	AnchorPeers []*AnchorPeer      `yaml:"AnchorPeers"`
+}
+
+// AnchorPeer is an anchor peer of an application org
+type AnchorPeer struct {
+	Host string `yaml:"Host"`
+	Port int    `yaml:"Port"`
+}
+
+// Orderer is the orderer section of an orderer genesis profile
+type Orderer struct {
+	OrdererType   string             `yaml:"OrdererType"`
+	Addresses     []string           `yaml:"Addresses"`
+	BatchTimeout  time.Duration      `yaml:"BatchTimeout"`
+	BatchSize     BatchSize          `yaml:"BatchSize"`
+	Kafka         Kafka              `yaml:"Kafka"`
+	MaxChannels   uint64             `yaml:"MaxChannels"`
+	Organizations []*Organization    `yaml:"Organizations"`
+	Policies      map[string]*Policy `yaml:"Policies"`
+	Capabilities  map[string]bool    `yaml:"Capabilities"`
+}
+
+// BatchSize is the batch size of the orderer, byte counts may be given like 98 MB
+type BatchSize struct {
+	MaxMessageCount   uint32   `yaml:"MaxMessageCount"`
+	AbsoluteMaxBytes  ByteSize `yaml:"AbsoluteMaxBytes"`
+	PreferredMaxBytes ByteSize `yaml:"PreferredMaxBytes"`
+}
+
+// Kafka holds the brokers of a kafka orderer
+type Kafka struct {
+	Brokers []string `yaml:"Brokers"`
+}
+
+// ByteSize is a byte count given as a number or with a KB, MB or GB suffix
+type ByteSize uint32
+
+// UnmarshalYAML parses a byte count like 512 KB
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	multiplier := uint64(1)
+	value := strings.TrimSpace(strings.ToUpper(s))
+	for suffix, m := range map[string]uint64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(value, suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, suffix)), m
+			break
+		}
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || n*multiplier > 1<<32-1 {
+		return errors.Errorf("invalid byte size [%s]", s)
+	}
+	*b = ByteSize(n * multiplier)
+	return nil
+}
+
+// LoadProfile reads a profile of configtx.yaml. MSP directories of its orgs are relative to
+// the directory of configtx.yaml.
+func LoadProfile(configtxPath, profileName string) (*Profile, error) {
+	data, err := ioutil.ReadFile(configtxPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading configtx [%s] failed", configtxPath)
+	}
+	topLevel := &TopLevel{}
+	if err := yaml.Unmarshal(data, topLevel); err != nil {
+		return nil, errors.Wrapf(err, "parsing configtx [%s] failed", configtxPath)
+	}
+
+	profile, ok := topLevel.Profiles[profileName]
+	if !ok || profile == nil {
+		return nil, errors.Errorf("profile [%s] not found in configtx [%s]", profileName, configtxPath)
+	}
+
+	baseDir, err := filepath.Abs(filepath.Dir(configtxPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving configtx directory failed")
+	}
+	for _, org := range profile.organizations() {
+		if org.Name == "" || org.ID == "" || org.MSPDir == "" {
+			return nil, errors.Errorf("org of profile [%s] needs a name, ID and MSPDir", profileName)
+		}
+		if !filepath.IsAbs(org.MSPDir) {
+			org.MSPDir = filepath.Join(baseDir, org.MSPDir)
+		}
+	}
+	if profile.Orderer != nil && profile.Orderer.OrdererType == "" {
+		profile.Orderer.OrdererType = "solo"
+	}
+	return profile, nil
+}
+
+// organizations returns all orgs of the profile
+func (p *Profile) organizations() []*Organization {
+	var orgs []*Organization
+	if p.Application != nil {
+		orgs = append(orgs, p.Application.Organizations...)
+	}
+	if p.Orderer != nil {
+		orgs = append(orgs, p.Orderer.Organizations...)
+	}
+	for _, consortium := range p.Consortiums {
+		if consortium != nil {
+			orgs = append(orgs, consortium.Organizations...)
+		}
+	}
+	return orgs
+}
+
+// ApplicationOrg returns the application org of the profile with the given name, nil if
+// there is none
+func (p *Profile) ApplicationOrg(name string) *Organization {
+	if p.Application == nil {
+		return nil
+	}
+	for _, org := range p.Application.Organizations {
+		if org.Name == name {
+			return org
+		}
+	}
+	return nil
+}
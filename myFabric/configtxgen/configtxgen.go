@@ -0,0 +1,150 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package configtxgen generates channel creation transactions, anchor peer updates and
+// orderer genesis blocks from the profiles of configtx.yaml and the MSP directories of the
+// orgs, the way the configtxgen tool of Fabric does.
+package configtxgen
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+	"github.com/pkg/errors"
+)
+
+// ChannelCreateTx returns the transaction that creates a channel of the given name from a
+// channel profile, the content of a file like mychannel.tx
+func ChannelCreateTx(profile *Profile, channelID string) ([]byte, error) {
+	update, err := ChannelCreateUpdate(profile, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigUpdateEnvelope(channelID, update)
+}
+
+// ChannelCreateUpdate returns the config update that creates a channel from a channel profile.
+// It is computed from a template of the channel that the orderer derives from the consortium:
+// the orgs are read at their version and the application group is written with its policies,
+// ACLs and capabilities.
+func ChannelCreateUpdate(profile *Profile, channelID string) (*cb.ConfigUpdate, error) {
+	if profile.Application == nil {
+		return nil, errors.New("cannot define a new channel with no application section")
+	}
+	if profile.Consortium == "" {
+		return nil, errors.New("cannot define a new channel with no consortium")
+	}
+
+	channelGroup, err := newChannelGroup(profile)
+	if err != nil {
+		return nil, err
+	}
+	template := proto.Clone(channelGroup).(*cb.ConfigGroup)
+	template.Groups[ApplicationGroupKey].Values = nil
+	template.Groups[ApplicationGroupKey].Policies = nil
+
+	update, err := Compute(&cb.Config{ChannelGroup: template}, &cb.Config{ChannelGroup: channelGroup})
+	if err != nil {
+		return nil, errors.WithMessage(err, "computing channel creation update failed")
+	}
+
+	// The consortium must be part of the update, the orderer checks it against the template
+	update.ChannelId = channelID
+	update.ReadSet.Values[ConsortiumKey] = &cb.ConfigValue{Version: 0}
+	update.WriteSet.Values[ConsortiumKey] = &cb.ConfigValue{
+		Version: 0,
+		Value:   marshalOrPanic(&cb.Consortium{Name: profile.Consortium}),
+	}
+	return update, nil
+}
+
+// AnchorPeersTx returns the transaction that sets the anchor peers of an application org of
+// a channel profile on a channel created from it, the content of a file like
+// mychannelOrg1MSPanchors.tx. The org is given by its name in the profile.
+func AnchorPeersTx(profile *Profile, channelID, orgName string) ([]byte, error) {
+	org := profile.ApplicationOrg(orgName)
+	if org == nil {
+		return nil, errors.Errorf("org [%s] is not an application org of the profile", orgName)
+	}
+	if len(org.AnchorPeers) == 0 {
+		return nil, errors.Errorf("org [%s] has no anchor peers", orgName)
+	}
+	orgGroup, err := NewApplicationOrgGroup(org)
+	if err != nil {
+		return nil, err
+	}
+
+	// The org group is read at its initial version and written with the anchor peers added
+	readOrg := NewConfigGroup()
+	readOrg.Values[MSPKey] = &cb.ConfigValue{}
+	for _, name := range []string{ReadersPolicyKey, WritersPolicyKey, AdminsPolicyKey} {
+		readOrg.Policies[name] = &cb.ConfigPolicy{}
+	}
+	writeOrg := proto.Clone(readOrg).(*cb.ConfigGroup)
+	writeOrg.Version = 1
+	writeOrg.ModPolicy = AdminsPolicyKey
+	writeOrg.Values[AnchorPeersKey] = orgGroup.Values[AnchorPeersKey]
+
+	update := &cb.ConfigUpdate{
+		ChannelId: channelID,
+		ReadSet:   NewConfigGroup(),
+		WriteSet:  NewConfigGroup(),
+	}
+	for _, set := range []struct {
+		group *cb.ConfigGroup
+		org   *cb.ConfigGroup
+	}{{update.ReadSet, readOrg}, {update.WriteSet, writeOrg}} {
+		application := NewConfigGroup()
+		application.Version = 1
+		application.ModPolicy = AdminsPolicyKey
+		application.Groups[org.Name] = set.org
+		set.group.Groups[ApplicationGroupKey] = application
+	}
+
+	return ConfigUpdateEnvelope(channelID, update)
+}
+
+// GenesisBlock returns the genesis block of the orderer system channel from an orderer
+// genesis profile, the content of a file like twoorgs.genesis.block
+func GenesisBlock(profile *Profile, channelID string) ([]byte, error) {
+	if profile.Orderer == nil {
+		return nil, errors.New("cannot create a genesis block with no orderer section")
+	}
+	channelGroup, err := newChannelGroup(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "generating nonce failed")
+	}
+	txID := sha256.Sum256(nonce)
+	channelHeader := utils.MakeChannelHeader(cb.HeaderType_CONFIG, 1, channelID, 0)
+	channelHeader.TxId = hex.EncodeToString(txID[:])
+	payload := &cb.Payload{
+		Header: utils.MakePayloadHeader(channelHeader, &cb.SignatureHeader{Nonce: nonce}),
+		Data:   marshalOrPanic(&cb.ConfigEnvelope{Config: &cb.Config{ChannelGroup: channelGroup}}),
+	}
+	envelope := &cb.Envelope{Payload: marshalOrPanic(payload)}
+
+	data := &cb.BlockData{Data: [][]byte{marshalOrPanic(envelope)}}
+	dataHash := sha256.Sum256(data.Data[0])
+	metadata := make([][]byte, len(cb.BlockMetadataIndex_name))
+	metadata[cb.BlockMetadataIndex_LAST_CONFIG] = marshalOrPanic(&cb.Metadata{
+		Value: marshalOrPanic(&cb.LastConfig{Index: 0}),
+	})
+	block := &cb.Block{
+		Header:   &cb.BlockHeader{Number: 0, DataHash: dataHash[:]},
+		Data:     data,
+		Metadata: &cb.BlockMetadata{Metadata: metadata},
+	}
+	return proto.Marshal(block)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package configtxgen
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	pp "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+)
+
+const (
+	configtxPath = "../fixtures/fabric/v1.4/config/configtx.yaml"
+	channelDir   = "../fixtures/fabric/v1.4/channel"
+)
+
+func loadProfile(t *testing.T, name string) *Profile {
+	profile, err := LoadProfile(configtxPath, name)
+	if err != nil {
+		t.Fatalf("loading profile %s failed: %s", name, err)
+	}
+	return profile
+}
+
+// decodeConfigUpdate decodes the config update of a CONFIG_UPDATE envelope of the channel
+func decodeConfigUpdate(t *testing.T, channelID string, envelopeBytes []byte) *cb.ConfigUpdate {
+	envelope := &cb.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		t.Fatalf("decoding envelope failed: %s", err)
+	}
+	payload, err := utils.GetPayload(envelope)
+	if err != nil {
+		t.Fatalf("decoding payload failed: %s", err)
+	}
+	channelHeader, err := utils.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil {
+		t.Fatalf("decoding channel header failed: %s", err)
+	}
+	if channelHeader.Type != int32(cb.HeaderType_CONFIG_UPDATE) || channelHeader.ChannelId != channelID {
+		t.Fatalf("expected a config update of channel %s, got type %d of channel %s", channelID, channelHeader.Type, channelHeader.ChannelId)
+	}
+	updateEnvelope := &cb.ConfigUpdateEnvelope{}
+	if err := proto.Unmarshal(payload.Data, updateEnvelope); err != nil {
+		t.Fatalf("decoding config update envelope failed: %s", err)
+	}
+	update := &cb.ConfigUpdate{}
+	if err := proto.Unmarshal(updateEnvelope.ConfigUpdate, update); err != nil {
+		t.Fatalf("decoding config update failed: %s", err)
+	}
+	if update.ChannelId != channelID {
+		t.Fatalf("expected the update of channel %s, got %s", channelID, update.ChannelId)
+	}
+	return update
+}
+
+// readFixtureUpdate decodes the config update of a channel transaction written by the
+// configtxgen tool of Fabric
+func readFixtureUpdate(t *testing.T, channelID, tx string) *cb.ConfigUpdate {
+	data, err := ioutil.ReadFile(filepath.Join(channelDir, tx))
+	if err != nil {
+		t.Fatalf("reading %s failed: %s", tx, err)
+	}
+	return decodeConfigUpdate(t, channelID, data)
+}
+
+// normalizeACLs encodes the ACLs written by the update in a deterministic order, the map
+// of ACLs is encoded in random order
+func normalizeACLs(t *testing.T, update *cb.ConfigUpdate) {
+	value := update.WriteSet.Groups[ApplicationGroupKey].GetValues()[ACLsKey]
+	if value == nil {
+		return
+	}
+	acls := &pp.ACLs{}
+	if err := proto.Unmarshal(value.Value, acls); err != nil {
+		t.Fatalf("decoding ACLs failed: %s", err)
+	}
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(acls); err != nil {
+		t.Fatalf("encoding ACLs failed: %s", err)
+	}
+	value.Value = buf.Bytes()
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*cb.ConfigGroup:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*cb.ConfigPolicy:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*Policy:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestChannelCreateTx(t *testing.T) {
+	profile := loadProfile(t, "TwoOrgsChannel")
+	tx, err := ChannelCreateTx(profile, "orgchannel")
+	if err != nil {
+		t.Fatalf("generating channel creation tx failed: %s", err)
+	}
+	update := decodeConfigUpdate(t, "orgchannel", tx)
+
+	consortium := &cb.Consortium{}
+	if err := proto.Unmarshal(update.WriteSet.Values[ConsortiumKey].GetValue(), consortium); err != nil || consortium.Name != profile.Consortium {
+		t.Fatalf("expected consortium %s, got %v: %v", profile.Consortium, consortium, err)
+	}
+
+	// The orgs of the profile are read from the consortium, the application group is written
+	var orgs []string
+	for _, org := range profile.Application.Organizations {
+		orgs = append(orgs, org.Name)
+	}
+	sort.Strings(orgs)
+	readApp, writeApp := update.ReadSet.Groups[ApplicationGroupKey], update.WriteSet.Groups[ApplicationGroupKey]
+	if readApp == nil || writeApp == nil {
+		t.Fatal("expected the application group to be read and written")
+	}
+	for _, app := range []*cb.ConfigGroup{readApp, writeApp} {
+		if keys := sortedKeys(app.Groups); strings.Join(keys, ",") != strings.Join(orgs, ",") {
+			t.Fatalf("expected orgs %v, got %v", orgs, keys)
+		}
+		for name, org := range app.Groups {
+			if org.Version != 0 || len(org.Values) != 0 || len(org.Policies) != 0 {
+				t.Fatalf("expected org %s to be only referenced, got %v", name, org)
+			}
+		}
+	}
+	if writeApp.Version != 1 || writeApp.ModPolicy != AdminsPolicyKey {
+		t.Fatalf("expected the application group at version 1 administered by Admins, got %d %s", writeApp.Version, writeApp.ModPolicy)
+	}
+	if keys, want := sortedKeys(writeApp.Policies), sortedKeys(profile.Application.Policies); strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected application policies %v, got %v", want, keys)
+	}
+
+	acls := &pp.ACLs{}
+	if err := proto.Unmarshal(writeApp.Values[ACLsKey].GetValue(), acls); err != nil {
+		t.Fatalf("decoding ACLs failed: %s", err)
+	}
+	if len(acls.Acls) != len(profile.Application.ACLs) {
+		t.Fatalf("expected %d ACLs, got %d", len(profile.Application.ACLs), len(acls.Acls))
+	}
+	for resource, policyRef := range profile.Application.ACLs {
+		if acls.Acls[resource].GetPolicyRef() != policyRef {
+			t.Errorf("expected ACL %s to be %s, got %v", resource, policyRef, acls.Acls[resource])
+		}
+	}
+
+	capabilities := &cb.Capabilities{}
+	if err := proto.Unmarshal(writeApp.Values[CapabilitiesKey].GetValue(), capabilities); err != nil {
+		t.Fatalf("decoding capabilities failed: %s", err)
+	}
+	for name, enabled := range profile.Application.Capabilities {
+		if _, ok := capabilities.Capabilities[name]; ok != enabled {
+			t.Errorf("expected capability %s enabled %t", name, enabled)
+		}
+	}
+	// Key level endorsement policies of example CC require V1_3
+	if _, ok := capabilities.Capabilities["V1_3"]; !ok {
+		t.Error("expected application capability V1_3 to be enabled")
+	}
+
+	// The update is the one of the configtxgen tool of Fabric
+	fixture := readFixtureUpdate(t, "orgchannel", "orgchannel.tx")
+	normalizeACLs(t, fixture)
+	normalizeACLs(t, update)
+	if !proto.Equal(update, fixture) {
+		t.Fatalf("expected the update of the orgchannel.tx fixture\n%v\ngot\n%v", fixture, update)
+	}
+}
+
+func TestAnchorPeersTx(t *testing.T) {
+	profile := loadProfile(t, "TwoOrgsChannel")
+	for _, org := range profile.Application.Organizations {
+		t.Run(org.Name, func(t *testing.T) {
+			tx, err := AnchorPeersTx(profile, "orgchannel", org.Name)
+			if err != nil {
+				t.Fatalf("generating anchor peers tx failed: %s", err)
+			}
+			update := decodeConfigUpdate(t, "orgchannel", tx)
+
+			readOrg := update.ReadSet.Groups[ApplicationGroupKey].GetGroups()[org.Name]
+			writeOrg := update.WriteSet.Groups[ApplicationGroupKey].GetGroups()[org.Name]
+			if readOrg == nil || writeOrg == nil || writeOrg.Version != readOrg.Version+1 {
+				t.Fatalf("expected org %s to be written at the next version, read %v write %v", org.Name, readOrg, writeOrg)
+			}
+			anchors := &pp.AnchorPeers{}
+			if err := proto.Unmarshal(writeOrg.Values[AnchorPeersKey].GetValue(), anchors); err != nil {
+				t.Fatalf("decoding anchor peers failed: %s", err)
+			}
+			if len(anchors.AnchorPeers) != len(org.AnchorPeers) {
+				t.Fatalf("expected %d anchor peers, got %v", len(org.AnchorPeers), anchors.AnchorPeers)
+			}
+			for i, anchor := range org.AnchorPeers {
+				if got := anchors.AnchorPeers[i]; got.Host != anchor.Host || int(got.Port) != anchor.Port {
+					t.Errorf("expected anchor peer %s:%d, got %s:%d", anchor.Host, anchor.Port, got.Host, got.Port)
+				}
+			}
+
+			if fixture := readFixtureUpdate(t, "orgchannel", "orgchannel"+org.Name+"anchors.tx"); !proto.Equal(update, fixture) {
+				t.Fatalf("expected the update of the anchor peers fixture\n%v\ngot\n%v", fixture, update)
+			}
+		})
+	}
+}
+
+func TestChannelTxErrors(t *testing.T) {
+	genesis := loadProfile(t, "TwoOrgsOrdererGenesis")
+	if _, err := ChannelCreateTx(genesis, "orgchannel"); err == nil || !strings.Contains(err.Error(), "no application section") {
+		t.Fatalf("expected a profile without application to fail, got %v", err)
+	}
+	noConsortium := loadProfile(t, "TwoOrgsChannel")
+	noConsortium.Consortium = ""
+	if _, err := ChannelCreateTx(noConsortium, "orgchannel"); err == nil || !strings.Contains(err.Error(), "no consortium") {
+		t.Fatalf("expected a profile without consortium to fail, got %v", err)
+	}
+
+	profile := loadProfile(t, "TwoOrgsChannel")
+	if _, err := AnchorPeersTx(profile, "orgchannel", "Org3MSP"); err == nil || !strings.Contains(err.Error(), "not an application org") {
+		t.Fatalf("expected an unknown org to fail, got %v", err)
+	}
+	profile.Application.Organizations[0].AnchorPeers = nil
+	if _, err := AnchorPeersTx(profile, "orgchannel", profile.Application.Organizations[0].Name); err == nil || !strings.Contains(err.Error(), "has no anchor peers") {
+		t.Fatalf("expected an org without anchor peers to fail, got %v", err)
+	}
+}
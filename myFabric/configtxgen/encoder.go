@@ -0,0 +1,315 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package configtxgen
+
+import (
+	"math"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/common/cauthdsl"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/orderer"
+	pp "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+	"github.com/pkg/errors"
+)
+
+// Names of the groups, values and policies of a channel config
+const (
+	ApplicationGroupKey = "Application"
+	OrdererGroupKey     = "Orderer"
+	ConsortiumsGroupKey = "Consortiums"
+
+	MSPKey                 = "MSP"
+	AnchorPeersKey         = "AnchorPeers"
+	ACLsKey                = "ACLs"
+	CapabilitiesKey        = "Capabilities"
+	ConsortiumKey          = "Consortium"
+	HashingAlgorithmKey    = "HashingAlgorithm"
+	BlockDataHashingKey    = "BlockDataHashingStructure"
+	OrdererAddressesKey    = "OrdererAddresses"
+	ConsensusTypeKey       = "ConsensusType"
+	BatchSizeKey           = "BatchSize"
+	BatchTimeoutKey        = "BatchTimeout"
+	KafkaBrokersKey        = "KafkaBrokers"
+	ChannelRestrictionsKey = "ChannelRestrictions"
+	ChannelCreationKey     = "ChannelCreationPolicy"
+
+	ReadersPolicyKey         = "Readers"
+	WritersPolicyKey         = "Writers"
+	AdminsPolicyKey          = "Admins"
+	BlockValidationPolicyKey = "BlockValidation"
+
+	// SignaturePolicyType and ImplicitMetaPolicyType are the policy types of configtx.yaml
+	SignaturePolicyType    = "Signature"
+	ImplicitMetaPolicyType = "ImplicitMeta"
+
+	ordererAdminsPolicyName = "/Channel/Orderer/Admins"
+)
+
+// NewPolicy encodes a policy of configtx.yaml, a signature policy like OR('Org1MSP.member')
+// or an implicit meta policy like MAJORITY Admins
+func NewPolicy(policy *Policy) (*cb.Policy, error) {
+	switch policy.Type {
+	case SignaturePolicyType:
+		envelope, err := cauthdsl.FromString(policy.Rule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid signature policy [%s]", policy.Rule)
+		}
+		return &cb.Policy{Type: int32(cb.Policy_SIGNATURE), Value: utils.MarshalOrPanic(envelope)}, nil
+	case ImplicitMetaPolicyType:
+		parts := strings.Fields(policy.Rule)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid implicit meta policy [%s], expecting <ANY|ALL|MAJORITY> <sub policy>", policy.Rule)
+		}
+		rule, ok := cb.ImplicitMetaPolicy_Rule_value[parts[0]]
+		if !ok {
+			return nil, errors.Errorf("invalid rule [%s] of implicit meta policy, expecting ANY, ALL or MAJORITY", parts[0])
+		}
+		return implicitMetaPolicy(cb.ImplicitMetaPolicy_Rule(rule), parts[1]), nil
+	default:
+		return nil, errors.Errorf("unknown policy type [%s]", policy.Type)
+	}
+}
+
+// NewApplicationOrgGroup encodes an application org of a channel: its MSP, policies and
+// anchor peers
+func NewApplicationOrgGroup(org *Organization) (*cb.ConfigGroup, error) {
+	group, err := newOrgGroup(org)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(org.AnchorPeers) > 0 {
+		anchorPeers := &pp.AnchorPeers{}
+		for _, anchor := range org.AnchorPeers {
+			anchorPeers.AnchorPeers = append(anchorPeers.AnchorPeers, &pp.AnchorPeer{Host: anchor.Host, Port: int32(anchor.Port)})
+		}
+		addValue(group, AnchorPeersKey, anchorPeers, AdminsPolicyKey)
+	}
+	return group, nil
+}
+
+// newChannelGroup encodes the channel group of a profile with its orderer, application and
+// consortiums groups
+func newChannelGroup(profile *Profile) (*cb.ConfigGroup, error) {
+	group := NewConfigGroup()
+	group.ModPolicy = AdminsPolicyKey
+	if err := addPolicies(group, profile.Policies); err != nil {
+		return nil, errors.WithMessage(err, "adding channel policies failed")
+	}
+
+	addValue(group, HashingAlgorithmKey, &cb.HashingAlgorithm{Name: "SHA256"}, AdminsPolicyKey)
+	addValue(group, BlockDataHashingKey, &cb.BlockDataHashingStructure{Width: math.MaxUint32}, AdminsPolicyKey)
+	if profile.Orderer != nil && len(profile.Orderer.Addresses) > 0 {
+		addValue(group, OrdererAddressesKey, &cb.OrdererAddresses{Addresses: profile.Orderer.Addresses}, ordererAdminsPolicyName)
+	}
+	if profile.Consortium != "" {
+		addValue(group, ConsortiumKey, &cb.Consortium{Name: profile.Consortium}, AdminsPolicyKey)
+	}
+	if len(profile.Capabilities) > 0 {
+		addValue(group, CapabilitiesKey, capabilities(profile.Capabilities), AdminsPolicyKey)
+	}
+
+	var err error
+	if profile.Orderer != nil {
+		if group.Groups[OrdererGroupKey], err = newOrdererGroup(profile.Orderer); err != nil {
+			return nil, errors.WithMessage(err, "encoding orderer group failed")
+		}
+	}
+	if profile.Application != nil {
+		if group.Groups[ApplicationGroupKey], err = newApplicationGroup(profile.Application); err != nil {
+			return nil, errors.WithMessage(err, "encoding application group failed")
+		}
+	}
+	if profile.Consortiums != nil {
+		if group.Groups[ConsortiumsGroupKey], err = newConsortiumsGroup(profile.Consortiums); err != nil {
+			return nil, errors.WithMessage(err, "encoding consortiums group failed")
+		}
+	}
+	return group, nil
+}
+
+func newOrdererGroup(orderer *Orderer) (*cb.ConfigGroup, error) {
+	group := NewConfigGroup()
+	group.ModPolicy = AdminsPolicyKey
+	if len(orderer.Policies) == 0 {
+		group.Policies[BlockValidationPolicyKey] = &cb.ConfigPolicy{
+			Policy:    implicitMetaPolicy(cb.ImplicitMetaPolicy_ANY, WritersPolicyKey),
+			ModPolicy: AdminsPolicyKey,
+		}
+	}
+	if err := addPolicies(group, orderer.Policies); err != nil {
+		return nil, err
+	}
+
+	addValue(group, ConsensusTypeKey, &ab.ConsensusType{Type: orderer.OrdererType}, AdminsPolicyKey)
+	addValue(group, BatchSizeKey, &ab.BatchSize{
+		MaxMessageCount:   orderer.BatchSize.MaxMessageCount,
+		AbsoluteMaxBytes:  uint32(orderer.BatchSize.AbsoluteMaxBytes),
+		PreferredMaxBytes: uint32(orderer.BatchSize.PreferredMaxBytes),
+	}, AdminsPolicyKey)
+	addValue(group, BatchTimeoutKey, &ab.BatchTimeout{Timeout: orderer.BatchTimeout.String()}, AdminsPolicyKey)
+	addValue(group, ChannelRestrictionsKey, &ab.ChannelRestrictions{MaxCount: orderer.MaxChannels}, AdminsPolicyKey)
+	if len(orderer.Capabilities) > 0 {
+		addValue(group, CapabilitiesKey, capabilities(orderer.Capabilities), AdminsPolicyKey)
+	}
+	switch orderer.OrdererType {
+	case "solo":
+	case "kafka":
+		addValue(group, KafkaBrokersKey, &ab.KafkaBrokers{Brokers: orderer.Kafka.Brokers}, AdminsPolicyKey)
+	default:
+		return nil, errors.Errorf("unsupported orderer type [%s], expecting solo or kafka", orderer.OrdererType)
+	}
+
+	for _, org := range orderer.Organizations {
+		orgGroup, err := newOrgGroup(org)
+		if err != nil {
+			return nil, err
+		}
+		group.Groups[org.Name] = orgGroup
+	}
+	return group, nil
+}
+
+func newApplicationGroup(application *Application) (*cb.ConfigGroup, error) {
+	group := NewConfigGroup()
+	group.ModPolicy = AdminsPolicyKey
+	if err := addPolicies(group, application.Policies); err != nil {
+		return nil, err
+	}
+
+	if len(application.ACLs) > 0 {
+		acls := &pp.ACLs{Acls: make(map[string]*pp.APIResource)}
+		for resource, policyRef := range application.ACLs {
+			acls.Acls[resource] = &pp.APIResource{PolicyRef: policyRef}
+		}
+		addValue(group, ACLsKey, acls, AdminsPolicyKey)
+	}
+	if len(application.Capabilities) > 0 {
+		addValue(group, CapabilitiesKey, capabilities(application.Capabilities), AdminsPolicyKey)
+	}
+
+	for _, org := range application.Organizations {
+		orgGroup, err := NewApplicationOrgGroup(org)
+		if err != nil {
+			return nil, err
+		}
+		group.Groups[org.Name] = orgGroup
+	}
+	return group, nil
+}
+
+func newConsortiumsGroup(consortiums map[string]*Consortium) (*cb.ConfigGroup, error) {
+	group := NewConfigGroup()
+	group.ModPolicy = ordererAdminsPolicyName
+	// The consortiums are administered by the orderer admins through the mod policy
+	group.Policies[AdminsPolicyKey] = &cb.ConfigPolicy{
+		Policy:    &cb.Policy{Type: int32(cb.Policy_SIGNATURE), Value: utils.MarshalOrPanic(cauthdsl.AcceptAllPolicy)},
+		ModPolicy: ordererAdminsPolicyName,
+	}
+
+	for name, consortium := range consortiums {
+		consortiumGroup := NewConfigGroup()
+		consortiumGroup.ModPolicy = ordererAdminsPolicyName
+		addValue(consortiumGroup, ChannelCreationKey, implicitMetaPolicy(cb.ImplicitMetaPolicy_ANY, AdminsPolicyKey), ordererAdminsPolicyName)
+		if consortium != nil {
+			for _, org := range consortium.Organizations {
+				orgGroup, err := newOrgGroup(org)
+				if err != nil {
+					return nil, err
+				}
+				consortiumGroup.Groups[org.Name] = orgGroup
+			}
+		}
+		group.Groups[name] = consortiumGroup
+	}
+	return group, nil
+}
+
+// newOrgGroup encodes the MSP and policies of an org. Without policies members may read
+// and write and admins administer the org.
+func newOrgGroup(org *Organization) (*cb.ConfigGroup, error) {
+	mspConfig, err := MSPConfig(org.ID, org.MSPDir)
+	if err != nil {
+		return nil, errors.WithMessage(err, "reading MSP of org ["+org.Name+"] failed")
+	}
+
+	group := NewConfigGroup()
+	group.ModPolicy = AdminsPolicyKey
+	addValue(group, MSPKey, mspConfig, AdminsPolicyKey)
+
+	if len(org.Policies) == 0 {
+		member := &cb.Policy{Type: int32(cb.Policy_SIGNATURE), Value: utils.MarshalOrPanic(cauthdsl.SignedByMspMember(org.ID))}
+		admin := &cb.Policy{Type: int32(cb.Policy_SIGNATURE), Value: utils.MarshalOrPanic(cauthdsl.SignedByMspAdmin(org.ID))}
+		group.Policies[ReadersPolicyKey] = &cb.ConfigPolicy{Policy: member, ModPolicy: AdminsPolicyKey}
+		group.Policies[WritersPolicyKey] = &cb.ConfigPolicy{Policy: member, ModPolicy: AdminsPolicyKey}
+		group.Policies[AdminsPolicyKey] = &cb.ConfigPolicy{Policy: admin, ModPolicy: AdminsPolicyKey}
+		return group, nil
+	}
+	if err := addPolicies(group, org.Policies); err != nil {
+		return nil, errors.WithMessage(err, "adding policies of org ["+org.Name+"] failed")
+	}
+	return group, nil
+}
+
+// addPolicies adds the policies to the group, or the implicit meta defaults if there are none:
+// any readers, any writers and a majority of admins of the sub groups
+func addPolicies(group *cb.ConfigGroup, policies map[string]*Policy) error {
+	if len(policies) == 0 {
+		group.Policies[ReadersPolicyKey] = &cb.ConfigPolicy{Policy: implicitMetaPolicy(cb.ImplicitMetaPolicy_ANY, ReadersPolicyKey), ModPolicy: AdminsPolicyKey}
+		group.Policies[WritersPolicyKey] = &cb.ConfigPolicy{Policy: implicitMetaPolicy(cb.ImplicitMetaPolicy_ANY, WritersPolicyKey), ModPolicy: AdminsPolicyKey}
+		group.Policies[AdminsPolicyKey] = &cb.ConfigPolicy{Policy: implicitMetaPolicy(cb.ImplicitMetaPolicy_MAJORITY, AdminsPolicyKey), ModPolicy: AdminsPolicyKey}
+		return nil
+	}
+
+	for name, policy := range policies {
+		if policy == nil {
+			return errors.Errorf("policy [%s] is empty", name)
+		}
+		p, err := NewPolicy(policy)
+		if err != nil {
+			return errors.WithMessage(err, "encoding policy ["+name+"] failed")
+		}
+		group.Policies[name] = &cb.ConfigPolicy{Policy: p, ModPolicy: AdminsPolicyKey}
+	}
+	return nil
+}
+
+func implicitMetaPolicy(rule cb.ImplicitMetaPolicy_Rule, subPolicy string) *cb.Policy {
+	return &cb.Policy{
+		Type:  int32(cb.Policy_IMPLICIT_META),
+		Value: utils.MarshalOrPanic(&cb.ImplicitMetaPolicy{Rule: rule, SubPolicy: subPolicy}),
+	}
+}
+
+// capabilities encodes the enabled capabilities
+func capabilities(enabled map[string]bool) *cb.Capabilities {
+	c := &cb.Capabilities{Capabilities: make(map[string]*cb.Capability)}
+	for name, on := range enabled {
+		if on {
+			c.Capabilities[name] = &cb.Capability{}
+		}
+	}
+	return c
+}
+
+func addValue(group *cb.ConfigGroup, key string, value proto.Message, modPolicy string) {
+	group.Values[key] = &cb.ConfigValue{Value: marshalOrPanic(value), ModPolicy: modPolicy}
+}
+
+// marshalOrPanic marshals with sorted map entries so that generating an artifact twice
+// yields the same bytes
+func marshalOrPanic(msg proto.Message) []byte {
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(msg); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,148 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package configtxgen
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	mspproto "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/msp"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// mspOUConfig is the config.yaml of an MSP directory
+type mspOUConfig struct {
+	OrganizationalUnitIdentifiers []*mspOUIdentifier `yaml:"OrganizationalUnitIdentifiers"`
+	NodeOUs                       *mspNodeOUs        `yaml:"NodeOUs"`
+}
+
+type mspOUIdentifier struct {
+	Certificate                  string `yaml:"Certificate"`
+	OrganizationalUnitIdentifier string `yaml:"OrganizationalUnitIdentifier"`
+}
+
+type mspNodeOUs struct {
+	Enable             bool             `yaml:"Enable"`
+	ClientOUIdentifier *mspOUIdentifier `yaml:"ClientOUIdentifier"`
+	PeerOUIdentifier   *mspOUIdentifier `yaml:"PeerOUIdentifier"`
+}
+
+// MSPConfig reads the verifying MSP of an org from an MSP directory as written by cryptogen,
+// the way the configtxgen tool does: certificates of cacerts, admincerts, intermediatecerts,
+// tlscacerts, tlsintermediatecerts and crls, and OUs of config.yaml. Only cacerts is required.
+func MSPConfig(mspID, mspDir string) (*mspproto.MSPConfig, error) {
+	dirs := []string{"cacerts", "admincerts", "intermediatecerts", "tlscacerts", "tlsintermediatecerts", "crls"}
+	pems := make(map[string][][]byte, len(dirs))
+	for _, dir := range dirs {
+		var err error
+		if pems[dir], err = readPEMDir(filepath.Join(mspDir, dir)); err != nil {
+			return nil, err
+		}
+	}
+	if len(pems["cacerts"]) == 0 {
+		return nil, errors.Errorf("no CA certificates found in MSP directory [%s]", mspDir)
+	}
+
+	fabricConfig := &mspproto.FabricMSPConfig{
+		Name:                 mspID,
+		RootCerts:            pems["cacerts"],
+		IntermediateCerts:    pems["intermediatecerts"],
+		Admins:               pems["admincerts"],
+		RevocationList:       pems["crls"],
+		TlsRootCerts:         pems["tlscacerts"],
+		TlsIntermediateCerts: pems["tlsintermediatecerts"],
+		CryptoConfig: &mspproto.FabricCryptoConfig{
+			SignatureHashFamily:            "SHA2",
+			IdentityIdentifierHashFunction: "SHA256",
+		},
+	}
+	if err := readOUConfig(mspDir, fabricConfig); err != nil {
+		return nil, err
+	}
+
+	fabricConfigBytes, err := proto.Marshal(fabricConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal MSP config failed")
+	}
+	return &mspproto.MSPConfig{Type: 0, Config: fabricConfigBytes}, nil
+}
+
+// readOUConfig adds the OU identifiers and node OUs of config.yaml of the MSP directory, if any
+func readOUConfig(mspDir string, fabricConfig *mspproto.FabricMSPConfig) error {
+	data, err := ioutil.ReadFile(filepath.Join(mspDir, "config.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "reading MSP config.yaml failed")
+	}
+	ouConfig := &mspOUConfig{}
+	if err := yaml.Unmarshal(data, ouConfig); err != nil {
+		return errors.Wrap(err, "parsing MSP config.yaml failed")
+	}
+
+	ouIdentifier := func(id *mspOUIdentifier) (*mspproto.FabricOUIdentifier, error) {
+		if id == nil {
+			return nil, nil
+		}
+		cert, err := ioutil.ReadFile(filepath.Join(mspDir, id.Certificate))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading OU certificate [%s] failed", id.Certificate)
+		}
+		return &mspproto.FabricOUIdentifier{Certificate: cert, OrganizationalUnitIdentifier: id.OrganizationalUnitIdentifier}, nil
+	}
+
+	for _, id := range ouConfig.OrganizationalUnitIdentifiers {
+		ou, err := ouIdentifier(id)
+		if err != nil {
+			return err
+		}
+		fabricConfig.OrganizationalUnitIdentifiers = append(fabricConfig.OrganizationalUnitIdentifiers, ou)
+	}
+	if ouConfig.NodeOUs != nil {
+		nodeOUs := &mspproto.FabricNodeOUs{Enable: ouConfig.NodeOUs.Enable}
+		if nodeOUs.ClientOuIdentifier, err = ouIdentifier(ouConfig.NodeOUs.ClientOUIdentifier); err != nil {
+			return err
+		}
+		if nodeOUs.PeerOuIdentifier, err = ouIdentifier(ouConfig.NodeOUs.PeerOUIdentifier); err != nil {
+			return err
+		}
+		fabricConfig.FabricNodeOus = nodeOUs
+	}
+	return nil
+}
+
+// readPEMDir reads the PEM files of a directory of an MSP, none if it does not exist
+func readPEMDir(dir string) ([][]byte, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "reading directory [%s] failed", dir)
+	}
+
+	var pems [][]byte
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading file [%s] failed", f.Name())
+		}
+		if block, _ := pem.Decode(data); block == nil {
+			continue
+		}
+		pems = append(pems, data)
+	}
+	return pems, nil
+}
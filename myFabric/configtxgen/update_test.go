@@ -0,0 +1,181 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package configtxgen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+)
+
+// writtenVersions collects the elements the write set writes at a new version by their path
+// and version. A new group is collected without its members.
+func writtenVersions(path string, read, write *cb.ConfigGroup, versions map[string]uint64) {
+	if read == nil {
+		versions[path] = write.Version
+		return
+	}
+	if write.Version != read.Version {
+		versions[path] = write.Version
+	}
+	for name, value := range write.Values {
+		if readValue, ok := read.Values[name]; !ok || readValue.Version != value.Version {
+			versions[path+"/"+name] = value.Version
+		}
+	}
+	for name, policy := range write.Policies {
+		if readPolicy, ok := read.Policies[name]; !ok || readPolicy.Version != policy.Version {
+			versions[path+"/"+name] = policy.Version
+		}
+	}
+	for name, group := range write.Groups {
+		writtenVersions(path+"/"+name, read.Groups[name], group, versions)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	original, err := newChannelGroup(loadProfile(t, "TwoOrgsChannel"))
+	if err != nil {
+		t.Fatalf("encoding channel group failed: %s", err)
+	}
+	org1Path := []string{ApplicationGroupKey, "Org1MSP"}
+	adminsRule, err := NewPolicy(&Policy{Type: SignaturePolicyType, Rule: "OR('Org1MSP.member')"})
+	if err != nil {
+		t.Fatalf("encoding policy failed: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(channel *cb.ConfigGroup)
+		written map[string]uint64
+		check   func(t *testing.T, update *cb.ConfigUpdate)
+	}{
+		{
+			name: "unchanged",
+			edit: func(*cb.ConfigGroup) {},
+		},
+		{
+			name: "value changed",
+			edit: func(channel *cb.ConfigGroup) {
+				channel.Groups[ApplicationGroupKey].Values[CapabilitiesKey].Value = marshalOrPanic(capabilities(map[string]bool{"V1_3": true}))
+			},
+			written: map[string]uint64{"/Channel/Application/Capabilities": 1},
+		},
+		{
+			name: "value mod policy changed",
+			edit: func(channel *cb.ConfigGroup) {
+				channel.Groups[ApplicationGroupKey].Values[CapabilitiesKey].ModPolicy = WritersPolicyKey
+			},
+			written: map[string]uint64{"/Channel/Application/Capabilities": 1},
+		},
+		{
+			name: "value added",
+			edit: func(channel *cb.ConfigGroup) {
+				addValue(channel, OrdererAddressesKey, &cb.OrdererAddresses{Addresses: []string{"orderer.example.com:7050"}}, AdminsPolicyKey)
+			},
+			written: map[string]uint64{"/Channel": 1, "/Channel/OrdererAddresses": 0},
+		},
+		{
+			name: "group added",
+			edit: func(channel *cb.ConfigGroup) {
+				org := proto.Clone(group(channel, org1Path...)).(*cb.ConfigGroup)
+				channel.Groups[ApplicationGroupKey].Groups["Org3MSP"] = org
+			},
+			written: map[string]uint64{"/Channel/Application": 1, "/Channel/Application/Org3MSP": 0},
+			check: func(t *testing.T, update *cb.ConfigUpdate) {
+				// A new group is written with all its members
+				org := group(update.WriteSet, ApplicationGroupKey, "Org3MSP")
+				if !proto.Equal(org, group(original, org1Path...)) {
+					t.Fatalf("expected the new group to be written in full, got %v", org)
+				}
+			},
+		},
+		{
+			name: "group removed",
+			edit: func(channel *cb.ConfigGroup) {
+				delete(channel.Groups[ApplicationGroupKey].Groups, "Org2MSP")
+			},
+			written: map[string]uint64{"/Channel/Application": 1},
+		},
+		{
+			name: "policy changed",
+			edit: func(channel *cb.ConfigGroup) {
+				group(channel, org1Path...).Policies[AdminsPolicyKey].Policy = adminsRule
+			},
+			written: map[string]uint64{"/Channel/Application/Org1MSP/Admins": 1},
+			check: func(t *testing.T, update *cb.ConfigUpdate) {
+				if !proto.Equal(group(update.WriteSet, org1Path...).Policies[AdminsPolicyKey].Policy, adminsRule) {
+					t.Fatal("expected the write set to carry the new policy")
+				}
+				if _, ok := group(update.ReadSet, org1Path...).Policies[AdminsPolicyKey]; ok {
+					t.Fatal("expected the changed policy not to be read")
+				}
+			},
+		},
+		{
+			name: "policy removed",
+			edit: func(channel *cb.ConfigGroup) {
+				delete(group(channel, org1Path...).Policies, ReadersPolicyKey)
+			},
+			written: map[string]uint64{"/Channel/Application/Org1MSP": 1},
+		},
+		{
+			name: "group mod policy changed",
+			edit: func(channel *cb.ConfigGroup) {
+				group(channel, org1Path...).ModPolicy = WritersPolicyKey
+			},
+			written: map[string]uint64{"/Channel/Application/Org1MSP": 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := proto.Clone(original).(*cb.ConfigGroup)
+			tc.edit(updated)
+
+			update, err := Compute(&cb.Config{ChannelGroup: original}, &cb.Config{ChannelGroup: updated})
+			if tc.written == nil {
+				if err != ErrNoDifferences {
+					t.Fatalf("expected ErrNoDifferences, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("computing update failed: %s", err)
+			}
+
+			written := make(map[string]uint64)
+			writtenVersions("/Channel", update.ReadSet, update.WriteSet, written)
+			if !reflect.DeepEqual(written, tc.written) {
+				t.Fatalf("expected written elements %v, got %v", tc.written, written)
+			}
+			if tc.check != nil {
+				tc.check(t, update)
+			}
+		})
+	}
+}
+
+func TestComputeWithoutChannelGroup(t *testing.T) {
+	channel := NewConfigGroup()
+	if _, err := Compute(&cb.Config{}, &cb.Config{ChannelGroup: channel}); err == nil {
+		t.Fatal("expected an original config without channel group to fail")
+	}
+	if _, err := Compute(&cb.Config{ChannelGroup: channel}, &cb.Config{}); err == nil {
+		t.Fatal("expected an updated config without channel group to fail")
+	}
+}
+
+// group returns the subgroup of a group at the given path
+func group(g *cb.ConfigGroup, path ...string) *cb.ConfigGroup {
+	for _, name := range path {
+		g = g.Groups[name]
+	}
+	return g
+}
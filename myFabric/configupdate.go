@@ -0,0 +1,383 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	contextAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	contextImpl "github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/resource"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/orderer"
+	pp "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+	"github.com/pkg/errors"
+	"myFabric/configtxgen"
+)
+
+// ChannelConfig is an editable copy of the current configuration of a channel. The edit
+// methods change Config only, Update computes the config update from the current config
+// to Config and Submit applies it to the channel.
+type ChannelConfig struct {
+	ChannelID   string
+	Orderer     string
+	BlockNumber uint64
+	Config      *cb.Config
+	current     *cb.Config
+}
+
+// FetchChannelConfig fetches the last config block of the channel from the orderer, like
+// QueryConfigFromOrderer but decoded into an editable config instead of a read-only view
+func FetchChannelConfig(ctxProvider contextAPI.ClientProvider, channelID, orderer string) (*ChannelConfig, error) {
+	current, blockNumber, err := fetchConfig(ctxProvider, channelID, orderer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChannelConfig{
+		ChannelID:   channelID,
+		Orderer:     orderer,
+		BlockNumber: blockNumber,
+		Config:      proto.Clone(current).(*cb.Config),
+		current:     current,
+	}, nil
+}
+
+func fetchConfig(ctxProvider contextAPI.ClientProvider, channelID, orderer string) (*cb.Config, uint64, error) {
+	ctx, err := ctxProvider()
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "failed to get client context")
+	}
+	ordererCfg, ok := ctx.EndpointConfig().OrdererConfig(orderer)
+	if !ok {
+		return nil, 0, errors.Errorf("orderer [%s] not found in config", orderer)
+	}
+	ordererClient, err := ctx.InfraProvider().CreateOrdererFromConfig(ordererCfg)
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "creating orderer client failed")
+	}
+
+	reqCtx, cancel := contextImpl.NewRequest(ctx, contextImpl.WithTimeoutType(fabAPI.OrdererResponse))
+	defer cancel()
+
+	block, err := resource.LastConfigFromOrderer(reqCtx, channelID, ordererClient, resource.WithRetry(retry.DefaultResMgmtOpts))
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "fetching config block of channel ["+channelID+"] failed")
+	}
+	configEnvelope, err := resource.CreateConfigEnvelope(block.Data.Data[0])
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "decoding config block failed")
+	}
+	if configEnvelope.Config == nil || configEnvelope.Config.ChannelGroup == nil {
+		return nil, 0, errors.New("config block has no channel group")
+	}
+
+	return configEnvelope.Config, block.Header.Number, nil
+}
+
+// AddOrg adds an application org to the channel. The MSP is read from an MSP directory as
+// written by cryptogen. Members of the org may read and write, its admins administer the org.
+// Anchor peers are given as host:port.
+func (c *ChannelConfig) AddOrg(mspID, mspDir string, anchorPeers ...string) error {
+	application, err := c.group(configtxgen.ApplicationGroupKey)
+	if err != nil {
+		return err
+	}
+	if _, ok := application.Groups[mspID]; ok {
+		return errors.Errorf("org [%s] is already a member of channel [%s]", mspID, c.ChannelID)
+	}
+
+	org := &configtxgen.Organization{Name: mspID, ID: mspID, MSPDir: mspDir}
+	for _, anchor := range anchorPeers {
+		host, portStr, err := net.SplitHostPort(anchor)
+		if err != nil {
+			return errors.Wrapf(err, "invalid anchor peer [%s], expecting host:port", anchor)
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return errors.Wrapf(err, "invalid port of anchor peer [%s]", anchor)
+		}
+		org.AnchorPeers = append(org.AnchorPeers, &configtxgen.AnchorPeer{Host: host, Port: int(port)})
+	}
+
+	orgGroup, err := configtxgen.NewApplicationOrgGroup(org)
+	if err != nil {
+		return err
+	}
+	application.Groups[mspID] = orgGroup
+	return nil
+}
+
+// SetBatchSize changes the batch size of the orderer. Zero values are left unchanged.
+func (c *ChannelConfig) SetBatchSize(maxMessageCount, absoluteMaxBytes, preferredMaxBytes uint32) error {
+	orderer, err := c.group(configtxgen.OrdererGroupKey)
+	if err != nil {
+		return err
+	}
+	value, ok := orderer.Values[configtxgen.BatchSizeKey]
+	if !ok {
+		return errors.New("orderer config has no batch size")
+	}
+	batchSize := &ab.BatchSize{}
+	if err := proto.Unmarshal(value.Value, batchSize); err != nil {
+		return errors.Wrap(err, "unmarshal batch size failed")
+	}
+
+	if maxMessageCount > 0 {
+		batchSize.MaxMessageCount = maxMessageCount
+	}
+	if absoluteMaxBytes > 0 {
+		batchSize.AbsoluteMaxBytes = absoluteMaxBytes
+	}
+	if preferredMaxBytes > 0 {
+		batchSize.PreferredMaxBytes = preferredMaxBytes
+	}
+	if batchSize.PreferredMaxBytes > batchSize.AbsoluteMaxBytes {
+		return errors.Errorf("preferred max bytes %d exceeds absolute max bytes %d", batchSize.PreferredMaxBytes, batchSize.AbsoluteMaxBytes)
+	}
+
+	value.Value = utils.MarshalOrPanic(batchSize)
+	return nil
+}
+
+// SetBatchTimeout changes the time the orderer waits before cutting a batch
+func (c *ChannelConfig) SetBatchTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return errors.Errorf("invalid batch timeout %s", timeout)
+	}
+	orderer, err := c.group(configtxgen.OrdererGroupKey)
+	if err != nil {
+		return err
+	}
+	value, ok := orderer.Values[configtxgen.BatchTimeoutKey]
+	if !ok {
+		return errors.New("orderer config has no batch timeout")
+	}
+
+	value.Value = utils.MarshalOrPanic(&ab.BatchTimeout{Timeout: timeout.String()})
+	return nil
+}
+
+// SetACL maps a resource of the channel, e.g. qscc/GetChainInfo, to a policy, e.g.
+// /Channel/Application/Admins. An empty policy removes the mapping.
+func (c *ChannelConfig) SetACL(resourceName, policyRef string) error {
+	application, err := c.group(configtxgen.ApplicationGroupKey)
+	if err != nil {
+		return err
+	}
+
+	acls := &pp.ACLs{}
+	value, ok := application.Values[configtxgen.ACLsKey]
+	if ok {
+		if err := proto.Unmarshal(value.Value, acls); err != nil {
+			return errors.Wrap(err, "unmarshal ACLs failed")
+		}
+	} else {
+		value = &cb.ConfigValue{ModPolicy: configtxgen.AdminsPolicyKey}
+		application.Values[configtxgen.ACLsKey] = value
+	}
+	if acls.Acls == nil {
+		acls.Acls = make(map[string]*pp.APIResource)
+	}
+
+	if policyRef == "" {
+		delete(acls.Acls, resourceName)
+	} else {
+		acls.Acls[resourceName] = &pp.APIResource{PolicyRef: policyRef}
+	}
+	value.Value = utils.MarshalOrPanic(acls)
+	return nil
+}
+
+// SetPolicy sets a signature policy, e.g. OR('Org1MSP.admin','Org2MSP.admin'), of a group of
+// the config. The group is given by its path below the channel group, e.g. Application or
+// Application/Org1MSP, the channel group itself by an empty path.
+func (c *ChannelConfig) SetPolicy(groupPath, name, policy string) error {
+	group, err := c.group(groupPath)
+	if err != nil {
+		return err
+	}
+	p, err := configtxgen.NewPolicy(&configtxgen.Policy{Type: configtxgen.SignaturePolicyType, Rule: policy})
+	if err != nil {
+		return err
+	}
+
+	configPolicy := &cb.ConfigPolicy{Policy: p, ModPolicy: configtxgen.AdminsPolicyKey}
+	if existing, ok := group.Policies[name]; ok {
+		configPolicy.ModPolicy = existing.ModPolicy
+	}
+	group.Policies[name] = configPolicy
+	return nil
+}
+
+// group returns the group of the edited config at the given path, like Application/Org1MSP
+func (c *ChannelConfig) group(path string) (*cb.ConfigGroup, error) {
+	group := c.Config.ChannelGroup
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		child, ok := group.Groups[name]
+		if !ok {
+			return nil, errors.Errorf("config group [%s] not found in channel [%s]", path, c.ChannelID)
+		}
+		group = child
+	}
+	ensureConfigGroupMaps(group)
+	return group, nil
+}
+
+// Update computes the config update from the current config to the edited config.
+// configtxgen.ErrNoDifferences is returned if the configs do not differ.
+func (c *ChannelConfig) Update() (*cb.ConfigUpdate, error) {
+	update, err := configtxgen.Compute(c.current, c.Config)
+	if err != nil {
+		return nil, err
+	}
+	update.ChannelId = c.ChannelID
+	return update, nil
+}
+
+// ConfigUpdatePaths lists the elements a config update writes at a new version, like
+// /Channel/Orderer/BatchSize. A new group is listed without its members.
+func ConfigUpdatePaths(update *cb.ConfigUpdate) []string {
+	var paths []string
+	collectWrittenPaths("/Channel", update.ReadSet, update.WriteSet, &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+func collectWrittenPaths(path string, read, write *cb.ConfigGroup, paths *[]string) {
+	if read == nil {
+		*paths = append(*paths, path)
+		return
+	}
+	if write.Version != read.Version {
+		*paths = append(*paths, path)
+	}
+	for name, value := range write.Values {
+		if readValue, ok := read.Values[name]; !ok || readValue.Version != value.Version {
+			*paths = append(*paths, path+"/"+name)
+		}
+	}
+	for name, policy := range write.Policies {
+		if readPolicy, ok := read.Policies[name]; !ok || readPolicy.Version != policy.Version {
+			*paths = append(*paths, path+"/"+name)
+		}
+	}
+	for name, group := range write.Groups {
+		collectWrittenPaths(path+"/"+name, read.Groups[name], group, paths)
+	}
+}
+
+// Submit signs the config update with the signing identity of each org and submits it with
+// the resource management client of the first org. It waits until the orderer has a new
+// config block and verifies that the channel config then matches the edited config. The
+// number of the new config block is returned. The orgs must satisfy the mod policies of
+// everything changed, e.g. the orderer org for batch sizes or a majority of the application
+// org admins for a new org.
+func (c *ChannelConfig) Submit(orgs []*OrgContext) (uint64, error) {
+	if len(orgs) == 0 {
+		return 0, errors.New("no orgs to sign the config update")
+	}
+	update, err := c.Update()
+	if err != nil {
+		return 0, err
+	}
+	envelope, err := configtxgen.ConfigUpdateEnvelope(c.ChannelID, update)
+	if err != nil {
+		return 0, err
+	}
+
+	var signingIdentities []msp.SigningIdentity
+	for _, orgCtx := range orgs {
+		if orgCtx.SigningIdentity == nil {
+			return 0, errors.Errorf("org [%s] has no signing identity", orgCtx.OrgID)
+		}
+		signingIdentities = append(signingIdentities, orgCtx.SigningIdentity)
+	}
+	req := resmgmt.SaveChannelRequest{
+		ChannelID:         c.ChannelID,
+		ChannelConfig:     bytes.NewReader(envelope),
+		SigningIdentities: signingIdentities,
+	}
+	if _, err := orgs[0].ResMgmt.SaveChannel(req, resmgmt.WithRetry(retry.DefaultResMgmtOpts), resmgmt.WithOrdererEndpoint(c.Orderer)); err != nil {
+		return 0, errors.WithMessage(err, "submitting config update failed")
+	}
+
+	blockNumber, err := waitForConfigBlock(orgs[0].ResMgmt, c.ChannelID, c.Orderer, false, c.BlockNumber)
+	if err != nil {
+		return 0, err
+	}
+	current, blockNumber, err := fetchConfig(orgs[0].CtxProvider, c.ChannelID, c.Orderer)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := configtxgen.Compute(current, c.Config); err != configtxgen.ErrNoDifferences {
+		if err != nil {
+			return 0, errors.WithMessage(err, "comparing config of channel ["+c.ChannelID+"] with the submitted update failed")
+		}
+		return 0, errors.Errorf("config of channel [%s] at block %d does not match the submitted update", c.ChannelID, blockNumber)
+	}
+
+	c.current = current
+	c.Config = proto.Clone(current).(*cb.Config)
+	c.BlockNumber = blockNumber
+	return blockNumber, nil
+}
+
+// NewConfigSigner creates an org context that signs config updates with the identity of the
+// user of the org, e.g. the orderer org admin, without discovering the peers of the org
+func NewConfigSigner(sdk *fabsdk.FabricSDK, orgName, userName string) (*OrgContext, error) {
+	clientContext := sdk.Context(fabsdk.WithUser(userName), fabsdk.WithOrg(orgName))
+	resMgmt, err := resmgmt.New(clientContext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Creating resource management client failed")
+	}
+
+	mspClient, err := mspclient.New(sdk.Context(), mspclient.WithOrg(orgName))
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating MSP client of org ["+orgName+"] failed")
+	}
+	signingIdentity, err := mspClient.GetSigningIdentity(userName)
+	if err != nil {
+		return nil, errors.WithMessage(err, "getting identity of user ["+userName+"] of org ["+orgName+"] failed")
+	}
+
+	return &OrgContext{
+		OrgID:           orgName,
+		CtxProvider:     clientContext,
+		SigningIdentity: signingIdentity,
+		ResMgmt:         resMgmt,
+	}, nil
+}
+
+// ensureConfigGroupMaps creates the maps of a group that were empty when it was unmarshalled
+func ensureConfigGroupMaps(group *cb.ConfigGroup) {
+	if group.Groups == nil {
+		group.Groups = make(map[string]*cb.ConfigGroup)
+	}
+	if group.Values == nil {
+		group.Values = make(map[string]*cb.ConfigValue)
+	}
+	if group.Policies == nil {
+		group.Policies = make(map[string]*cb.ConfigPolicy)
+	}
+}
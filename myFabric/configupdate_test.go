@@ -0,0 +1,315 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/resource"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/orderer"
+	pp "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+	"myFabric/configtxgen"
+)
+
+const (
+	fixtureChannelDir = "fixtures/fabric/v1.4/channel"
+	fixtureChannelID  = "orgchannel"
+)
+
+// fixtureChannelConfig returns the config of orgchannel as the orderer creates it: the
+// channel group of the decoded genesis block fixture without the consortiums, and the
+// application group of the orgchannel.tx fixture with the orgs of its consortium
+func fixtureChannelConfig(t *testing.T) *ChannelConfig {
+	blockBytes, err := ioutil.ReadFile(filepath.Join(fixtureChannelDir, "twoorgs.genesis.block"))
+	if err != nil {
+		t.Fatalf("reading genesis block failed: %s", err)
+	}
+	block := &cb.Block{}
+	if err := proto.Unmarshal(blockBytes, block); err != nil {
+		t.Fatalf("decoding genesis block failed: %s", err)
+	}
+	configEnvelope, err := resource.CreateConfigEnvelope(block.Data.Data[0])
+	if err != nil {
+		t.Fatalf("decoding genesis config failed: %s", err)
+	}
+	channel := configEnvelope.Config.ChannelGroup
+
+	update := fixtureConfigUpdate(t, fixtureChannelID+".tx")
+	consortium := &cb.Consortium{}
+	if err := proto.Unmarshal(update.WriteSet.Values[configtxgen.ConsortiumKey].Value, consortium); err != nil {
+		t.Fatalf("decoding consortium failed: %s", err)
+	}
+	consortiumOrgs := channel.Groups[configtxgen.ConsortiumsGroupKey].Groups[consortium.Name].Groups
+	application := update.WriteSet.Groups[configtxgen.ApplicationGroupKey]
+	for name := range application.Groups {
+		application.Groups[name] = consortiumOrgs[name]
+	}
+	delete(channel.Groups, configtxgen.ConsortiumsGroupKey)
+	channel.Groups[configtxgen.ApplicationGroupKey] = application
+	channel.Values[configtxgen.ConsortiumKey] = update.WriteSet.Values[configtxgen.ConsortiumKey]
+
+	current := &cb.Config{ChannelGroup: channel}
+	return &ChannelConfig{
+		ChannelID: fixtureChannelID,
+		Orderer:   ordererEndpoint,
+		Config:    proto.Clone(current).(*cb.Config),
+		current:   current,
+	}
+}
+
+// fixtureConfigUpdate decodes the config update of a channel transaction fixture
+func fixtureConfigUpdate(t *testing.T, tx string) *cb.ConfigUpdate {
+	envelopeBytes, err := ioutil.ReadFile(filepath.Join(fixtureChannelDir, tx))
+	if err != nil {
+		t.Fatalf("reading %s failed: %s", tx, err)
+	}
+	envelope := &cb.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		t.Fatalf("decoding %s failed: %s", tx, err)
+	}
+	payload, err := utils.GetPayload(envelope)
+	if err != nil {
+		t.Fatalf("decoding payload of %s failed: %s", tx, err)
+	}
+	updateEnvelope := &cb.ConfigUpdateEnvelope{}
+	if err := proto.Unmarshal(payload.Data, updateEnvelope); err != nil {
+		t.Fatalf("decoding config update envelope of %s failed: %s", tx, err)
+	}
+	update := &cb.ConfigUpdate{}
+	if err := proto.Unmarshal(updateEnvelope.ConfigUpdate, update); err != nil {
+		t.Fatalf("decoding config update of %s failed: %s", tx, err)
+	}
+	return update
+}
+
+// configValue decodes a value of the edited config at the given group path
+func configValue(t *testing.T, c *ChannelConfig, groupPath, key string, msg proto.Message) {
+	group, err := c.group(groupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, ok := group.Values[key]
+	if !ok {
+		t.Fatalf("config group [%s] has no value %s", groupPath, key)
+	}
+	if err := proto.Unmarshal(value.Value, msg); err != nil {
+		t.Fatalf("decoding value %s failed: %s", key, err)
+	}
+}
+
+func TestChannelConfigEdits(t *testing.T) {
+	mspDir := filepath.Join(CryptoConfigPath, "peerOrganizations/org1.example.com/msp")
+	const signPolicy = "OR('Org1MSP.admin','Org2MSP.admin')"
+
+	tests := []struct {
+		name      string
+		edit      func(c *ChannelConfig) error
+		errText   string
+		wantPaths []string
+		verify    func(t *testing.T, c *ChannelConfig)
+	}{
+		{
+			name:      "add org",
+			edit:      func(c *ChannelConfig) error { return c.AddOrg("Org3MSP", mspDir, "peer0.org3.example.com:7051") },
+			wantPaths: []string{"/Channel/Application", "/Channel/Application/Org3MSP"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				anchors := &pp.AnchorPeers{}
+				configValue(t, c, "Application/Org3MSP", configtxgen.AnchorPeersKey, anchors)
+				if len(anchors.AnchorPeers) != 1 || anchors.AnchorPeers[0].Host != "peer0.org3.example.com" || anchors.AnchorPeers[0].Port != 7051 {
+					t.Fatalf("unexpected anchor peers %v", anchors.AnchorPeers)
+				}
+			},
+		},
+		{name: "add member org", edit: func(c *ChannelConfig) error { return c.AddOrg("Org1MSP", mspDir) }, errText: "already a member"},
+		{name: "add org with bad anchor peer", edit: func(c *ChannelConfig) error { return c.AddOrg("Org3MSP", mspDir, "peer0") }, errText: "expecting host:port"},
+		{name: "add org with bad anchor port", edit: func(c *ChannelConfig) error { return c.AddOrg("Org3MSP", mspDir, "peer0:x") }, errText: "invalid port"},
+		{name: "add org with missing MSP", edit: func(c *ChannelConfig) error { return c.AddOrg("Org3MSP", "missing") }, errText: "missing"},
+		{
+			name:      "batch size",
+			edit:      func(c *ChannelConfig) error { return c.SetBatchSize(20, 0, 0) },
+			wantPaths: []string{"/Channel/Orderer/BatchSize"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				batchSize, original := &ab.BatchSize{}, &ab.BatchSize{}
+				configValue(t, c, configtxgen.OrdererGroupKey, configtxgen.BatchSizeKey, batchSize)
+				configValue(t, &ChannelConfig{Config: c.current}, configtxgen.OrdererGroupKey, configtxgen.BatchSizeKey, original)
+				original.MaxMessageCount = 20
+				if !proto.Equal(batchSize, original) {
+					t.Fatalf("expected only the max message count to change to %v, got %v", original, batchSize)
+				}
+			},
+		},
+		{name: "batch size preferred over absolute", edit: func(c *ChannelConfig) error { return c.SetBatchSize(0, 1, 0) }, errText: "exceeds absolute max bytes"},
+		{name: "unchanged batch size", edit: func(c *ChannelConfig) error { return c.SetBatchSize(0, 0, 0) }, errText: configtxgen.ErrNoDifferences.Error()},
+		{
+			name:      "batch timeout",
+			edit:      func(c *ChannelConfig) error { return c.SetBatchTimeout(5 * time.Second) },
+			wantPaths: []string{"/Channel/Orderer/BatchTimeout"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				timeout := &ab.BatchTimeout{}
+				configValue(t, c, configtxgen.OrdererGroupKey, configtxgen.BatchTimeoutKey, timeout)
+				if timeout.Timeout != "5s" {
+					t.Fatalf("expected batch timeout 5s, got %s", timeout.Timeout)
+				}
+			},
+		},
+		{name: "invalid batch timeout", edit: func(c *ChannelConfig) error { return c.SetBatchTimeout(0) }, errText: "invalid batch timeout"},
+		{
+			name: "batch size and timeout",
+			edit: func(c *ChannelConfig) error {
+				if err := c.SetBatchTimeout(time.Second); err != nil {
+					return err
+				}
+				return c.SetBatchSize(0, 0, 1024)
+			},
+			wantPaths: []string{"/Channel/Orderer/BatchSize", "/Channel/Orderer/BatchTimeout"},
+		},
+		{
+			name:      "set ACL",
+			edit:      func(c *ChannelConfig) error { return c.SetACL("qscc/GetChainInfo", "/Channel/Application/Admins") },
+			wantPaths: []string{"/Channel/Application/ACLs"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				acls := &pp.ACLs{}
+				configValue(t, c, configtxgen.ApplicationGroupKey, configtxgen.ACLsKey, acls)
+				if acls.Acls["qscc/GetChainInfo"].GetPolicyRef() != "/Channel/Application/Admins" {
+					t.Fatalf("expected the ACL of qscc/GetChainInfo to be set, got %v", acls.Acls)
+				}
+			},
+		},
+		{
+			name: "remove ACL",
+			edit: func(c *ChannelConfig) error {
+				if err := c.SetACL("qscc/GetChainInfo", "/Channel/Application/Admins"); err != nil {
+					return err
+				}
+				return c.SetACL("qscc/GetChainInfo", "")
+			},
+			wantPaths: []string{"/Channel/Application/ACLs"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				acls := &pp.ACLs{}
+				configValue(t, c, configtxgen.ApplicationGroupKey, configtxgen.ACLsKey, acls)
+				if _, ok := acls.Acls["qscc/GetChainInfo"]; ok {
+					t.Fatalf("expected the ACL of qscc/GetChainInfo to be removed, got %v", acls.Acls)
+				}
+			},
+		},
+		{
+			name: "set org policy",
+			edit: func(c *ChannelConfig) error {
+				return c.SetPolicy("Application/Org1MSP", configtxgen.AdminsPolicyKey, signPolicy)
+			},
+			wantPaths: []string{"/Channel/Application/Org1MSP/Admins"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				group, _ := c.group("Application/Org1MSP")
+				current := c.current.ChannelGroup.Groups[configtxgen.ApplicationGroupKey].Groups["Org1MSP"]
+				if group.Policies[configtxgen.AdminsPolicyKey].ModPolicy != current.Policies[configtxgen.AdminsPolicyKey].ModPolicy {
+					t.Fatal("expected the mod policy of the replaced policy to be kept")
+				}
+			},
+		},
+		{
+			name:      "add channel policy",
+			edit:      func(c *ChannelConfig) error { return c.SetPolicy("", "Custom", signPolicy) },
+			wantPaths: []string{"/Channel", "/Channel/Custom"},
+			verify: func(t *testing.T, c *ChannelConfig) {
+				if policy := c.Config.ChannelGroup.Policies["Custom"]; policy == nil || policy.ModPolicy != configtxgen.AdminsPolicyKey {
+					t.Fatalf("expected a new policy administered by Admins, got %v", policy)
+				}
+			},
+		},
+		{name: "invalid policy", edit: func(c *ChannelConfig) error { return c.SetPolicy("Application", "Custom", "OR(") }, errText: "OR("},
+		{name: "unknown group", edit: func(c *ChannelConfig) error { return c.SetPolicy("Application/Org9MSP", "Custom", signPolicy) }, errText: "config group [Application/Org9MSP] not found"},
+		{name: "unchanged", edit: func(c *ChannelConfig) error { return nil }, errText: configtxgen.ErrNoDifferences.Error()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := fixtureChannelConfig(t)
+			original := proto.Clone(c.current).(*cb.Config)
+
+			err := tc.edit(c)
+			var update *cb.ConfigUpdate
+			if err == nil {
+				update, err = c.Update()
+			}
+			if !proto.Equal(c.current, original) {
+				t.Fatal("expected the current config to be left unchanged")
+			}
+			if tc.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errText) {
+					t.Fatalf("expected error containing [%s], got %v", tc.errText, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("updating config failed: %s", err)
+			}
+
+			if update.ChannelId != fixtureChannelID {
+				t.Fatalf("expected the update of channel %s, got %s", fixtureChannelID, update.ChannelId)
+			}
+			if paths := ConfigUpdatePaths(update); !reflect.DeepEqual(paths, tc.wantPaths) {
+				t.Fatalf("expected paths %v, got %v", tc.wantPaths, paths)
+			}
+			if tc.verify != nil {
+				tc.verify(t, c)
+			}
+		})
+	}
+}
+
+func TestUpdateNoDifferences(t *testing.T) {
+	c := fixtureChannelConfig(t)
+	if err := c.SetBatchTimeout(time.Second); err != nil {
+		t.Fatalf("setting batch timeout failed: %s", err)
+	}
+	// Setting the current timeout again leaves nothing to update
+	timeout := &ab.BatchTimeout{}
+	configValue(t, &ChannelConfig{Config: c.current}, configtxgen.OrdererGroupKey, configtxgen.BatchTimeoutKey, timeout)
+	current, err := time.ParseDuration(timeout.Timeout)
+	if err != nil {
+		t.Fatalf("invalid batch timeout of fixture: %s", err)
+	}
+	if err := c.SetBatchTimeout(current); err != nil {
+		t.Fatalf("setting batch timeout failed: %s", err)
+	}
+	if _, err := c.Update(); err != configtxgen.ErrNoDifferences {
+		t.Fatalf("expected ErrNoDifferences, got %v", err)
+	}
+}
+
+func TestConfigUpdatePaths(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   string
+		want []string
+	}{
+		// A new channel writes its application group with its values and policies, the orgs
+		// of the consortium are only read
+		{"channel creation", fixtureChannelID + ".tx", []string{
+			"/Channel/Application", "/Channel/Application/ACLs", "/Channel/Application/Admins", "/Channel/Application/Capabilities",
+			"/Channel/Application/Org1MemberPolicy", "/Channel/Application/Org1Org2MemberPolicy", "/Channel/Application/Org2MemberPolicy",
+			"/Channel/Application/Readers", "/Channel/Application/Writers",
+		}},
+		// An anchor peer update writes the anchor peers of its org
+		{"anchor peers", fixtureChannelID + "Org1MSPanchors.tx", []string{"/Channel/Application/Org1MSP", "/Channel/Application/Org1MSP/AnchorPeers"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if paths := ConfigUpdatePaths(fixtureConfigUpdate(t, tc.tx)); !reflect.DeepEqual(paths, tc.want) {
+				t.Fatalf("expected paths %v, got %v", tc.want, paths)
+			}
+		})
+	}
+}
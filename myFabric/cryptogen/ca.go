@@ -0,0 +1,163 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cryptogen
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// certValidity is how long generated certificates are valid
+const certValidity = 10 * 365 * 24 * time.Hour
+
+// CA is a signing or TLS CA of an org. Its key is written to its directory as <SKI>_sk and
+// its certificate as <common name>-cert.pem.
+type CA struct {
+	Name   string
+	Cert   *x509.Certificate
+	Signer crypto.Signer
+	spec   NodeSpec
+}
+
+// NewCA generates the key and self-signed certificate of a CA of an org into a directory
+func NewCA(dir, orgDomain string, spec NodeSpec) (*CA, error) {
+	priv, _, err := generateKey(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	template := x509Template(spec)
+	template.Subject.Organization = []string{orgDomain}
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment |
+		x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
+	template.IsCA = true
+	template.SubjectKeyId = subjectKeyID(&priv.PublicKey)
+
+	certFile := filepath.Join(dir, spec.CommonName+"-cert.pem")
+	cert, err := writeCert(certFile, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Name: spec.CommonName, Cert: cert, Signer: priv, spec: spec}, nil
+}
+
+// SignCertificate issues a certificate to a public key and writes it to a file. The subject is
+// the one of the CA with the given common name and OUs, SANS may be host names or IPs.
+func (ca *CA) SignCertificate(certFile, name string, ous, sans []string, pub *ecdsa.PublicKey,
+	keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage) (*x509.Certificate, error) {
+	template := x509Template(ca.spec)
+	template.Subject.CommonName = name
+	template.Subject.OrganizationalUnit = append(template.Subject.OrganizationalUnit, ous...)
+	template.KeyUsage = keyUsage
+	template.ExtKeyUsage = extKeyUsage
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	return writeCert(certFile, template, ca.Cert, pub, ca.Signer)
+}
+
+// x509Template returns a certificate template with a random serial number and the subject
+// of a node spec
+func x509Template(spec NodeSpec) *x509.Certificate {
+	serialNumber, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	now := time.Now().Round(time.Minute)
+	subject := pkix.Name{
+		Country:    []string{spec.Country},
+		Province:   []string{spec.Province},
+		Locality:   []string{spec.Locality},
+		CommonName: spec.CommonName,
+	}
+	if spec.OrganizationalUnit != "" {
+		subject.OrganizationalUnit = []string{spec.OrganizationalUnit}
+	}
+	return &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		NotBefore:             now,
+		NotAfter:              now.Add(certValidity),
+		BasicConstraintsValid: true,
+	}
+}
+
+// generateKey generates an ECDSA P-256 key and writes it to a directory as <SKI>_sk
+func generateKey(dir string) (*ecdsa.PrivateKey, string, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "generating key failed")
+	}
+	keyFile := filepath.Join(dir, hex.EncodeToString(subjectKeyID(&priv.PublicKey))+"_sk")
+	if err := writePrivateKey(keyFile, priv); err != nil {
+		return nil, "", err
+	}
+	return priv, keyFile, nil
+}
+
+// subjectKeyID is the SHA256 of the public key point, the SKI Fabric uses for key files
+func subjectKeyID(pub *ecdsa.PublicKey) []byte {
+	ski := sha256.Sum256(elliptic.Marshal(pub.Curve, pub.X, pub.Y))
+	return ski[:]
+}
+
+// writePrivateKey writes a key as PKCS#8 PEM readable only by the owner
+func writePrivateKey(file string, priv *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return errors.Wrap(err, "marshal private key failed")
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errors.Wrap(err, "creating key directory failed")
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return errors.Wrapf(ioutil.WriteFile(file, data, 0600), "writing key [%s] failed", file)
+}
+
+// writeCert creates a certificate and writes it to a file
+func writeCert(certFile string, template, parent *x509.Certificate, pub *ecdsa.PublicKey,
+	signer crypto.Signer) (*x509.Certificate, error) {
+	name := template.Subject.CommonName
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating certificate [%s] failed", name)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing certificate [%s] failed", name)
+	}
+	if err := writePEM(certFile, cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// writePEM writes a certificate as PEM, creating the directory of the file
+func writePEM(file string, cert *x509.Certificate) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errors.Wrap(err, "creating certificate directory failed")
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	return errors.Wrapf(ioutil.WriteFile(file, data, 0644), "writing certificate [%s] failed", file)
+}
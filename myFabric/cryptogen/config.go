@@ -0,0 +1,176 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cryptogen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"text/template"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Defaults of the subject of the certificates of an org, the ones the fixtures were
+// generated with
+const (
+	defaultCountry  = "US"
+	defaultProvince = "California"
+	defaultLocality = "San Francisco"
+
+	defaultHostnameTemplate   = "{{.Prefix}}{{.Index}}"
+	defaultCommonNameTemplate = "{{.Hostname}}.{{.Domain}}"
+)
+
+// Config is cryptogen.yaml
+type Config struct {
+	OrdererOrgs []*OrgSpec `yaml:"OrdererOrgs"`
+	PeerOrgs    []*OrgSpec `yaml:"PeerOrgs"`
+}
+
+// OrgSpec is an org of cryptogen.yaml. Its nodes are the ones of Specs followed by Count
+// nodes of Template.
+type OrgSpec struct {
+	Name          string       `yaml:"Name"`
+	Domain        string       `yaml:"Domain"`
+	EnableNodeOUs bool         `yaml:"EnableNodeOUs"`
+	CA            NodeSpec     `yaml:"CA"`
+	Template      NodeTemplate `yaml:"Template"`
+	Specs         []NodeSpec   `yaml:"Specs"`
+	Users         UsersSpec    `yaml:"Users"`
+}
+
+// NodeTemplate generates Count nodes named from Hostname, {{.Prefix}}{{.Index}} by default
+type NodeTemplate struct {
+	Count    int      `yaml:"Count"`
+	Start    int      `yaml:"Start"`
+	Hostname string   `yaml:"Hostname"`
+	SANS     []string `yaml:"SANS"`
+}
+
+// NodeSpec is a node or the CA of an org. CommonName and SANS are templates of
+// {{.Hostname}} and {{.Domain}}, the common name is {{.Hostname}}.{{.Domain}} by default.
+type NodeSpec struct {
+	Hostname           string   `yaml:"Hostname"`
+	CommonName         string   `yaml:"CommonName"`
+	Country            string   `yaml:"Country"`
+	Province           string   `yaml:"Province"`
+	Locality           string   `yaml:"Locality"`
+	OrganizationalUnit string   `yaml:"OrganizationalUnit"`
+	SANS               []string `yaml:"SANS"`
+}
+
+// UsersSpec is the number of users of an org besides its admin
+type UsersSpec struct {
+	Count int `yaml:"Count"`
+}
+
+// LoadConfig reads cryptogen.yaml and expands the templates of the node specs
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading cryptogen config [%s] failed", configPath)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrapf(err, "parsing cryptogen config [%s] failed", configPath)
+	}
+
+	for _, org := range config.OrdererOrgs {
+		if err := org.expand("orderer"); err != nil {
+			return nil, err
+		}
+	}
+	for _, org := range config.PeerOrgs {
+		if err := org.expand("peer"); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// expand appends the nodes of the template to the specs and resolves the names of the
+// nodes and the CA
+func (o *OrgSpec) expand(prefix string) error {
+	if o.Name == "" || o.Domain == "" {
+		return errors.New("org of cryptogen config needs a name and a domain")
+	}
+
+	hostnameTemplate := o.Template.Hostname
+	if hostnameTemplate == "" {
+		hostnameTemplate = defaultHostnameTemplate
+	}
+	for i := o.Template.Start; i < o.Template.Start+o.Template.Count; i++ {
+		hostname, err := parseTemplate(hostnameTemplate, struct {
+			Prefix string
+			Index  int
+			Domain string
+		}{prefix, i, o.Domain})
+		if err != nil {
+			return err
+		}
+		o.Specs = append(o.Specs, NodeSpec{Hostname: hostname, SANS: o.Template.SANS})
+	}
+
+	for i := range o.Specs {
+		if err := o.Specs[i].expand(o.Domain); err != nil {
+			return err
+		}
+	}
+	if o.CA.Hostname == "" {
+		o.CA.Hostname = "ca"
+	}
+	return o.CA.expand(o.Domain)
+}
+
+// expand resolves the common name and SANS of the node and defaults its subject
+func (n *NodeSpec) expand(domain string) error {
+	if n.Hostname == "" {
+		return errors.Errorf("node of org [%s] needs a hostname", domain)
+	}
+	data := struct{ Hostname, Domain string }{n.Hostname, domain}
+
+	commonNameTemplate := n.CommonName
+	if commonNameTemplate == "" {
+		commonNameTemplate = defaultCommonNameTemplate
+	}
+	var err error
+	if n.CommonName, err = parseTemplate(commonNameTemplate, data); err != nil {
+		return err
+	}
+	sans := make([]string, len(n.SANS))
+	for i, san := range n.SANS {
+		if sans[i], err = parseTemplate(san, data); err != nil {
+			return err
+		}
+	}
+	n.SANS = sans
+
+	if n.Country == "" {
+		n.Country = defaultCountry
+	}
+	if n.Province == "" {
+		n.Province = defaultProvince
+	}
+	if n.Locality == "" {
+		n.Locality = defaultLocality
+	}
+	return nil
+}
+
+// parseTemplate executes a template of cryptogen.yaml
+func parseTemplate(text string, data interface{}) (string, error) {
+	t, err := template.New("cryptogen").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing template [%s] failed", text)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "executing template [%s] failed", text)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package cryptogen generates the crypto material of the orgs of cryptogen.yaml, the way the
+// cryptogen tool of Fabric does: signing and TLS CAs of every org, the MSP of the org, and
+// the MSP and TLS material of its nodes, admin and users.
+package cryptogen
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PeerOrgDir returns the directory of the material of a peer org in an output directory
+func PeerOrgDir(outDir, domain string) string {
+	return filepath.Join(outDir, "peerOrganizations", domain)
+}
+
+// OrdererOrgDir returns the directory of the material of an orderer org in an output directory
+func OrdererOrgDir(outDir, domain string) string {
+	return filepath.Join(outDir, "ordererOrganizations", domain)
+}
+
+// Generate writes the material of the orgs of the config into an output directory and
+// returns the directories of the orgs it generated. Orgs whose directory exists are left
+// as they are, so orgs added to the config are generated next to the existing ones.
+func Generate(config *Config, outDir string) ([]string, error) {
+	var generated []string
+	for _, org := range config.OrdererOrgs {
+		orgDir := OrdererOrgDir(outDir, org.Domain)
+		ok, err := generateOrg(orgDir, "orderers", "", org)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("generating orderer org [%s] failed", org.Name))
+		}
+		if ok {
+			generated = append(generated, orgDir)
+		}
+	}
+	for _, org := range config.PeerOrgs {
+		orgDir := PeerOrgDir(outDir, org.Domain)
+		ok, err := generateOrg(orgDir, "peers", peerOU, org)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("generating peer org [%s] failed", org.Name))
+		}
+		if ok {
+			generated = append(generated, orgDir)
+		}
+	}
+	return generated, nil
+}
+
+// CAKeyFile returns the name of the key file of the signing CA of an org directory, the
+// file a Fabric CA server of the org is started with
+func CAKeyFile(orgDir string) (string, error) {
+	keyFiles, err := filepath.Glob(filepath.Join(orgDir, "ca", "*_sk"))
+	if err != nil {
+		return "", errors.Wrap(err, "listing CA keys failed")
+	}
+	if len(keyFiles) != 1 {
+		return "", errors.Errorf("expected one CA key in [%s], found %d", orgDir, len(keyFiles))
+	}
+	return filepath.Base(keyFiles[0]), nil
+}
+
+// generateOrg writes the CAs, MSP, nodes and users of an org unless its directory exists.
+// Nodes are written to nodesDir of the org and get nodeOU if the org enables node OUs.
+func generateOrg(orgDir, nodesDir, nodeOU string, org *OrgSpec) (bool, error) {
+	if _, err := os.Stat(orgDir); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, errors.Wrap(err, "checking org directory failed")
+	}
+
+	signCA, err := NewCA(filepath.Join(orgDir, "ca"), org.Domain, org.CA)
+	if err != nil {
+		return false, errors.WithMessage(err, "generating signing CA failed")
+	}
+	tlsSpec := org.CA
+	tlsSpec.CommonName = "tlsca." + org.Domain
+	tlsCA, err := NewCA(filepath.Join(orgDir, "tlsca"), org.Domain, tlsSpec)
+	if err != nil {
+		return false, errors.WithMessage(err, "generating TLS CA failed")
+	}
+
+	var nodeMSPDirs []string
+	for _, node := range org.Specs {
+		baseDir := filepath.Join(orgDir, nodesDir, node.CommonName)
+		sans := appendUnique([]string{node.CommonName, node.Hostname}, node.SANS...)
+		if _, err := generateLocalMSP(baseDir, node.CommonName, nodeOU, sans, signCA, tlsCA, org.EnableNodeOUs, true); err != nil {
+			return false, errors.WithMessage(err, fmt.Sprintf("generating node [%s] failed", node.CommonName))
+		}
+		nodeMSPDirs = append(nodeMSPDirs, filepath.Join(baseDir, "msp"))
+	}
+
+	users := []string{"Admin"}
+	for i := 1; i <= org.Users.Count; i++ {
+		users = append(users, fmt.Sprintf("User%d", i))
+	}
+	var admin *x509.Certificate
+	for _, user := range users {
+		name := user + "@" + org.Domain
+		cert, err := generateLocalMSP(filepath.Join(orgDir, "users", name), name, clientOU, nil, signCA, tlsCA, org.EnableNodeOUs, false)
+		if err != nil {
+			return false, errors.WithMessage(err, fmt.Sprintf("generating user [%s] failed", name))
+		}
+		if admin == nil {
+			admin = cert
+		}
+	}
+
+	for _, mspDir := range nodeMSPDirs {
+		if err := writeAdminCert(mspDir, admin); err != nil {
+			return false, err
+		}
+	}
+	if err := generateVerifyingMSP(filepath.Join(orgDir, "msp"), signCA, tlsCA, admin, org.EnableNodeOUs); err != nil {
+		return false, errors.WithMessage(err, "generating org MSP failed")
+	}
+	return true, nil
+}
+
+// appendUnique appends the values not in the slice yet, ignoring case like host names do
+func appendUnique(values []string, more ...string) []string {
+	for _, v := range more {
+		found := false
+		for _, existing := range values {
+			if strings.EqualFold(existing, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,299 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cryptogen
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const configPath = "../fixtures/fabric/v1/config/cryptogen.yaml"
+
+var tlsUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+func readCert(t *testing.T, file string) *x509.Certificate {
+	block, _ := pem.Decode(readFile(t, file))
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("[%s] is not a PEM certificate", file)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing certificate [%s] failed: %s", file, err)
+	}
+	return cert
+}
+
+func readFile(t *testing.T, file string) []byte {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("reading [%s] failed: %s", file, err)
+	}
+	return data
+}
+
+// listDir returns the sorted names of the files of a directory
+func listDir(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading directory [%s] failed: %s", dir, err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkKey verifies that a key file holds the private key of a certificate and is named by
+// the SKI of the key unless it has a fixed name
+func checkKey(t *testing.T, keyFile string, cert *x509.Certificate, named bool) {
+	block, _ := pem.Decode(readFile(t, keyFile))
+	if block == nil {
+		t.Fatalf("[%s] is not a PEM key", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing key [%s] failed: %s", keyFile, err)
+	}
+	priv, ok := key.(*ecdsa.PrivateKey)
+	pub, _ := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || pub == nil || priv.X.Cmp(pub.X) != 0 || priv.Y.Cmp(pub.Y) != 0 {
+		t.Fatalf("expected [%s] to be the key of %s", keyFile, cert.Subject.CommonName)
+	}
+	if named && filepath.Base(keyFile) != hex.EncodeToString(subjectKeyID(pub))+"_sk" {
+		t.Fatalf("expected key [%s] to be named by its SKI", keyFile)
+	}
+}
+
+// checkIssued verifies the issuer and the usages of a certificate
+func checkIssued(t *testing.T, cert, issuer *x509.Certificate, name string, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage) {
+	if err := cert.CheckSignatureFrom(issuer); err != nil {
+		t.Fatalf("expected %s to be issued by %s: %s", name, issuer.Subject.CommonName, err)
+	}
+	if cert.Subject.CommonName != name || cert.IsCA {
+		t.Fatalf("expected a leaf certificate of %s, got %s", name, cert.Subject)
+	}
+	if cert.KeyUsage != keyUsage || !reflect.DeepEqual(cert.ExtKeyUsage, extKeyUsage) {
+		t.Fatalf("unexpected usages of %s: %v %v", name, cert.KeyUsage, cert.ExtKeyUsage)
+	}
+}
+
+// checkCA verifies the self-signed CA and the key of a CA directory and returns its certificate
+func checkCA(t *testing.T, dir, name, domain string) *x509.Certificate {
+	cert := readCert(t, filepath.Join(dir, name+"-cert.pem"))
+	if err := cert.CheckSignatureFrom(cert); err != nil || !cert.IsCA {
+		t.Fatalf("expected %s to be a self-signed CA: %v", name, err)
+	}
+	if cert.Subject.CommonName != name || !reflect.DeepEqual(cert.Subject.Organization, []string{domain}) {
+		t.Fatalf("unexpected subject of %s: %s", name, cert.Subject)
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 ||
+		!reflect.DeepEqual(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}) {
+		t.Fatalf("unexpected usages of %s: %v %v", name, cert.KeyUsage, cert.ExtKeyUsage)
+	}
+	keys, err := filepath.Glob(filepath.Join(dir, "*_sk"))
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("expected one key of %s, got %v", name, keys)
+	}
+	checkKey(t, keys[0], cert, true)
+	return cert
+}
+
+// checkMSP verifies the CA certificates of an MSP directory and that its only admin
+// certificate is the given one
+func checkMSP(t *testing.T, mspDir, domain string, signCA, tlsCA *x509.Certificate, adminName string, adminPEM []byte) {
+	if got := readCert(t, filepath.Join(mspDir, "cacerts", "ca."+domain+"-cert.pem")); !got.Equal(signCA) {
+		t.Fatalf("unexpected CA certificate of [%s]", mspDir)
+	}
+	if got := readCert(t, filepath.Join(mspDir, "tlscacerts", "tlsca."+domain+"-cert.pem")); !got.Equal(tlsCA) {
+		t.Fatalf("unexpected TLS CA certificate of [%s]", mspDir)
+	}
+	adminFile := adminName + "-cert.pem"
+	if names := listDir(t, filepath.Join(mspDir, "admincerts")); !reflect.DeepEqual(names, []string{adminFile}) {
+		t.Fatalf("expected only the admin certificate in [%s], got %v", mspDir, names)
+	}
+	if !bytes.Equal(readFile(t, filepath.Join(mspDir, "admincerts", adminFile)), adminPEM) {
+		t.Fatalf("expected the admin certificate of [%s] to be the one of %s", mspDir, adminName)
+	}
+}
+
+// checkLocalMSP verifies the MSP and TLS material of a node or user and returns the PEM of
+// its signing certificate. Without adminPEM the identity must be its own admin.
+func checkLocalMSP(t *testing.T, baseDir, name, domain string, sans []string, signCA, tlsCA *x509.Certificate, adminPEM []byte, tlsServer bool) []byte {
+	mspDir := filepath.Join(baseDir, "msp")
+	if names := listDir(t, mspDir); !reflect.DeepEqual(names, []string{"admincerts", "cacerts", "keystore", "signcerts", "tlscacerts"}) {
+		t.Fatalf("unexpected MSP layout of %s: %v", name, names)
+	}
+	certFile := filepath.Join(mspDir, "signcerts", name+"-cert.pem")
+	cert := readCert(t, certFile)
+	checkIssued(t, cert, signCA, name, x509.KeyUsageDigitalSignature, nil)
+	if len(cert.DNSNames) != 0 {
+		t.Fatalf("expected no SANs in the signing certificate of %s, got %v", name, cert.DNSNames)
+	}
+	keys := listDir(t, filepath.Join(mspDir, "keystore"))
+	if len(keys) != 1 {
+		t.Fatalf("expected one key of %s, got %v", name, keys)
+	}
+	checkKey(t, filepath.Join(mspDir, "keystore", keys[0]), cert, true)
+	certPEM := readFile(t, certFile)
+	if adminPEM == nil {
+		checkMSP(t, mspDir, domain, signCA, tlsCA, name, certPEM)
+	} else {
+		checkMSP(t, mspDir, domain, signCA, tlsCA, "Admin@"+domain, adminPEM)
+	}
+
+	tlsDir := filepath.Join(baseDir, "tls")
+	prefix := "client"
+	if tlsServer {
+		prefix = "server"
+	}
+	if names := listDir(t, tlsDir); !reflect.DeepEqual(names, []string{"ca.crt", prefix + ".crt", prefix + ".key"}) {
+		t.Fatalf("unexpected TLS layout of %s: %v", name, names)
+	}
+	if !readCert(t, filepath.Join(tlsDir, "ca.crt")).Equal(tlsCA) {
+		t.Fatalf("expected the TLS CA certificate of %s", name)
+	}
+	tlsCert := readCert(t, filepath.Join(tlsDir, prefix+".crt"))
+	checkIssued(t, tlsCert, tlsCA, name, x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment, tlsUsage)
+	if !reflect.DeepEqual(tlsCert.DNSNames, sans) {
+		t.Fatalf("expected SANs %v of %s, got %v", sans, name, tlsCert.DNSNames)
+	}
+	checkKey(t, filepath.Join(tlsDir, prefix+".key"), tlsCert, false)
+	return certPEM
+}
+
+func TestGenerate(t *testing.T) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loading config failed: %s", err)
+	}
+	outDir := t.TempDir()
+	generated, err := Generate(config, outDir)
+	if err != nil {
+		t.Fatalf("generating crypto material failed: %s", err)
+	}
+
+	orgs := []struct {
+		dir      string
+		domain   string
+		nodesDir string
+		nodes    []string
+		users    []string
+	}{
+		{OrdererOrgDir(outDir, "example.com"), "example.com", "orderers", []string{"orderer"}, []string{"Admin"}},
+		{PeerOrgDir(outDir, "org1.example.com"), "org1.example.com", "peers", []string{"ca", "peer0", "peer1"}, []string{"Admin", "User1"}},
+		{PeerOrgDir(outDir, "org2.example.com"), "org2.example.com", "peers", []string{"ca", "peer0", "peer1"}, []string{"Admin", "User1"}},
+		{PeerOrgDir(outDir, "tls.example.com"), "tls.example.com", "peers", []string{"ca"}, []string{"Admin", "User1"}},
+	}
+	var wantGenerated []string
+	for _, org := range orgs {
+		wantGenerated = append(wantGenerated, org.dir)
+	}
+	if !reflect.DeepEqual(generated, wantGenerated) {
+		t.Fatalf("expected generated orgs %v, got %v", wantGenerated, generated)
+	}
+
+	for _, org := range orgs {
+		t.Run(org.domain, func(t *testing.T) {
+			if names := listDir(t, org.dir); !reflect.DeepEqual(names, []string{"ca", "msp", org.nodesDir, "tlsca", "users"}) {
+				t.Fatalf("unexpected layout of org %s: %v", org.domain, names)
+			}
+			signCA := checkCA(t, filepath.Join(org.dir, "ca"), "ca."+org.domain, org.domain)
+			tlsCA := checkCA(t, filepath.Join(org.dir, "tlsca"), "tlsca."+org.domain, org.domain)
+			if keyFile, err := CAKeyFile(org.dir); err != nil || keyFile != hex.EncodeToString(signCA.SubjectKeyId)+"_sk" {
+				t.Fatalf("expected the key file of the signing CA, got %s: %v", keyFile, err)
+			}
+
+			// Users are their own admins, the nodes and the org MSP name the org admin
+			var userNames []string
+			for _, user := range org.users {
+				userNames = append(userNames, user+"@"+org.domain)
+			}
+			if names := listDir(t, filepath.Join(org.dir, "users")); !reflect.DeepEqual(names, userNames) {
+				t.Fatalf("expected users %v, got %v", userNames, names)
+			}
+			adminPEM := checkLocalMSP(t, filepath.Join(org.dir, "users", userNames[0]), userNames[0], org.domain, nil, signCA, tlsCA, nil, false)
+			for _, user := range userNames[1:] {
+				checkLocalMSP(t, filepath.Join(org.dir, "users", user), user, org.domain, nil, signCA, tlsCA, nil, false)
+			}
+
+			var nodeNames []string
+			for _, node := range org.nodes {
+				nodeNames = append(nodeNames, node+"."+org.domain)
+			}
+			if names := listDir(t, filepath.Join(org.dir, org.nodesDir)); !reflect.DeepEqual(names, nodeNames) {
+				t.Fatalf("expected nodes %v, got %v", nodeNames, names)
+			}
+			for i, node := range nodeNames {
+				sans := []string{node, org.nodes[i]}
+				checkLocalMSP(t, filepath.Join(org.dir, org.nodesDir, node), node, org.domain, sans, signCA, tlsCA, adminPEM, true)
+			}
+
+			// The org MSP is what configtx.yaml reads, it has no keys
+			mspDir := filepath.Join(org.dir, "msp")
+			if names := listDir(t, mspDir); !reflect.DeepEqual(names, []string{"admincerts", "cacerts", "tlscacerts"}) {
+				t.Fatalf("unexpected layout of the MSP of org %s: %v", org.domain, names)
+			}
+			checkMSP(t, mspDir, org.domain, signCA, tlsCA, userNames[0], adminPEM)
+		})
+	}
+
+	// Existing orgs are left alone
+	caCert := readFile(t, filepath.Join(orgs[1].dir, "ca", "ca.org1.example.com-cert.pem"))
+	generated, err = Generate(config, outDir)
+	if err != nil || len(generated) != 0 {
+		t.Fatalf("expected no org to be generated again, got %v: %v", generated, err)
+	}
+	if !bytes.Equal(readFile(t, filepath.Join(orgs[1].dir, "ca", "ca.org1.example.com-cert.pem")), caCert) {
+		t.Fatal("expected the CA of an existing org to be kept")
+	}
+}
+
+func TestGenerateNodeOUs(t *testing.T) {
+	config := &Config{PeerOrgs: []*OrgSpec{{
+		Name:          "Org3",
+		Domain:        "org3.example.com",
+		EnableNodeOUs: true,
+		Specs:         []NodeSpec{{Hostname: "peer0", SANS: []string{"{{.Hostname}}.alt.{{.Domain}}", "127.0.0.1"}}},
+	}}}
+	if err := config.PeerOrgs[0].expand("peer"); err != nil {
+		t.Fatalf("expanding org failed: %s", err)
+	}
+	outDir := t.TempDir()
+	if _, err := Generate(config, outDir); err != nil {
+		t.Fatalf("generating crypto material failed: %s", err)
+	}
+	orgDir := PeerOrgDir(outDir, "org3.example.com")
+
+	peerDir := filepath.Join(orgDir, "peers", "peer0.org3.example.com")
+	peer := readCert(t, filepath.Join(peerDir, "msp", "signcerts", "peer0.org3.example.com-cert.pem"))
+	admin := readCert(t, filepath.Join(orgDir, "users", "Admin@org3.example.com", "msp", "signcerts", "Admin@org3.example.com-cert.pem"))
+	if !reflect.DeepEqual(peer.Subject.OrganizationalUnit, []string{peerOU}) || !reflect.DeepEqual(admin.Subject.OrganizationalUnit, []string{clientOU}) {
+		t.Fatalf("expected the node OUs, got %v and %v", peer.Subject.OrganizationalUnit, admin.Subject.OrganizationalUnit)
+	}
+	for _, mspDir := range []string{filepath.Join(orgDir, "msp"), filepath.Join(peerDir, "msp")} {
+		if data := readFile(t, filepath.Join(mspDir, "config.yaml")); !bytes.Contains(data, []byte("OrganizationalUnitIdentifier: peer")) {
+			t.Fatalf("expected node OUs in the config.yaml of [%s], got %s", mspDir, data)
+		}
+	}
+
+	// SANs are templates and may be IPs
+	tlsCert := readCert(t, filepath.Join(peerDir, "tls", "server.crt"))
+	wantDNS := []string{"peer0.org3.example.com", "peer0", "peer0.alt.org3.example.com"}
+	if !reflect.DeepEqual(tlsCert.DNSNames, wantDNS) || len(tlsCert.IPAddresses) != 1 || tlsCert.IPAddresses[0].String() != "127.0.0.1" {
+		t.Fatalf("expected SANs %v and 127.0.0.1, got %v %v", wantDNS, tlsCert.DNSNames, tlsCert.IPAddresses)
+	}
+}
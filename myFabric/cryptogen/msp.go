@@ -0,0 +1,135 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cryptogen
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Node OUs of the certificates of an org with EnableNodeOUs
+const (
+	clientOU = "client"
+	peerOU   = "peer"
+)
+
+// tlsExtKeyUsage is the extended key usage of TLS certificates, they serve both ends
+var tlsExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+// nodeOUsConfig is the config.yaml written to MSP directories of an org with EnableNodeOUs
+type nodeOUsConfig struct {
+	NodeOUs struct {
+		Enable             bool         `yaml:"Enable"`
+		ClientOUIdentifier ouIdentifier `yaml:"ClientOUIdentifier"`
+		PeerOUIdentifier   ouIdentifier `yaml:"PeerOUIdentifier"`
+	} `yaml:"NodeOUs"`
+}
+
+type ouIdentifier struct {
+	Certificate                  string `yaml:"Certificate"`
+	OrganizationalUnitIdentifier string `yaml:"OrganizationalUnitIdentifier"`
+}
+
+// generateLocalMSP writes the MSP and TLS material of a node or user into baseDir/msp and
+// baseDir/tls. The MSP identity is signed by signCA with the given OU if nodeOUs is set, the
+// TLS certificate by tlsCA with the SANS. Server TLS files are named server.*, client ones
+// client.*. The identity is its own admin until the admin certificate is copied over it.
+func generateLocalMSP(baseDir, name, ou string, sans []string, signCA, tlsCA *CA,
+	nodeOUs, tlsServer bool) (*x509.Certificate, error) {
+	mspDir := filepath.Join(baseDir, "msp")
+	if err := writeCACerts(mspDir, signCA, tlsCA, nodeOUs); err != nil {
+		return nil, err
+	}
+
+	priv, _, err := generateKey(filepath.Join(mspDir, "keystore"))
+	if err != nil {
+		return nil, err
+	}
+	var ous []string
+	if nodeOUs && ou != "" {
+		ous = []string{ou}
+	}
+	certName := name + "-cert.pem"
+	cert, err := signCA.SignCertificate(filepath.Join(mspDir, "signcerts", certName), name, ous, nil,
+		&priv.PublicKey, x509.KeyUsageDigitalSignature, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(filepath.Join(mspDir, "admincerts", certName), cert); err != nil {
+		return nil, err
+	}
+
+	tlsDir := filepath.Join(baseDir, "tls")
+	tlsPriv, tlsKeyFile, err := generateKey(tlsDir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := "client"
+	if tlsServer {
+		prefix = "server"
+	}
+	if _, err := tlsCA.SignCertificate(filepath.Join(tlsDir, prefix+".crt"), name, nil, sans, &tlsPriv.PublicKey,
+		x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment, tlsExtKeyUsage); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tlsKeyFile, filepath.Join(tlsDir, prefix+".key")); err != nil {
+		return nil, errors.Wrap(err, "renaming TLS key failed")
+	}
+	if err := writePEM(filepath.Join(tlsDir, "ca.crt"), tlsCA.Cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// generateVerifyingMSP writes the MSP of an org, the one configtx.yaml reads: the CA
+// certificates and the admin certificate
+func generateVerifyingMSP(mspDir string, signCA, tlsCA *CA, admin *x509.Certificate, nodeOUs bool) error {
+	if err := writeCACerts(mspDir, signCA, tlsCA, nodeOUs); err != nil {
+		return err
+	}
+	return writeAdminCert(mspDir, admin)
+}
+
+// writeCACerts writes the CA certificates of an MSP directory and config.yaml if the org has
+// node OUs
+func writeCACerts(mspDir string, signCA, tlsCA *CA, nodeOUs bool) error {
+	caCertFile := filepath.Join("cacerts", signCA.Name+"-cert.pem")
+	if err := writePEM(filepath.Join(mspDir, caCertFile), signCA.Cert); err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(mspDir, "tlscacerts", tlsCA.Name+"-cert.pem"), tlsCA.Cert); err != nil {
+		return err
+	}
+	if !nodeOUs {
+		return nil
+	}
+
+	config := &nodeOUsConfig{}
+	config.NodeOUs.Enable = true
+	config.NodeOUs.ClientOUIdentifier = ouIdentifier{Certificate: caCertFile, OrganizationalUnitIdentifier: clientOU}
+	config.NodeOUs.PeerOUIdentifier = ouIdentifier{Certificate: caCertFile, OrganizationalUnitIdentifier: peerOU}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return errors.Wrap(err, "marshal MSP config.yaml failed")
+	}
+	return errors.Wrap(ioutil.WriteFile(filepath.Join(mspDir, "config.yaml"), data, 0644), "writing MSP config.yaml failed")
+}
+
+// writeAdminCert replaces the admin certificates of an MSP directory with the one of the
+// org admin
+func writeAdminCert(mspDir string, admin *x509.Certificate) error {
+	adminDir := filepath.Join(mspDir, "admincerts")
+	if err := os.RemoveAll(adminDir); err != nil {
+		return errors.Wrap(err, "removing admin certificates failed")
+	}
+	return writePEM(filepath.Join(adminDir, admin.Subject.CommonName+"-cert.pem"), admin)
+}
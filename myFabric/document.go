@@ -0,0 +1,185 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// DefaultDocumentPageSize is the number of documents returned by a query page if none is given
+const DefaultDocumentPageSize = 50
+
+// Document is a JSON record of example CC stored under the composite key (type, owner, id).
+// The owner is the common name of the certificate of the client the document belongs to.
+type Document struct {
+	DocType string          `json:"docType"`
+	Owner   string          `json:"owner"`
+	ID      string          `json:"id"`
+	Data    json.RawMessage `json:"data"`
+	// Creator is set by example CC to the client that wrote the document
+	Creator *KeyOwner `json:"creator,omitempty"`
+}
+
+// DocumentPage is a page of documents returned by a document query
+type DocumentPage struct {
+	Records  []*Document `json:"records"`
+	Count    int32       `json:"count"`
+	Bookmark string      `json:"bookmark"`
+}
+
+// DocumentQuery selects the documents of a type, and of an owner if set. Selector, if set,
+// is a CouchDB query matched against the document fields instead, it requires CouchDB as
+// state database of the peers.
+type DocumentQuery struct {
+	DocType  string
+	Owner    string
+	Selector string
+	PageSize int32
+	// Bookmark of the previous page, empty for the first page
+	Bookmark string
+}
+
+// ExampleCCPutDocArgs returns example cc args that create or replace a document
+func ExampleCCPutDocArgs(doc *Document) [][]byte {
+	return [][]byte{[]byte("putdoc"), []byte(doc.DocType), []byte(doc.Owner), []byte(doc.ID), doc.Data}
+}
+
+// ExampleCCGetDocArgs returns example cc args that query a document
+func ExampleCCGetDocArgs(docType, owner, id string) [][]byte {
+	return [][]byte{[]byte("getdoc"), []byte(docType), []byte(owner), []byte(id)}
+}
+
+// ExampleCCDelDocArgs returns example cc args that delete a document
+func ExampleCCDelDocArgs(docType, owner, id string) [][]byte {
+	return [][]byte{[]byte("deldoc"), []byte(docType), []byte(owner), []byte(id)}
+}
+
+// ExampleCCDocQueryArgs returns example cc args that query a page of documents
+func ExampleCCDocQueryArgs(q DocumentQuery) [][]byte {
+	pageSize := q.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultDocumentPageSize
+	}
+	size := []byte(strconv.FormatInt(int64(pageSize), 10))
+	if q.Selector != "" {
+		return [][]byte{[]byte("querydocs"), []byte(q.Selector), size, []byte(q.Bookmark)}
+	}
+	return [][]byte{[]byte("docs"), []byte(q.DocType), []byte(q.Owner), size, []byte(q.Bookmark)}
+}
+
+// PutDocumentWithContext creates or replaces a document, only its creator and admins may replace it.
+// Clients may only write documents they own, admins those of any owner.
+// On failure a *LedgerError is returned.
+func PutDocumentWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, doc *Document, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCPutDocArgs(doc),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "putdoc", doc.ID, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// GetDocumentWithContext queries a document. On failure a *LedgerError is returned, with
+// Kind ErrNotFound if there is no such document.
+func GetDocumentWithContext(reqCtx context.Context, chClient *channel.Client, ccID, docType, owner, id string, policy RetryPolicy) (*Document, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCGetDocArgs(docType, owner, id),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "getdoc", id, err)
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(response.Payload, doc); err != nil {
+		return nil, errors.Wrap(err, "decoding document failed")
+	}
+	return doc, nil
+}
+
+// DeleteDocumentWithContext deletes a document, only its creator and admins may delete it.
+// On failure a *LedgerError is returned.
+func DeleteDocumentWithContext(reqCtx context.Context, chClient *channel.Client, ccID, docType, owner, id string, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCDelDocArgs(docType, owner, id),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "deldoc", id, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// QueryDocumentsWithContext queries a page of documents. Pass the bookmark of the previous page
+// in q to get the next one. On failure a *LedgerError is returned.
+func QueryDocumentsWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, q DocumentQuery, policy RetryPolicy) (*DocumentPage, error) {
+	if q.Selector == "" && q.DocType == "" {
+		return nil, errors.New("document query requires a type or a selector")
+	}
+
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCDocQueryArgs(q),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "docs", q.DocType, err)
+	}
+
+	page := &DocumentPage{}
+	if err := json.Unmarshal(response.Payload, page); err != nil {
+		return nil, errors.Wrap(err, "decoding document page failed")
+	}
+	return page, nil
+}
+
+// QueryAllDocumentsWithContext reads all documents selected by q page by page, starting at the
+// bookmark of q. The pages are read in separate queries, so documents written meanwhile may or
+// may not be included.
+func QueryAllDocumentsWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, q DocumentQuery, policy RetryPolicy) ([]*Document, error) {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultDocumentPageSize
+	}
+
+	docs := []*Document{}
+	for {
+		page, err := QueryDocumentsWithContext(reqCtx, chClient, ccID, q, policy)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, page.Records...)
+		if page.Bookmark == "" || page.Count < q.PageSize {
+			return docs, nil
+		}
+		q.Bookmark = page.Bookmark
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// Roles of the endorsers required by a key-level endorsement policy
+const (
+	EndorsementRoleMember = "member"
+	EndorsementRolePeer   = "peer"
+)
+
+// KeyEndorsement is the key-level endorsement policy of a key of example CC. Changes of
+// the key and of its owner must be endorsed by every org instead of satisfying the
+// chaincode policy.
+// Orgs is empty if the chaincode policy applies.
+type KeyEndorsement struct {
+	Key  string   `json:"key"`
+	Orgs []string `json:"orgs"`
+}
+
+// ExampleCCSetEPArgs returns example cc args that require the endorsement of all given
+// orgs for changes of the key, or remove the key-level policy if there are no orgs
+func ExampleCCSetEPArgs(key string, mspIDs []string, role string) [][]byte {
+	return [][]byte{[]byte("setep"), []byte(key), []byte(strings.Join(mspIDs, ",")), []byte(role)}
+}
+
+// ExampleCCGetEPArgs returns example cc args that query the key-level endorsement policy of the key
+func ExampleCCGetEPArgs(key string) [][]byte {
+	return [][]byte{[]byte("getep"), []byte(key)}
+}
+
+// SetKeyEndorsementWithContext requires the endorsement of a member, or of a peer if role is
+// EndorsementRolePeer, of each given org for later changes of the key. Without orgs the
+// key-level policy is removed. Only the owner of the key may change its policy, and the
+// change itself must satisfy the current policy of the key, so the channel client must
+// then reach peers of all its orgs. On failure a *LedgerError is returned.
+func SetKeyEndorsementWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, mspIDs []string, role string, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCSetEPArgs(key, mspIDs, role),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "setep", key, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// GetKeyEndorsementWithContext queries the key-level endorsement policy of the key.
+// On failure a *LedgerError is returned, with Kind ErrNotFound if the key does not exist.
+func GetKeyEndorsementWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, policy RetryPolicy) (*KeyEndorsement, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCGetEPArgs(key),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "getep", key, err)
+	}
+
+	endorsement := &KeyEndorsement{}
+	if err := json.Unmarshal(response.Payload, endorsement); err != nil {
+		return nil, errors.Wrap(err, "decoding endorsement policy failed")
+	}
+	return endorsement, nil
+}
@@ -0,0 +1,287 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+// LedgerErrorKind tells why a ledger operation failed
+type LedgerErrorKind int
+
+// Kinds of ledger errors
+const (
+	ErrUnknown LedgerErrorKind = iota
+	ErrEndorsement
+	ErrNotFound
+	ErrChaincode
+	ErrMVCCConflict
+	ErrInvalidTransaction
+	ErrTimeout
+	ErrConnection
+	ErrCanceled
+	ErrForbidden
+)
+
+var ledgerErrorKindNames = map[LedgerErrorKind]string{
+	ErrUnknown:            "UNKNOWN",
+	ErrEndorsement:        "ENDORSEMENT_FAILURE",
+	ErrNotFound:           "NOT_FOUND",
+	ErrChaincode:          "CHAINCODE_ERROR",
+	ErrMVCCConflict:       "MVCC_CONFLICT",
+	ErrInvalidTransaction: "INVALID_TRANSACTION",
+	ErrTimeout:            "TIMEOUT",
+	ErrConnection:         "CONNECTION_FAILURE",
+	ErrCanceled:           "CANCELED",
+	ErrForbidden:          "FORBIDDEN",
+}
+
+// String representation of the kind
+func (k LedgerErrorKind) String() string {
+	if s, ok := ledgerErrorKindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("LedgerErrorKind(%d)", int(k))
+}
+
+// Error codes of the JSON errors returned by example CC
+const (
+	CCErrInvalidArgs       = "INVALID_ARGUMENTS"
+	CCErrInvalidValue      = "INVALID_VALUE"
+	CCErrNotFound          = "NOT_FOUND"
+	CCErrInsufficient      = "INSUFFICIENT_FUNDS"
+	CCErrForbidden         = "FORBIDDEN"
+	CCErrStateFailure      = "STATE_FAILURE"
+	CCErrEventFailure      = "EVENT_FAILURE"
+	CCErrInvokeFailure     = "INVOKE_FAILURE"
+	CCErrUnknownFunction   = "UNKNOWN_FUNCTION"
+	CCErrSupplyCapExceeded = "SUPPLY_CAP_EXCEEDED"
+	CCErrSupplyInvariant   = "SUPPLY_INVARIANT_VIOLATED"
+	CCErrTokenModeDisabled = "TOKEN_MODE_DISABLED"
+)
+
+// ChaincodeError is the structured error returned by example CC
+type ChaincodeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Key     string `json:"key,omitempty"`
+}
+
+// ChaincodeErrorOf extracts the structured error of example CC from err.
+// It returns false if err was not returned by the chaincode or the chaincode
+// is an older version returning plain messages.
+func ChaincodeErrorOf(err error) (*ChaincodeError, bool) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case s.Group == status.ChaincodeStatus:
+		return parseChaincodeError(s.Message)
+	case s.Group == status.ClientStatus && status.Code(s.Code) == status.MultipleErrors:
+		for _, d := range s.Details {
+			if e, ok := d.(error); ok {
+				if ccErr, ok := ChaincodeErrorOf(e); ok {
+					return ccErr, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// parseChaincodeError decodes the JSON error in a chaincode message. The peer may add
+// a prefix to the message returned by the chaincode, so the JSON object is searched for.
+func parseChaincodeError(msg string) (*ChaincodeError, bool) {
+	start := strings.Index(msg, "{")
+	end := strings.LastIndex(msg, "}")
+	if start < 0 || end < start {
+		return nil, false
+	}
+
+	ccErr := &ChaincodeError{}
+	if err := json.Unmarshal([]byte(msg[start:end+1]), ccErr); err != nil || ccErr.Code == "" {
+		return nil, false
+	}
+	return ccErr, true
+}
+
+// LedgerError is returned by the ledger helpers when an operation on example CC fails
+type LedgerError struct {
+	Kind LedgerErrorKind
+	Op   string
+	Key  string
+	Err  error
+}
+
+// Error returns the error message
+func (e *LedgerError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s failed [%s]: %s", e.Op, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s of key [%s] failed [%s]: %s", e.Op, e.Key, e.Kind, e.Err)
+}
+
+// Cause returns the underlying error so that errors.Cause and status.FromError keep working
+func (e *LedgerError) Cause() error {
+	return e.Err
+}
+
+// newLedgerError classifies err and wraps it in a LedgerError
+func newLedgerError(op, key string, err error) *LedgerError {
+	return &LedgerError{Kind: ClassifyError(err), Op: op, Key: key, Err: err}
+}
+
+// newLedgerErrorWithContext is like newLedgerError but reports a cancelled or expired
+// request context, since the SDK turns both into a generic timeout
+func newLedgerErrorWithContext(reqCtx context.Context, op, key string, err error) *LedgerError {
+	ledgerErr := newLedgerError(op, key, err)
+	switch reqCtx.Err() {
+	case context.Canceled:
+		ledgerErr.Kind = ErrCanceled
+	case context.DeadlineExceeded:
+		ledgerErr.Kind = ErrTimeout
+	}
+	return ledgerErr
+}
+
+// LedgerErrorKindOf returns the kind of the first LedgerError in the cause chain of err,
+// or classifies err if there is none
+func LedgerErrorKindOf(err error) LedgerErrorKind {
+	type causer interface {
+		Cause() error
+	}
+
+	for e := err; e != nil; {
+		if le, ok := e.(*LedgerError); ok {
+			return le.Kind
+		}
+		c, ok := e.(causer)
+		if !ok {
+			break
+		}
+		e = c.Cause()
+	}
+	return ClassifyError(err)
+}
+
+// IsLedgerErrorKind returns true if err is of the given kind
+func IsLedgerErrorKind(err error, kind LedgerErrorKind) bool {
+	return err != nil && LedgerErrorKindOf(err) == kind
+}
+
+// ClassifyError infers the kind of an error returned by the SDK clients
+func ClassifyError(err error) LedgerErrorKind {
+	if err == nil {
+		return ErrUnknown
+	}
+
+	switch err {
+	case context.Canceled:
+		return ErrCanceled
+	case context.DeadlineExceeded:
+		return ErrTimeout
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return ErrUnknown
+	}
+
+	switch s.Group {
+	case status.ChaincodeStatus:
+		if isNotFoundMessage(s.Message) {
+			return ErrNotFound
+		}
+		if ccErr, ok := parseChaincodeError(s.Message); ok && ccErr.Code == CCErrForbidden {
+			return ErrForbidden
+		}
+		return ErrChaincode
+	case status.EndorserServerStatus:
+		return ErrEndorsement
+	case status.EventServerStatus:
+		return classifyValidationCode(pb.TxValidationCode(s.Code))
+	case status.EndorserClientStatus:
+		switch status.Code(s.Code) {
+		case status.Timeout:
+			return ErrTimeout
+		case status.PrematureChaincodeExecution, status.ChaincodeAlreadyLaunching, status.ChaincodeNameNotFound:
+			return ErrChaincode
+		}
+		return ErrConnection
+	case status.GRPCTransportStatus:
+		if grpcCodes.Code(s.Code) == grpcCodes.DeadlineExceeded {
+			return ErrTimeout
+		}
+		return ErrConnection
+	case status.HTTPTransportStatus, status.OrdererClientStatus, status.OrdererServerStatus, status.DiscoveryServerStatus:
+		return ErrConnection
+	case status.ClientStatus:
+		return classifyClientStatus(s)
+	}
+	return ErrUnknown
+}
+
+func classifyValidationCode(code pb.TxValidationCode) LedgerErrorKind {
+	switch code {
+	case pb.TxValidationCode_MVCC_READ_CONFLICT, pb.TxValidationCode_PHANTOM_READ_CONFLICT:
+		return ErrMVCCConflict
+	case pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE:
+		return ErrEndorsement
+	}
+	return ErrInvalidTransaction
+}
+
+func classifyClientStatus(s *status.Status) LedgerErrorKind {
+	switch status.Code(s.Code) {
+	case status.Timeout:
+		return ErrTimeout
+	case status.ConnectionFailed, status.NoPeersFound:
+		return ErrConnection
+	case status.EndorsementMismatch, status.MissingEndorsement, status.SignatureVerificationFailed, status.QueryEndorsers:
+		return ErrEndorsement
+	case status.MultipleErrors:
+		return classifyMultipleErrors(s.Details)
+	}
+	return ErrUnknown
+}
+
+// classifyMultipleErrors picks the most relevant kind reported by several endorsers.
+// A chaincode answer wins over transport problems since the peer did run the chaincode.
+func classifyMultipleErrors(details []interface{}) LedgerErrorKind {
+	priority := []LedgerErrorKind{ErrNotFound, ErrForbidden, ErrChaincode, ErrEndorsement, ErrTimeout, ErrConnection}
+
+	found := make(map[LedgerErrorKind]bool)
+	for _, d := range details {
+		if err, ok := d.(error); ok {
+			found[ClassifyError(err)] = true
+		}
+	}
+	for _, kind := range priority {
+		if found[kind] {
+			return kind
+		}
+	}
+	return ErrUnknown
+}
+
+// isNotFoundMessage matches the errors example CC returns for a missing key,
+// including the plain messages of older chaincode versions
+func isNotFoundMessage(msg string) bool {
+	if ccErr, ok := parseChaincodeError(msg); ok {
+		return ccErr.Code == CCErrNotFound
+	}
+	return strings.Contains(msg, "Entity not found") || strings.Contains(msg, "Nil amount")
+}
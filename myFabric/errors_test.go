@@ -0,0 +1,184 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+// ccStatus is a chaincode error as the peer endorser of the SDK returns it
+func ccStatus(msg string) error {
+	return errors.Wrap(status.New(status.ChaincodeStatus, 500, msg, nil), "Transaction processing for endorser [peer0]")
+}
+
+func TestClassifyError(t *testing.T) {
+	notFound := ccStatus(`chaincode error: {"code":"NOT_FOUND","message":"key not found","key":"a"}`)
+	forbidden := ccStatus(`{"code":"FORBIDDEN","message":"not the owner","key":"a"}`)
+	insufficient := ccStatus(`{"code":"INSUFFICIENT_FUNDS","message":"balance too low","key":"a"}`)
+	timeout := status.New(status.EndorserClientStatus, status.Timeout.ToInt32(), "request timed out", nil)
+	connection := status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection refused", nil)
+	endorsement := status.New(status.EndorserServerStatus, 500, "endorsement failure", nil)
+
+	tests := []struct {
+		name string
+		err  error
+		kind LedgerErrorKind
+	}{
+		{"nil", nil, ErrUnknown},
+		{"plain error", errors.New("boom"), ErrUnknown},
+		{"canceled", context.Canceled, ErrCanceled},
+		{"deadline", context.DeadlineExceeded, ErrTimeout},
+		{"chaincode not found", notFound, ErrNotFound},
+		{"chaincode not found of old version", ccStatus("Entity not found"), ErrNotFound},
+		{"chaincode nil amount of old version", ccStatus("Nil amount for b"), ErrNotFound},
+		{"chaincode forbidden", forbidden, ErrForbidden},
+		{"chaincode error", insufficient, ErrChaincode},
+		{"chaincode plain message", ccStatus("Invalid transaction amount"), ErrChaincode},
+		{"chaincode malformed JSON", ccStatus(`{"code":`), ErrChaincode},
+		{"wrapped twice", errors.WithMessage(errors.Wrap(notFound, "query failed"), "get"), ErrNotFound},
+		{"endorser server", endorsement, ErrEndorsement},
+		{"MVCC conflict", status.New(status.EventServerStatus, int32(pb.TxValidationCode_MVCC_READ_CONFLICT), "", nil), ErrMVCCConflict},
+		{"phantom read", status.New(status.EventServerStatus, int32(pb.TxValidationCode_PHANTOM_READ_CONFLICT), "", nil), ErrMVCCConflict},
+		{"policy failure", status.New(status.EventServerStatus, int32(pb.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), "", nil), ErrEndorsement},
+		{"invalid transaction", status.New(status.EventServerStatus, int32(pb.TxValidationCode_BAD_PAYLOAD), "", nil), ErrInvalidTransaction},
+		{"endorser client timeout", timeout, ErrTimeout},
+		{"premature execution", status.New(status.EndorserClientStatus, status.PrematureChaincodeExecution.ToInt32(), "", nil), ErrChaincode},
+		{"chaincode name not found", status.New(status.EndorserClientStatus, status.ChaincodeNameNotFound.ToInt32(), "", nil), ErrChaincode},
+		{"endorser client connection", connection, ErrConnection},
+		{"grpc deadline", status.New(status.GRPCTransportStatus, int32(grpcCodes.DeadlineExceeded), "", nil), ErrTimeout},
+		{"grpc unavailable", status.New(status.GRPCTransportStatus, int32(grpcCodes.Unavailable), "", nil), ErrConnection},
+		{"orderer", status.New(status.OrdererServerStatus, 503, "", nil), ErrConnection},
+		{"discovery", status.New(status.DiscoveryServerStatus, 500, "", nil), ErrConnection},
+		{"client timeout", status.New(status.ClientStatus, status.Timeout.ToInt32(), "", nil), ErrTimeout},
+		{"no peers", status.New(status.ClientStatus, status.NoPeersFound.ToInt32(), "", nil), ErrConnection},
+		{"endorsement mismatch", status.New(status.ClientStatus, status.EndorsementMismatch.ToInt32(), "", nil), ErrEndorsement},
+		{"unknown client status", status.New(status.ClientStatus, status.GenericTransient.ToInt32(), "", nil), ErrUnknown},
+		{"multi not found wins", multi.New(connection, notFound, forbidden), ErrNotFound},
+		{"multi forbidden over chaincode", multi.New(insufficient, forbidden), ErrForbidden},
+		{"multi chaincode over transport", multi.New(timeout, insufficient, connection), ErrChaincode},
+		{"multi endorsement over timeout", multi.New(timeout, endorsement), ErrEndorsement},
+		{"multi timeout over connection", multi.New(connection, timeout), ErrTimeout},
+		{"multi unknown", multi.New(errors.New("a"), errors.New("b")), ErrUnknown},
+		{"wrapped multi", errors.Wrap(multi.New(connection, notFound), "query failed"), ErrNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if kind := ClassifyError(tc.err); kind != tc.kind {
+				t.Fatalf("expected %s, got %s", tc.kind, kind)
+			}
+		})
+	}
+}
+
+func TestLedgerErrorKindOf(t *testing.T) {
+	notFound := ccStatus(`{"code":"NOT_FOUND","message":"key not found","key":"a"}`)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ledgerErr := newLedgerErrorWithContext(canceled, "get", "a", notFound)
+	if ledgerErr.Kind != ErrCanceled {
+		t.Fatalf("expected a cancelled request to be %s, got %s", ErrCanceled, ledgerErr.Kind)
+	}
+	if kind := LedgerErrorKindOf(errors.Wrap(ledgerErr, "command failed")); kind != ErrCanceled {
+		t.Fatalf("expected the kind of the wrapped LedgerError, got %s", kind)
+	}
+	if kind := LedgerErrorKindOf(notFound); kind != ErrNotFound {
+		t.Fatalf("expected an error without LedgerError to be classified, got %s", kind)
+	}
+	if !IsLedgerErrorKind(newLedgerError("get", "a", notFound), ErrNotFound) || IsLedgerErrorKind(nil, ErrUnknown) {
+		t.Fatal("IsLedgerErrorKind does not match the kind")
+	}
+
+	ccErr, ok := ChaincodeErrorOf(ledgerErr)
+	if !ok || ccErr.Code != CCErrNotFound || ccErr.Key != "a" {
+		t.Fatalf("expected the chaincode error of the LedgerError, got %v", ccErr)
+	}
+	ccErr, ok = ChaincodeErrorOf(multi.New(errors.New("connection refused"), notFound))
+	if !ok || ccErr.Code != CCErrNotFound {
+		t.Fatalf("expected the chaincode error of a multi error, got %v", ccErr)
+	}
+	if _, ok := ChaincodeErrorOf(ccStatus("Entity not found")); ok {
+		t.Fatal("expected no chaincode error in a plain message")
+	}
+}
+
+// TestChaincodeErrorCodes pins the CCErr constants to the error codes of example CC
+func TestChaincodeErrorCodes(t *testing.T) {
+	ccErrs := map[string]string{
+		"InvalidArgs":       CCErrInvalidArgs,
+		"InvalidValue":      CCErrInvalidValue,
+		"NotFound":          CCErrNotFound,
+		"Insufficient":      CCErrInsufficient,
+		"Forbidden":         CCErrForbidden,
+		"StateFailure":      CCErrStateFailure,
+		"EventFailure":      CCErrEventFailure,
+		"InvokeFailure":     CCErrInvokeFailure,
+		"UnknownFunction":   CCErrUnknownFunction,
+		"SupplyCapExceeded": CCErrSupplyCapExceeded,
+		"SupplyInvariant":   CCErrSupplyInvariant,
+		"TokenModeDisabled": CCErrTokenModeDisabled,
+	}
+
+	codes := make(map[string]string)
+	for name, value := range chaincodeConstants(t, "chaincode/example_cc.go") {
+		if strings.HasPrefix(name, "errCode") {
+			codes[strings.TrimPrefix(name, "errCode")] = value
+		}
+	}
+
+	for name, code := range codes {
+		want, ok := ccErrs[name]
+		if !ok {
+			t.Errorf("example CC error code errCode%s has no CCErr%s constant", name, name)
+		} else if code != want {
+			t.Errorf("expected errCode%s [%s] to equal CCErr%s [%s]", name, code, name, want)
+		}
+	}
+	for name := range ccErrs {
+		if _, ok := codes[name]; !ok {
+			t.Errorf("CCErr%s is not an error code of example CC", name)
+		}
+	}
+}
+
+// chaincodeConstants returns the string constants declared in a source file of a chaincode.
+// The chaincodes are separate programs, their constants are pinned to ours by tests.
+func chaincodeConstants(t *testing.T, path string) map[string]string {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatalf("parsing chaincode [%s] failed: %s", path, err)
+	}
+	consts := make(map[string]string)
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if i >= len(spec.Values) {
+				continue
+			}
+			if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				consts[name.Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+		return true
+	})
+	return consts
+}
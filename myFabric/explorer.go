@@ -0,0 +1,398 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	contextAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	mspProto "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+	"github.com/pkg/errors"
+)
+
+// ChainInfo is the height and the hashes of the last block of a channel
+type ChainInfo struct {
+	Height            uint64 `json:"height"`
+	CurrentBlockHash  string `json:"currentBlockHash"`
+	PreviousBlockHash string `json:"previousBlockHash"`
+	Endorser          string `json:"endorser"`
+}
+
+// BlockInfo is a decoded block
+type BlockInfo struct {
+	Number       uint64    `json:"number"`
+	Hash         string    `json:"hash"`
+	PreviousHash string    `json:"previousHash"`
+	DataHash     string    `json:"dataHash"`
+	Transactions []*TxInfo `json:"transactions"`
+}
+
+// TxInfo is a decoded transaction envelope
+type TxInfo struct {
+	TxID           string        `json:"txId"`
+	Type           string        `json:"type"`
+	ChannelID      string        `json:"channelId"`
+	Timestamp      time.Time     `json:"timestamp"`
+	BlockNumber    uint64        `json:"blockNumber"`
+	ValidationCode string        `json:"validationCode"`
+	Creator        *CreatorInfo  `json:"creator,omitempty"`
+	Actions        []*ActionInfo `json:"actions,omitempty"`
+}
+
+// CreatorInfo identifies the client that signed a transaction
+type CreatorInfo struct {
+	MSPID   string `json:"mspId"`
+	Subject string `json:"subject,omitempty"`
+	Issuer  string `json:"issuer,omitempty"`
+}
+
+// ActionInfo is a chaincode invocation of an endorser transaction
+type ActionInfo struct {
+	Chaincode        string            `json:"chaincode"`
+	ChaincodeVersion string            `json:"chaincodeVersion,omitempty"`
+	Function         string            `json:"function"`
+	Args             []string          `json:"args"`
+	ResponseStatus   int32             `json:"responseStatus"`
+	ResponseMessage  string            `json:"responseMessage,omitempty"`
+	Event            *EventInfo        `json:"event,omitempty"`
+	ReadWriteSets    []*NsReadWriteSet `json:"readWriteSets"`
+	Endorsers        []*CreatorInfo    `json:"endorsers"`
+}
+
+// EventInfo is the chaincode event set by an action
+type EventInfo struct {
+	Name    string `json:"name"`
+	Payload string `json:"payload"`
+}
+
+// NsReadWriteSet holds the keys read and written in a chaincode namespace
+type NsReadWriteSet struct {
+	Namespace string     `json:"namespace"`
+	Reads     []*KVRead  `json:"reads"`
+	Writes    []*KVWrite `json:"writes"`
+}
+
+// KVRead is a key read at a committed version, a nil version means the key did not exist
+type KVRead struct {
+	Key         string  `json:"key"`
+	BlockNumber *uint64 `json:"blockNumber,omitempty"`
+	TxNumber    *uint64 `json:"txNumber,omitempty"`
+}
+
+// KVWrite is a key written or deleted
+type KVWrite struct {
+	Key      string `json:"key"`
+	Value    string `json:"value,omitempty"`
+	IsDelete bool   `json:"isDelete,omitempty"`
+}
+
+// Explorer queries blocks and transactions of a channel and decodes them
+type Explorer struct {
+	ledger *ledger.Client
+}
+
+// NewExplorer creates an Explorer for the given channel context
+func NewExplorer(ctx contextAPI.ChannelProvider) (*Explorer, error) {
+	ledgerClient, err := ledger.New(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create new ledger client")
+	}
+	return &Explorer{ledger: ledgerClient}, nil
+}
+
+// ChainInfo queries the height and the current block hash of the channel
+func (e *Explorer) ChainInfo(reqCtx context.Context) (*ChainInfo, error) {
+	info, err := e.ledger.QueryInfo(ledger.WithParentContext(reqCtx))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "query info", "", err)
+	}
+	return &ChainInfo{
+		Height:            info.BCI.Height,
+		CurrentBlockHash:  hex.EncodeToString(info.BCI.CurrentBlockHash),
+		PreviousBlockHash: hex.EncodeToString(info.BCI.PreviousBlockHash),
+		Endorser:          info.Endorser,
+	}, nil
+}
+
+// BlockByNumber queries and decodes the block with the given number
+func (e *Explorer) BlockByNumber(reqCtx context.Context, number uint64) (*BlockInfo, error) {
+	block, err := e.ledger.QueryBlock(number, ledger.WithParentContext(reqCtx))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "query block", "", err)
+	}
+	return DecodeBlock(block)
+}
+
+// BlockByHash queries and decodes the block with the given hex encoded hash
+func (e *Explorer) BlockByHash(reqCtx context.Context, hash string) (*BlockInfo, error) {
+	blockHash, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid block hash [%s]", hash)
+	}
+
+	block, err := e.ledger.QueryBlockByHash(blockHash, ledger.WithParentContext(reqCtx))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "query block", "", err)
+	}
+	return DecodeBlock(block)
+}
+
+// Transaction queries and decodes the transaction with the given ID
+func (e *Explorer) Transaction(reqCtx context.Context, txID fabAPI.TransactionID) (*TxInfo, error) {
+	processed, err := e.ledger.QueryTransaction(txID, ledger.WithParentContext(reqCtx))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "query transaction", "", err)
+	}
+	block, err := e.ledger.QueryBlockByTxID(txID, ledger.WithParentContext(reqCtx))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "query transaction", "", err)
+	}
+
+	tx, err := DecodeEnvelope(processed.TransactionEnvelope)
+	if err != nil {
+		return nil, err
+	}
+	tx.BlockNumber = block.Header.Number
+	tx.ValidationCode = pb.TxValidationCode(processed.ValidationCode).String()
+	return tx, nil
+}
+
+// DecodeBlock decodes all transactions of a block
+func DecodeBlock(block *cb.Block) (*BlockInfo, error) {
+	if block.Header == nil || block.Data == nil {
+		return nil, errors.New("block has no header or data")
+	}
+
+	hash, err := blockHeaderHash(block.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &BlockInfo{
+		Number:       block.Header.Number,
+		Hash:         hex.EncodeToString(hash),
+		PreviousHash: hex.EncodeToString(block.Header.PreviousHash),
+		DataHash:     hex.EncodeToString(block.Header.DataHash),
+	}
+
+	var txFilter []byte
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(cb.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = block.Metadata.Metadata[cb.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	for i, data := range block.Data.Data {
+		env, err := utils.GetEnvelopeFromBlock(data)
+		if err != nil {
+			return nil, errors.WithMessage(err, "decoding block transaction failed")
+		}
+		tx, err := DecodeEnvelope(env)
+		if err != nil {
+			return nil, err
+		}
+		tx.BlockNumber = block.Header.Number
+		if i < len(txFilter) {
+			tx.ValidationCode = pb.TxValidationCode(txFilter[i]).String()
+		}
+		info.Transactions = append(info.Transactions, tx)
+	}
+	return info, nil
+}
+
+// DecodeEnvelope decodes the headers of a transaction envelope and, for endorser transactions,
+// the chaincode invocations with their read/write sets
+func DecodeEnvelope(env *cb.Envelope) (*TxInfo, error) {
+	payload, err := utils.GetPayload(env)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Header == nil {
+		return nil, errors.New("envelope payload has no header")
+	}
+
+	chdr, err := utils.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil {
+		return nil, err
+	}
+	shdr, err := utils.GetSignatureHeader(payload.Header.SignatureHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &TxInfo{
+		TxID:      chdr.TxId,
+		Type:      cb.HeaderType(chdr.Type).String(),
+		ChannelID: chdr.ChannelId,
+		Creator:   decodeCreator(shdr.Creator),
+	}
+	if chdr.Timestamp != nil {
+		if tx.Timestamp, err = ptypes.Timestamp(chdr.Timestamp); err != nil {
+			return nil, errors.Wrap(err, "invalid transaction timestamp")
+		}
+	}
+
+	if cb.HeaderType(chdr.Type) != cb.HeaderType_ENDORSER_TRANSACTION {
+		return tx, nil
+	}
+
+	transaction, err := utils.GetTransaction(payload.Data)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range transaction.Actions {
+		actionInfo, err := decodeAction(action)
+		if err != nil {
+			return nil, errors.WithMessage(err, "decoding transaction ["+chdr.TxId+"] failed")
+		}
+		tx.Actions = append(tx.Actions, actionInfo)
+	}
+	return tx, nil
+}
+
+func decodeAction(action *pb.TransactionAction) (*ActionInfo, error) {
+	ccPayload, ccAction, err := utils.GetPayloads(action)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ActionInfo{}
+	if ccAction.ChaincodeId != nil {
+		info.Chaincode = ccAction.ChaincodeId.Name
+		info.ChaincodeVersion = ccAction.ChaincodeId.Version
+	}
+	if ccAction.Response != nil {
+		info.ResponseStatus = ccAction.Response.Status
+		info.ResponseMessage = ccAction.Response.Message
+	}
+
+	proposalPayload, err := utils.GetChaincodeProposalPayload(ccPayload.ChaincodeProposalPayload)
+	if err != nil {
+		return nil, err
+	}
+	cis := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(proposalPayload.Input, cis); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling ChaincodeInvocationSpec")
+	}
+	if spec := cis.ChaincodeSpec; spec != nil && spec.Input != nil {
+		for i, arg := range spec.Input.Args {
+			if i == 0 {
+				info.Function = string(arg)
+				continue
+			}
+			info.Args = append(info.Args, string(arg))
+		}
+	}
+
+	if len(ccAction.Events) > 0 {
+		event, err := utils.GetChaincodeEvents(ccAction.Events)
+		if err != nil {
+			return nil, err
+		}
+		if event.EventName != "" {
+			info.Event = &EventInfo{Name: event.EventName, Payload: string(event.Payload)}
+		}
+	}
+
+	txRwSet := &rwsetutil.TxRwSet{}
+	if err := txRwSet.FromProtoBytes(ccAction.Results); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling read/write set")
+	}
+	for _, nsRwSet := range txRwSet.NsRwSets {
+		info.ReadWriteSets = append(info.ReadWriteSets, decodeNsRwSet(nsRwSet))
+	}
+
+	if ccPayload.Action != nil {
+		for _, endorsement := range ccPayload.Action.Endorsements {
+			info.Endorsers = append(info.Endorsers, decodeCreator(endorsement.Endorser))
+		}
+	}
+	return info, nil
+}
+
+func decodeNsRwSet(nsRwSet *rwsetutil.NsRwSet) *NsReadWriteSet {
+	info := &NsReadWriteSet{Namespace: nsRwSet.NameSpace}
+	if nsRwSet.KvRwSet == nil {
+		return info
+	}
+
+	for _, read := range nsRwSet.KvRwSet.Reads {
+		kvRead := &KVRead{Key: read.Key}
+		if read.Version != nil {
+			blockNum, txNum := read.Version.BlockNum, read.Version.TxNum
+			kvRead.BlockNumber = &blockNum
+			kvRead.TxNumber = &txNum
+		}
+		info.Reads = append(info.Reads, kvRead)
+	}
+	for _, write := range nsRwSet.KvRwSet.Writes {
+		info.Writes = append(info.Writes, &KVWrite{Key: write.Key, Value: string(write.Value), IsDelete: write.IsDelete})
+	}
+	return info
+}
+
+// decodeCreator extracts the MSP ID and the certificate names of a serialized identity.
+// Only the MSP ID is returned if the certificate cannot be parsed.
+func decodeCreator(creator []byte) *CreatorInfo {
+	if len(creator) == 0 {
+		return nil
+	}
+
+	sid := &mspProto.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, sid); err != nil {
+		logger.Debugf("failed to unmarshal serialized identity: %s", err)
+		return nil
+	}
+
+	info := &CreatorInfo{MSPID: sid.Mspid}
+	block, _ := pem.Decode(sid.IdBytes)
+	if block == nil {
+		return info
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		logger.Debugf("failed to parse certificate of [%s]: %s", sid.Mspid, err)
+		return info
+	}
+	info.Subject = cert.Subject.String()
+	info.Issuer = cert.Issuer.String()
+	return info
+}
+
+// asn1BlockHeader is the encoding of a block header whose hash links the blocks
+type asn1BlockHeader struct {
+	Number       *big.Int
+	PreviousHash []byte
+	DataHash     []byte
+}
+
+// blockHeaderHash computes the hash of a block header the way the orderer does
+func blockHeaderHash(header *cb.BlockHeader) ([]byte, error) {
+	raw, err := asn1.Marshal(asn1BlockHeader{
+		Number:       new(big.Int).SetUint64(header.Number),
+		PreviousHash: header.PreviousHash,
+		DataHash:     header.DataHash,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding block header failed")
+	}
+	hash := sha256.Sum256(raw)
+	return hash[:], nil
+}
@@ -0,0 +1,269 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	contextAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/pkg/errors"
+)
+
+// Export formats
+const (
+	ExportJSONLines = "jsonl"
+	ExportCSV       = "csv"
+)
+
+// exportCSVHeader names the columns of a CSV export
+var exportCSVHeader = []string{"blockNumber", "txId", "timestamp", "creatorMspId", "creatorSubject", "chaincode", "function", "args", "writes", "validationCode"}
+
+// ExportRecord is the exported form of a single transaction
+type ExportRecord struct {
+	BlockNumber    uint64     `json:"blockNumber"`
+	TxID           string     `json:"txId"`
+	Timestamp      time.Time  `json:"timestamp"`
+	CreatorMSPID   string     `json:"creatorMspId"`
+	CreatorSubject string     `json:"creatorSubject"`
+	Chaincode      string     `json:"chaincode"`
+	Function       string     `json:"function"`
+	Args           []string   `json:"args"`
+	Writes         []*KVWrite `json:"writes"`
+	ValidationCode string     `json:"validationCode"`
+}
+
+// newExportRecord flattens a decoded transaction. The writes of all namespaces are kept,
+// so that the state changes of chaincode to chaincode calls are exported as well.
+func newExportRecord(tx *TxInfo) *ExportRecord {
+	rec := &ExportRecord{
+		BlockNumber:    tx.BlockNumber,
+		TxID:           tx.TxID,
+		Timestamp:      tx.Timestamp,
+		ValidationCode: tx.ValidationCode,
+	}
+	if tx.Creator != nil {
+		rec.CreatorMSPID = tx.Creator.MSPID
+		rec.CreatorSubject = tx.Creator.Subject
+	}
+	for i, action := range tx.Actions {
+		if i == 0 {
+			rec.Chaincode = action.Chaincode
+			rec.Function = action.Function
+			rec.Args = action.Args
+		}
+		for _, rws := range action.ReadWriteSets {
+			rec.Writes = append(rec.Writes, rws.Writes...)
+		}
+	}
+	return rec
+}
+
+// LedgerExporter writes every transaction of the channel ledger as a record.
+// The last exported block is stored in CheckpointPath, if set, and exporting resumes after it.
+type LedgerExporter struct {
+	Format         string
+	CheckpointPath string
+	ReconnectDelay time.Duration
+	ctxProvider    contextAPI.ChannelProvider
+	explorer       *Explorer
+	out            io.Writer
+	csv            *csv.Writer
+	next           uint64
+}
+
+// NewLedgerExporter creates an exporter writing records in the given format to out
+func NewLedgerExporter(ctx contextAPI.ChannelProvider, out io.Writer, format string) (*LedgerExporter, error) {
+	if format != ExportJSONLines && format != ExportCSV {
+		return nil, errors.Errorf("invalid export format [%s]", format)
+	}
+
+	explorer, err := NewExplorer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &LedgerExporter{
+		Format:         format,
+		ReconnectDelay: DefaultReconnectDelay,
+		ctxProvider:    ctx,
+		explorer:       explorer,
+		out:            out,
+	}
+	if format == ExportCSV {
+		x.csv = csv.NewWriter(out)
+	}
+	return x, nil
+}
+
+// WriteCSVHeader writes the column names, it should only be called at the start of a new CSV file
+func (x *LedgerExporter) WriteCSVHeader() error {
+	if x.csv == nil {
+		return nil
+	}
+	if err := x.csv.Write(exportCSVHeader); err != nil {
+		return errors.Wrap(err, "writing export failed")
+	}
+	x.csv.Flush()
+	return errors.Wrap(x.csv.Error(), "writing export failed")
+}
+
+// Export writes all blocks from the checkpoint up to the current height and returns the
+// number of blocks exported
+func (x *LedgerExporter) Export(ctx context.Context) (uint64, error) {
+	if err := x.loadCheckpoint(); err != nil {
+		return 0, err
+	}
+
+	info, err := x.explorer.ChainInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var exported uint64
+	for ; x.next < info.Height; exported++ {
+		block, err := x.explorer.BlockByNumber(ctx, x.next)
+		if err != nil {
+			return exported, err
+		}
+		if err := x.writeBlock(block); err != nil {
+			return exported, err
+		}
+	}
+	return exported, nil
+}
+
+// Follow exports all blocks like Export and then keeps exporting new blocks as they are
+// committed, until ctx is done
+func (x *LedgerExporter) Follow(ctx context.Context) error {
+	if _, err := x.Export(ctx); err != nil {
+		return err
+	}
+
+	for {
+		err := x.followFrom(ctx)
+		if err != errEventsDisconnected {
+			return err
+		}
+
+		logger.Warnf("block event connection lost, reconnecting in %s", x.ReconnectDelay)
+		select {
+		case <-time.After(x.ReconnectDelay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// followFrom exports the blocks delivered by the event service, starting at the next block
+func (x *LedgerExporter) followFrom(ctx context.Context) error {
+	events, err := event.New(x.ctxProvider, event.WithBlockEvents(), event.WithSeekType(seek.FromBlock), event.WithBlockNum(x.next))
+	if err != nil {
+		if ClassifyError(err) == ErrConnection {
+			logger.Warnf("failed to connect event client: %s", err)
+			return errEventsDisconnected
+		}
+		return errors.WithMessage(err, "failed to create new event client")
+	}
+
+	reg, notifier, err := events.RegisterBlockEvent()
+	if err != nil {
+		return errors.WithMessage(err, "failed to register block event")
+	}
+	defer events.Unregister(reg)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case blockEvent, ok := <-notifier:
+			if !ok {
+				return errEventsDisconnected
+			}
+			if blockEvent.Block == nil || blockEvent.Block.Header == nil || blockEvent.Block.Header.Number < x.next {
+				continue
+			}
+
+			block, err := DecodeBlock(blockEvent.Block)
+			if err != nil {
+				return err
+			}
+			if err := x.writeBlock(block); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeBlock writes the records of a block and advances the checkpoint
+func (x *LedgerExporter) writeBlock(block *BlockInfo) error {
+	for _, tx := range block.Transactions {
+		if err := x.writeRecord(newExportRecord(tx)); err != nil {
+			return err
+		}
+	}
+	if x.csv != nil {
+		x.csv.Flush()
+		if err := x.csv.Error(); err != nil {
+			return errors.Wrap(err, "writing export failed")
+		}
+	}
+
+	x.next = block.Number + 1
+	if x.CheckpointPath == "" {
+		return nil
+	}
+	return SaveCheckpoint(x.CheckpointPath, &Checkpoint{BlockNumber: block.Number})
+}
+
+func (x *LedgerExporter) writeRecord(rec *ExportRecord) error {
+	if x.Format == ExportJSONLines {
+		return errors.Wrap(json.NewEncoder(x.out).Encode(rec), "writing export failed")
+	}
+
+	args, err := json.Marshal(rec.Args)
+	if err != nil {
+		return errors.Wrap(err, "encoding args failed")
+	}
+	writes, err := json.Marshal(rec.Writes)
+	if err != nil {
+		return errors.Wrap(err, "encoding writes failed")
+	}
+	return errors.Wrap(x.csv.Write([]string{
+		strconv.FormatUint(rec.BlockNumber, 10),
+		rec.TxID,
+		rec.Timestamp.Format(time.RFC3339Nano),
+		rec.CreatorMSPID,
+		rec.CreatorSubject,
+		rec.Chaincode,
+		rec.Function,
+		string(args),
+		string(writes),
+		rec.ValidationCode,
+	}), "writing export failed")
+}
+
+// loadCheckpoint sets the next block to export from the stored checkpoint
+func (x *LedgerExporter) loadCheckpoint() error {
+	if x.CheckpointPath == "" {
+		return nil
+	}
+	cp, err := LoadCheckpoint(x.CheckpointPath)
+	if err != nil {
+		return err
+	}
+	if cp != nil && cp.BlockNumber+1 > x.next {
+		x.next = cp.BlockNumber + 1
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// newTestExporter exports the blocks of ledger from a mock peer
+func newTestExporter(t *testing.T, ledger *mockLedger, out io.Writer, format string) (*LedgerExporter, *mockPeer) {
+	peer := newMockPeer(ledger.handle)
+	x, err := NewLedgerExporter(newMockChannelProvider(t, peer), out, format)
+	if err != nil {
+		t.Fatalf("creating exporter failed: %s", err)
+	}
+	return x, peer
+}
+
+// exportedBlocks returns the numbers of the blocks queried from the peer
+func exportedBlocks(peer *mockPeer) []string {
+	var blocks []string
+	for _, args := range peer.calls {
+		if args[0] == "GetBlockByNumber" {
+			blocks = append(blocks, args[2])
+		}
+	}
+	return blocks
+}
+
+func TestLedgerExporterCSV(t *testing.T) {
+	ledger := &mockLedger{}
+	ledger.add(
+		newMockBlock(t, 0),
+		newMockBlock(t, 1,
+			mockTx{txID: "tx1", args: []string{"invoke", "set", "a", "1"}, writes: []*KVWrite{{Key: "a", Value: "1"}}, code: pb.TxValidationCode_VALID},
+			mockTx{txID: "tx2", args: []string{"invoke", "delete", "b"}, writes: []*KVWrite{{Key: "b", IsDelete: true}}, code: pb.TxValidationCode_MVCC_READ_CONFLICT},
+		),
+	)
+	out := &bytes.Buffer{}
+	x, _ := newTestExporter(t, ledger, out, ExportCSV)
+	if err := x.WriteCSVHeader(); err != nil {
+		t.Fatalf("writing header failed: %s", err)
+	}
+	if n, err := x.Export(context.Background()); err != nil || n != 2 {
+		t.Fatalf("expected 2 blocks exported, got %d: %v", n, err)
+	}
+
+	rows, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatalf("reading export failed: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected the header and 2 rows, got %d rows", len(rows))
+	}
+	if !reflect.DeepEqual(rows[0], exportCSVHeader) {
+		t.Fatalf("expected header %v, got %v", exportCSVHeader, rows[0])
+	}
+
+	want := []map[string]string{
+		{
+			"blockNumber":    "1",
+			"txId":           "tx1",
+			"timestamp":      mockTxTimestamp(1).Format(time.RFC3339Nano),
+			"creatorMspId":   "Org1MSP",
+			"creatorSubject": "CN=User1@org1.example.com",
+			"chaincode":      "examplecc",
+			"function":       "invoke",
+			"args":           `["set","a","1"]`,
+			"writes":         `[{"key":"a","value":"1"}]`,
+			"validationCode": "VALID",
+		},
+		{
+			"blockNumber":    "1",
+			"txId":           "tx2",
+			"args":           `["delete","b"]`,
+			"writes":         `[{"key":"b","isDelete":true}]`,
+			"validationCode": "MVCC_READ_CONFLICT",
+		},
+	}
+	for i, row := range rows[1:] {
+		if len(row) != len(exportCSVHeader) {
+			t.Fatalf("expected %d columns in row %d, got %d", len(exportCSVHeader), i, len(row))
+		}
+		for col, name := range exportCSVHeader {
+			v, ok := want[i][name]
+			if !ok {
+				continue
+			}
+			if name == "creatorSubject" {
+				if !strings.Contains(row[col], v) {
+					t.Errorf("expected column %s of row %d to contain [%s], got [%s]", name, i, v, row[col])
+				}
+			} else if row[col] != v {
+				t.Errorf("expected column %s of row %d to be [%s], got [%s]", name, i, v, row[col])
+			}
+		}
+	}
+}
+
+func TestLedgerExporterResume(t *testing.T) {
+	checkpoint := filepath.Join(t.TempDir(), "export.checkpoint")
+	ledger := &mockLedger{}
+	ledger.add(
+		newMockBlock(t, 0),
+		newMockBlock(t, 1, mockTx{txID: "tx1", args: []string{"invoke", "set", "a", "1"}, code: pb.TxValidationCode_VALID}),
+	)
+
+	first := &bytes.Buffer{}
+	x, _ := newTestExporter(t, ledger, first, ExportJSONLines)
+	x.CheckpointPath = checkpoint
+	if n, err := x.Export(context.Background()); err != nil || n != 2 {
+		t.Fatalf("expected 2 blocks exported, got %d: %v", n, err)
+	}
+	cp, err := LoadCheckpoint(checkpoint)
+	if err != nil || cp == nil || cp.BlockNumber != 1 {
+		t.Fatalf("expected checkpoint at block 1, got %+v: %v", cp, err)
+	}
+
+	ledger.add(
+		newMockBlock(t, 2, mockTx{txID: "tx2", args: []string{"invoke", "set", "b", "2"}, code: pb.TxValidationCode_VALID}),
+		newMockBlock(t, 3, mockTx{txID: "tx3", args: []string{"invoke", "delete", "a"}, code: pb.TxValidationCode_VALID}),
+	)
+
+	// A new exporter resumes after the checkpoint
+	second := &bytes.Buffer{}
+	x, peer := newTestExporter(t, ledger, second, ExportJSONLines)
+	x.CheckpointPath = checkpoint
+	if n, err := x.Export(context.Background()); err != nil || n != 2 {
+		t.Fatalf("expected 2 blocks exported after resuming, got %d: %v", n, err)
+	}
+	if blocks := exportedBlocks(peer); !reflect.DeepEqual(blocks, []string{"2", "3"}) {
+		t.Fatalf("expected blocks 2 and 3 to be queried, got %v", blocks)
+	}
+
+	var txIDs []string
+	dec := json.NewDecoder(second)
+	for dec.More() {
+		rec := &ExportRecord{}
+		if err := dec.Decode(rec); err != nil {
+			t.Fatalf("decoding record failed: %s", err)
+		}
+		txIDs = append(txIDs, rec.TxID)
+	}
+	if !reflect.DeepEqual(txIDs, []string{"tx2", "tx3"}) {
+		t.Fatalf("expected records tx2 and tx3, got %v", txIDs)
+	}
+	if cp, err := LoadCheckpoint(checkpoint); err != nil || cp.BlockNumber != 3 {
+		t.Fatalf("expected checkpoint at block 3, got %+v: %v", cp, err)
+	}
+
+	// Nothing is left to export
+	x, peer = newTestExporter(t, ledger, &bytes.Buffer{}, ExportJSONLines)
+	x.CheckpointPath = checkpoint
+	if n, err := x.Export(context.Background()); err != nil || n != 0 || len(exportedBlocks(peer)) != 0 {
+		t.Fatalf("expected nothing exported, got %d blocks: %v", n, err)
+	}
+}
+
+func TestNewLedgerExporterInvalidFormat(t *testing.T) {
+	if _, err := NewLedgerExporter(newMockChannelProvider(t), &bytes.Buffer{}, "xml"); err == nil {
+		t.Fatal("expected an invalid format to be rejected")
+	}
+}
@@ -1,3 +0,0 @@
-#!/bin/bash
-export ORG1CA1_FABRIC_CA_SERVER_CA_KEYFILE=/etc/hyperledger/fabric-ca-server-config/171b7e701fce182e00bc04b2c58748613fa9a428a5e48b1c39af10978dc6f51e_sk
-export ORG2CA1_FABRIC_CA_SERVER_CA_KEYFILE=/etc/hyperledger/fabric-ca-server-config/c796051e93406d0974240971cdf4ce871e6b15c42e681f889f14134801adee0c_sk
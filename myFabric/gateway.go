@@ -0,0 +1,249 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// gateway routes
+const (
+	keysPath         = "/keys/"
+	movePath         = "/move"
+	transactionsPath = "/transactions/"
+	channelPath      = "/channel"
+)
+
+// shutdownTimeout bounds the graceful shutdown of the gateway
+const shutdownTimeout = 10 * time.Second
+
+// GatewayRetryPolicy is the retry policy of the gateway. Unlike DefaultRetryPolicy a key that
+// is not found is answered with 404 right away instead of being asked for again for seconds.
+var GatewayRetryPolicy = RetryPolicy{Opts: retry.DefaultChannelOpts}
+
+// setRequest is the body of a PUT /keys/{key} request
+type setRequest struct {
+	Value string `json:"value"`
+}
+
+// moveRequest is the body of a POST /move request
+type moveRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+// NewBlockchain creates the REST gateway for the given chaincode. It uses the shared
+// chClient and org1ChannelClientContext, so connect must have been called before.
+func NewBlockchain(chaincodeID string) (*Blockchain, error) {
+	if chClient == nil || org1ChannelClientContext == nil {
+		return nil, errors.New("channel client is not initialized")
+	}
+
+	ledgerClient, err := ledger.New(org1ChannelClientContext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Failed to create new ledger client")
+	}
+
+	return &Blockchain{ChaincodeID: chaincodeID, RetryPolicy: GatewayRetryPolicy, ledger: ledgerClient}, nil
+}
+
+// ListenAndServe serves the gateway on addr until ctx is done. Ledger calls of the requests
+// in flight are cancelled and the server shuts down gracefully.
+func (bc *Blockchain) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     bc.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// Handler returns the HTTP handler serving all gateway routes
+func (bc *Blockchain) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(keysPath, bc.handleKey)
+	mux.HandleFunc(movePath, bc.handleMove)
+	mux.HandleFunc(transactionsPath, bc.handleTransaction)
+	mux.HandleFunc(channelPath, bc.handleChannel)
+	return mux
+}
+
+// handleKey serves GET, PUT and DELETE on /keys/{key}
+func (bc *Blockchain) handleKey(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, keysPath)
+	if key == "" || strings.Contains(key, "/") {
+		writeStatus(w, http.StatusNotFound, "key is required")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		val, err := GetValueFromKeyWithContext(r.Context(), chClient, bc.ChaincodeID, key, bc.RetryPolicy)
+		if err != nil {
+			writeErrorStatus(w, err)
+			return
+		}
+		writeStatus(w, http.StatusOK, map[string]string{"key": key, "value": val})
+	case http.MethodPut, http.MethodPost:
+		req := setRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeStatus(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		txID, err := SetKeyDataWithContext(r.Context(), chClient, bc.ChaincodeID, req.Value, key, bc.RetryPolicy)
+		if err != nil {
+			writeErrorStatus(w, err)
+			return
+		}
+		writeStatus(w, http.StatusOK, map[string]string{"key": key, "value": req.Value, "txid": string(txID)})
+	case http.MethodDelete:
+		txID, err := DeleteKeyDataWithContext(r.Context(), chClient, bc.ChaincodeID, key, bc.RetryPolicy)
+		if err != nil {
+			writeErrorStatus(w, err)
+			return
+		}
+		writeStatus(w, http.StatusOK, map[string]string{"key": key, "txid": string(txID)})
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleMove serves POST /move
+func (bc *Blockchain) handleMove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	req := moveRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeStatus(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.From == "" || req.To == "" || req.Amount == "" {
+		writeStatus(w, http.StatusBadRequest, "from, to and amount are required")
+		return
+	}
+
+	txID, err := MoveKeyDataWithContext(r.Context(), chClient, bc.ChaincodeID, req.From, req.To, req.Amount, bc.RetryPolicy)
+	if err != nil {
+		writeErrorStatus(w, err)
+		return
+	}
+	writeStatus(w, http.StatusOK, map[string]string{"from": req.From, "to": req.To, "amount": req.Amount, "txid": string(txID)})
+}
+
+// handleTransaction serves GET /transactions/{txid}
+func (bc *Blockchain) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	txID := fabAPI.TransactionID(strings.TrimPrefix(r.URL.Path, transactionsPath))
+	if txID == "" {
+		writeStatus(w, http.StatusNotFound, "transaction ID is required")
+		return
+	}
+
+	tx, err := bc.ledger.QueryTransaction(txID, ledger.WithParentContext(r.Context()))
+	if err != nil {
+		writeErrorStatus(w, err)
+		return
+	}
+	block, err := bc.ledger.QueryBlockByTxID(txID, ledger.WithParentContext(r.Context()))
+	if err != nil {
+		writeErrorStatus(w, err)
+		return
+	}
+
+	writeStatus(w, http.StatusOK, map[string]interface{}{
+		"txid":           txID,
+		"blockNumber":    block.Header.Number,
+		"validationCode": pb.TxValidationCode(tx.ValidationCode).String(),
+	})
+}
+
+// handleChannel serves GET /channel
+func (bc *Blockchain) handleChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	info, err := bc.ledger.QueryInfo(ledger.WithParentContext(r.Context()))
+	if err != nil {
+		writeErrorStatus(w, err)
+		return
+	}
+
+	writeStatus(w, http.StatusOK, map[string]interface{}{
+		"height":            info.BCI.Height,
+		"currentBlockHash":  hex.EncodeToString(info.BCI.CurrentBlockHash),
+		"previousBlockHash": hex.EncodeToString(info.BCI.PreviousBlockHash),
+		"endorser":          info.Endorser,
+	})
+}
+
+// writeStatus wraps the message in a Status and writes it with the given HTTP code
+func writeStatus(w http.ResponseWriter, code int, message interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(Status{Code: code, Message: message}); err != nil {
+		logger.Warnf("failed to write response: %s", err)
+	}
+}
+
+// writeErrorStatus maps the kind of err to an HTTP code
+func writeErrorStatus(w http.ResponseWriter, err error) {
+	writeStatus(w, httpStatusFor(err), err.Error())
+}
+
+func httpStatusFor(err error) int {
+	switch LedgerErrorKindOf(err) {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrMVCCConflict:
+		return http.StatusConflict
+	case ErrEndorsement, ErrForbidden:
+		return http.StatusForbidden
+	case ErrChaincode, ErrInvalidTransaction:
+		return http.StatusBadRequest
+	case ErrTimeout, ErrCanceled:
+		return http.StatusGatewayTimeout
+	case ErrConnection:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,159 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+const gatewayTxID = "4f7c2b"
+
+// newTestGateway serves the gateway of example CC from a fake chaincode on a mock peer
+// that also answers the ledger queries of the transaction gatewayTxID
+func newTestGateway(t *testing.T, cc *fakeExampleCC) (*httptest.Server, *mockPeer) {
+	peer := newMockPeer(func(args []string) mockResponse {
+		switch args[0] {
+		case "GetChainInfo":
+			info, _ := proto.Marshal(&common.BlockchainInfo{Height: 8, CurrentBlockHash: []byte{0xab}, PreviousBlockHash: []byte{0xcd}})
+			return mockResponse{Status: 200, Payload: info}
+		case "GetTransactionByID", "GetBlockByTxID":
+			if args[2] != gatewayTxID {
+				return mockResponse{Status: 500, Message: "Failed to get transaction with id " + args[2]}
+			}
+			if args[0] == "GetTransactionByID" {
+				tx, _ := proto.Marshal(&pb.ProcessedTransaction{ValidationCode: int32(pb.TxValidationCode_MVCC_READ_CONFLICT)})
+				return mockResponse{Status: 200, Payload: tx}
+			}
+			block, _ := proto.Marshal(&common.Block{Header: &common.BlockHeader{Number: 7}, Data: &common.BlockData{}, Metadata: &common.BlockMetadata{}})
+			return mockResponse{Status: 200, Payload: block}
+		}
+		return cc.handle(args)
+	})
+	setMockClients(t, peer)
+
+	bc, err := NewBlockchain("examplecc")
+	if err != nil {
+		t.Fatalf("creating gateway failed: %s", err)
+	}
+	srv := httptest.NewServer(bc.Handler())
+	t.Cleanup(srv.Close)
+	return srv, peer
+}
+
+// doRequest sends a request to the gateway and decodes the Status of the response
+func doRequest(t *testing.T, method, url, body string) (int, Status) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("creating request failed: %s", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %s", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected JSON response, got content type [%s]", ct)
+	}
+	s := Status{}
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatalf("decoding response failed: %s", err)
+	}
+	if s.Code != resp.StatusCode {
+		t.Fatalf("expected code %d of the body to be the HTTP status %d", s.Code, resp.StatusCode)
+	}
+	return resp.StatusCode, s
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	cc := newFakeExampleCC(map[string]string{"a": "100", "b": "200", "locked": "10"})
+	cc.fail["locked"] = ccErrorResponse(CCErrForbidden, "locked")
+	srv, _ := newTestGateway(t, cc)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		code    int
+		message map[string]interface{}
+		errText string
+	}{
+		{"get key", http.MethodGet, "/keys/a", "", http.StatusOK, map[string]interface{}{"key": "a", "value": "100"}, ""},
+		{"get missing key", http.MethodGet, "/keys/nokey", "", http.StatusNotFound, nil, "NOT_FOUND"},
+		{"get without key", http.MethodGet, "/keys/", "", http.StatusNotFound, nil, "key is required"},
+		{"get nested key", http.MethodGet, "/keys/a/b", "", http.StatusNotFound, nil, "key is required"},
+		{"put key", http.MethodPut, "/keys/c", `{"value":"5"}`, http.StatusOK, map[string]interface{}{"key": "c", "value": "5"}, ""},
+		{"put invalid body", http.MethodPut, "/keys/c", `{"value":`, http.StatusBadRequest, nil, "invalid request body"},
+		{"delete key", http.MethodDelete, "/keys/c", "", http.StatusOK, map[string]interface{}{"key": "c"}, ""},
+		{"delete missing key", http.MethodDelete, "/keys/c", "", http.StatusNotFound, nil, "NOT_FOUND"},
+		{"patch key", http.MethodPatch, "/keys/a", "", http.StatusMethodNotAllowed, nil, "method not allowed"},
+		{"move", http.MethodPost, "/move", `{"from":"a","to":"b","amount":"10"}`, http.StatusOK, map[string]interface{}{"from": "a", "to": "b", "amount": "10"}, ""},
+		{"move forbidden", http.MethodPost, "/move", `{"from":"locked","to":"b","amount":"1"}`, http.StatusForbidden, nil, "FORBIDDEN"},
+		{"move insufficient", http.MethodPost, "/move", `{"from":"a","to":"b","amount":"1000"}`, http.StatusBadRequest, nil, "INSUFFICIENT_FUNDS"},
+		{"move missing amount", http.MethodPost, "/move", `{"from":"a","to":"b"}`, http.StatusBadRequest, nil, "from, to and amount are required"},
+		{"move invalid body", http.MethodPost, "/move", `[]`, http.StatusBadRequest, nil, "invalid request body"},
+		{"move with get", http.MethodGet, "/move", "", http.StatusMethodNotAllowed, nil, "method not allowed"},
+		{"transaction", http.MethodGet, "/transactions/" + gatewayTxID, "", http.StatusOK, map[string]interface{}{"txid": gatewayTxID, "blockNumber": float64(7), "validationCode": "MVCC_READ_CONFLICT"}, ""},
+		{"unknown transaction", http.MethodGet, "/transactions/ffff", "", http.StatusBadRequest, nil, "Failed to get transaction"},
+		{"transaction without ID", http.MethodGet, "/transactions/", "", http.StatusNotFound, nil, "transaction ID is required"},
+		{"transaction with post", http.MethodPost, "/transactions/" + gatewayTxID, "", http.StatusMethodNotAllowed, nil, "method not allowed"},
+		{"channel", http.MethodGet, "/channel", "", http.StatusOK, map[string]interface{}{"height": float64(8), "currentBlockHash": "ab", "previousBlockHash": "cd", "endorser": "peer0.mock:7051"}, ""},
+		{"channel with put", http.MethodPut, "/channel", "", http.StatusMethodNotAllowed, nil, "method not allowed"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, s := doRequest(t, tc.method, srv.URL+tc.path, tc.body)
+			if code != tc.code {
+				t.Fatalf("expected HTTP status %d, got %d: %v", tc.code, code, s.Message)
+			}
+			if tc.errText != "" {
+				msg, ok := s.Message.(string)
+				if !ok || !strings.Contains(msg, tc.errText) {
+					t.Fatalf("expected error message containing [%s], got %v", tc.errText, s.Message)
+				}
+				return
+			}
+			msg, ok := s.Message.(map[string]interface{})
+			if !ok {
+				t.Fatalf("expected an object message, got %v", s.Message)
+			}
+			for k, v := range tc.message {
+				if msg[k] != v {
+					t.Fatalf("expected %s [%v], got [%v]", k, v, msg[k])
+				}
+			}
+			if tc.method != http.MethodGet && msg["txid"] == "" {
+				t.Fatalf("expected the transaction ID in %v", msg)
+			}
+		})
+	}
+
+	if a, b := cc.get("a"), cc.get("b"); a != "90" || b != "210" {
+		t.Fatalf("expected a=90 and b=210 after the move, got a=%s b=%s", a, b)
+	}
+}
+
+func TestGatewayGetNotFoundIsNotRetried(t *testing.T) {
+	srv, peer := newTestGateway(t, newFakeExampleCC(map[string]string{}))
+
+	code, _ := doRequest(t, http.MethodGet, srv.URL+"/keys/nokey", "")
+	if code != http.StatusNotFound {
+		t.Fatalf("expected HTTP status %d, got %d", http.StatusNotFound, code)
+	}
+	if len(peer.calls) != 1 {
+		t.Fatalf("expected a missing key to be queried once, got %d queries", len(peer.calls))
+	}
+}
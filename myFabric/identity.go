@@ -0,0 +1,158 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+)
+
+// ExampleCCAdminAttribute is the certificate attribute that lets example CC users change
+// keys they do not own
+const ExampleCCAdminAttribute = "examplecc.admin"
+
+// KeyOwner is the identity recorded by example CC as the owner of a key
+type KeyOwner struct {
+	MSPID   string `json:"mspId"`
+	Subject string `json:"subject"`
+}
+
+// KeyOwnerOf returns the owner example CC records for the keys created by the given identity
+func KeyOwnerOf(identity msp.SigningIdentity) (*KeyOwner, error) {
+	block, _ := pem.Decode(identity.EnrollmentCertificate())
+	if block == nil {
+		return nil, errors.New("decoding enrollment certificate failed")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing enrollment certificate failed")
+	}
+	return &KeyOwner{MSPID: identity.Identifier().MSPID, Subject: cert.Subject.String()}, nil
+}
+
+// UserRegistration describes a user registered with the CA of an org
+type UserRegistration struct {
+	Name string
+	// Secret is generated by the CA if empty
+	Secret string
+	// Affiliation defaults to department1 of the org
+	Affiliation string
+	// Admin adds ExampleCCAdminAttribute to the enrollment certificates of the user
+	Admin      bool
+	Attributes []mspclient.Attribute
+}
+
+// RegisterUser registers a user with the CA of the given org using the registrar of the
+// SDK config, and returns the enrollment secret
+func RegisterUser(sdk *fabsdk.FabricSDK, orgName string, reg UserRegistration) (string, error) {
+	mspClient, err := mspclient.New(sdk.Context(), mspclient.WithOrg(orgName))
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to create msp client")
+	}
+
+	affiliation := reg.Affiliation
+	if affiliation == "" {
+		affiliation = strings.ToLower(orgName) + ".department1"
+	}
+
+	attributes := append([]mspclient.Attribute(nil), reg.Attributes...)
+	if reg.Admin {
+		attributes = append(attributes, mspclient.Attribute{Name: ExampleCCAdminAttribute, Value: "true", ECert: true})
+	}
+
+	secret, err := mspClient.Register(&mspclient.RegistrationRequest{
+		Name:        reg.Name,
+		Type:        "client",
+		Affiliation: affiliation,
+		Attributes:  attributes,
+		Secret:      reg.Secret,
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "registering user ["+reg.Name+"] failed")
+	}
+	return secret, nil
+}
+
+// EnrollUser enrolls a registered user with the CA of the given org and stores its
+// certificate, so that the user can be selected with fabsdk.WithUser.
+// If admin is set the enrollment requires ExampleCCAdminAttribute.
+func EnrollUser(sdk *fabsdk.FabricSDK, orgName, name, secret string, admin bool) error {
+	mspClient, err := mspclient.New(sdk.Context(), mspclient.WithOrg(orgName))
+	if err != nil {
+		return errors.WithMessage(err, "failed to create msp client")
+	}
+
+	opts := []mspclient.EnrollmentOption{mspclient.WithSecret(secret)}
+	if admin {
+		opts = append(opts, mspclient.WithAttributeRequests([]*mspclient.AttributeRequest{{Name: ExampleCCAdminAttribute}}))
+	}
+	if err := mspClient.Enroll(name, opts...); err != nil {
+		return errors.WithMessage(err, "enrolling user ["+name+"] failed")
+	}
+	return nil
+}
+
+// ExampleCCOwnerArgs returns example cc args that query the owner of the given key
+func ExampleCCOwnerArgs(key string) [][]byte {
+	return [][]byte{[]byte("owner"), []byte(key)}
+}
+
+// ExampleCCChownArgs returns example cc args that hand the given key over to another owner
+func ExampleCCChownArgs(key string, owner KeyOwner) [][]byte {
+	return [][]byte{[]byte("chown"), []byte(key), []byte(owner.MSPID), []byte(owner.Subject)}
+}
+
+// GetKeyOwnerWithContext queries the owner of the given key in cc. The keys set by init and
+// reset are owned by the identity that instantiated or reset cc.
+// On failure a *LedgerError is returned, with Kind ErrNotFound if the key has no owner.
+func GetKeyOwnerWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, policy RetryPolicy) (*KeyOwner, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCOwnerArgs(key),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "owner", key, err)
+	}
+
+	owner := &KeyOwner{}
+	if err := json.Unmarshal(response.Payload, owner); err != nil {
+		return nil, errors.Wrap(err, "decoding owner failed")
+	}
+	return owner, nil
+}
+
+// ChangeKeyOwnerWithContext hands the given key over to another owner. Only the current owner
+// and admins may do so. On failure a *LedgerError is returned.
+func ChangeKeyOwnerWithContext(reqCtx context.Context, chClient *channel.Client, ccID, key string, owner KeyOwner, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCChownArgs(key, owner),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "chown", key, err)
+	}
+
+	return response.TransactionID, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// InvokeTarget is a chaincode called by example CC through invokecc
+type InvokeTarget struct {
+	ChaincodeID string
+	// ChannelID is the channel of the chaincode, the channel of example CC if empty.
+	// Chaincodes on other channels can only be queried.
+	ChannelID string
+	Args      []string
+	// Marker records the call in the <txid>_invokedcc key of example CC
+	Marker bool
+}
+
+// InvokeCCResult is the response of a chaincode called through invokecc
+type InvokeCCResult struct {
+	ChaincodeID string `json:"chaincodeId"`
+	ChannelID   string `json:"channelId"`
+	Status      int32  `json:"status"`
+	Message     string `json:"message,omitempty"`
+	Payload     []byte `json:"payload"`
+}
+
+// AllowedChaincode is a chaincode that example CC may call through invokecc
+type AllowedChaincode struct {
+	ChannelID   string `json:"channelId"`
+	ChaincodeID string `json:"chaincodeId"`
+}
+
+// ExampleCCInvokeCCArgs returns example cc invokecc args calling the given target
+func ExampleCCInvokeCCArgs(target InvokeTarget) ([][]byte, error) {
+	argsJSON, err := json.Marshal(struct {
+		Args []string `json:"Args"`
+	}{target.Args})
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding invoke args failed")
+	}
+	return [][]byte{[]byte(target.ChaincodeID), argsJSON, []byte(target.ChannelID), []byte(strconv.FormatBool(target.Marker))}, nil
+}
+
+// InvokeChaincodeWithContext calls the target through the invokecc function of cc, in a
+// transaction if execute is set or else in a query. The target must be on the allow-list of cc.
+// On failure a *LedgerError is returned, with Kind ErrForbidden if the target is not allowed.
+func InvokeChaincodeWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, target InvokeTarget, execute bool, policy RetryPolicy) (*InvokeCCResult, fabAPI.TransactionID, error) {
+	args, err := ExampleCCInvokeCCArgs(target)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req := channel.Request{
+		ChaincodeID: ccID,
+		Fcn:         "invokecc",
+		Args:        args,
+	}
+	if target.ChannelID == "" {
+		// The callee is endorsed by the same peers, so its policy must be considered in selection
+		req.InvocationChain = []*fabAPI.ChaincodeCall{{ID: target.ChaincodeID}}
+	}
+
+	var response channel.Response
+	if execute {
+		response, err = chClient.Execute(req, channel.WithParentContext(reqCtx), channel.WithRetry(policy.Opts))
+	} else {
+		response, err = chClient.Query(req, channel.WithParentContext(reqCtx), channel.WithRetry(policy.Opts))
+	}
+	if err != nil {
+		return nil, "", newLedgerErrorWithContext(reqCtx, "invokecc", target.ChaincodeID, err)
+	}
+
+	result := &InvokeCCResult{}
+	if err := json.Unmarshal(response.Payload, result); err != nil {
+		return nil, "", errors.Wrap(err, "decoding invokecc response failed")
+	}
+	return result, response.TransactionID, nil
+}
+
+// SetInvokeAllowedWithContext adds the target chaincode to the invokecc allow-list of cc, or
+// removes it if allow is not set. The channel of cc is used if targetChannelID is empty.
+// Only admins may change the allow-list. On failure a *LedgerError is returned.
+func SetInvokeAllowedWithContext(reqCtx context.Context, chClient *channel.Client, ccID, targetCCID, targetChannelID string, allow bool, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	fcn := "allowcc"
+	if !allow {
+		fcn = "disallowcc"
+	}
+
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         fcn,
+			Args:        [][]byte{[]byte(targetCCID), []byte(targetChannelID)},
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, fcn, targetCCID, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// GetInvokeAllowListWithContext queries the chaincodes that cc may call through invokecc.
+// On failure a *LedgerError is returned.
+func GetInvokeAllowListWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, policy RetryPolicy) ([]AllowedChaincode, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "allowedcc",
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "allowedcc", "", err)
+	}
+
+	var allowed []AllowedChaincode
+	if err := json.Unmarshal(response.Payload, &allowed); err != nil {
+		return nil, errors.Wrap(err, "decoding allow-list failed")
+	}
+	return allowed, nil
+}
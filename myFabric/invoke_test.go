@@ -0,0 +1,166 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExampleCCInvokeCCArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		target InvokeTarget
+		want   []string
+	}{
+		{
+			name:   "same channel",
+			target: InvokeTarget{ChaincodeID: "othercc", Args: []string{"query", "a"}},
+			want:   []string{"othercc", `{"Args":["query","a"]}`, "", "false"},
+		},
+		{
+			name:   "other channel with marker",
+			target: InvokeTarget{ChaincodeID: "othercc", ChannelID: "otherchannel", Args: []string{"invoke", "set", "a", "1"}, Marker: true},
+			want:   []string{"othercc", `{"Args":["invoke","set","a","1"]}`, "otherchannel", "true"},
+		},
+		{
+			name:   "no args",
+			target: InvokeTarget{ChaincodeID: "othercc"},
+			want:   []string{"othercc", `{"Args":null}`, "", "false"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := ExampleCCInvokeCCArgs(tc.target)
+			if err != nil {
+				t.Fatalf("encoding args failed: %s", err)
+			}
+			got := make([]string, len(args))
+			for i, arg := range args {
+				got[i] = string(arg)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected args %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInvokeChaincodeWithContext(t *testing.T) {
+	peer := newMockPeer(func(args []string) mockResponse {
+		if args[1] == "forbiddencc" {
+			return ccErrorResponse(CCErrForbidden, args[1])
+		}
+		if args[1] == "brokencc" {
+			return ccSuccess("{")
+		}
+		result, _ := json.Marshal(InvokeCCResult{ChaincodeID: args[1], ChannelID: mockChannelID, Status: 200, Payload: []byte("100")})
+		return mockResponse{Status: 200, Payload: result}
+	})
+	setMockClients(t, peer)
+	target := InvokeTarget{ChaincodeID: "othercc", Args: []string{"query", "a"}}
+
+	for _, execute := range []bool{false, true} {
+		result, txID, err := InvokeChaincodeWithContext(context.Background(), chClient, "examplecc", target, execute, RetryPolicy{})
+		if err != nil {
+			t.Fatalf("invoking (execute %t) failed: %s", execute, err)
+		}
+		if result.ChaincodeID != "othercc" || result.ChannelID != mockChannelID || result.Status != 200 || string(result.Payload) != "100" {
+			t.Fatalf("unexpected result %+v", result)
+		}
+		if txID == "" {
+			t.Fatal("expected the ID of the transaction")
+		}
+		if args := peer.argsOfTx(string(txID)); !reflect.DeepEqual(args, []string{"invokecc", "othercc", `{"Args":["query","a"]}`, "", "false"}) {
+			t.Fatalf("unexpected invokecc args %q", args)
+		}
+	}
+
+	target.ChaincodeID = "forbiddencc"
+	if _, _, err := InvokeChaincodeWithContext(context.Background(), chClient, "examplecc", target, true, RetryPolicy{}); !IsLedgerErrorKind(err, ErrForbidden) {
+		t.Fatalf("expected a target that is not allowed to be forbidden, got %v", err)
+	}
+	target.ChaincodeID = "brokencc"
+	if _, _, err := InvokeChaincodeWithContext(context.Background(), chClient, "examplecc", target, false, RetryPolicy{}); err == nil {
+		t.Fatal("expected an invalid response to fail")
+	}
+}
+
+func TestSetInvokeAllowedWithContext(t *testing.T) {
+	peer := newMockPeer(func(args []string) mockResponse {
+		if args[1] == "forbiddencc" {
+			return ccErrorResponse(CCErrForbidden, args[1])
+		}
+		return ccSuccess("")
+	})
+	setMockClients(t, peer)
+
+	tests := []struct {
+		channelID string
+		allow     bool
+		want      []string
+	}{
+		{channelID: "", allow: true, want: []string{"allowcc", "othercc", ""}},
+		{channelID: "otherchannel", allow: true, want: []string{"allowcc", "othercc", "otherchannel"}},
+		{channelID: "otherchannel", allow: false, want: []string{"disallowcc", "othercc", "otherchannel"}},
+	}
+	for _, tc := range tests {
+		txID, err := SetInvokeAllowedWithContext(context.Background(), chClient, "examplecc", "othercc", tc.channelID, tc.allow, RetryPolicy{})
+		if err != nil {
+			t.Fatalf("changing the allow-list failed: %s", err)
+		}
+		if args := peer.argsOfTx(string(txID)); !reflect.DeepEqual(args, tc.want) {
+			t.Fatalf("expected args %q, got %q", tc.want, args)
+		}
+	}
+
+	if _, err := SetInvokeAllowedWithContext(context.Background(), chClient, "examplecc", "forbiddencc", "", true, RetryPolicy{}); !IsLedgerErrorKind(err, ErrForbidden) {
+		t.Fatalf("expected a forbidden change to fail with ErrForbidden, got %v", err)
+	}
+}
+
+func TestGetInvokeAllowListWithContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []AllowedChaincode
+		wantErr bool
+	}{
+		{
+			name:    "allowed",
+			payload: `[{"channelId":"mockchannel","chaincodeId":"othercc"},{"channelId":"otherchannel","chaincodeId":"thirdcc"}]`,
+			want:    []AllowedChaincode{{ChannelID: mockChannelID, ChaincodeID: "othercc"}, {ChannelID: "otherchannel", ChaincodeID: "thirdcc"}},
+		},
+		{name: "empty", payload: `[]`, want: []AllowedChaincode{}},
+		{name: "invalid", payload: `{`, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			peer := newMockPeer(func(args []string) mockResponse { return ccSuccess(tc.payload) })
+			setMockClients(t, peer)
+
+			allowed, err := GetInvokeAllowListWithContext(context.Background(), chClient, "examplecc", RetryPolicy{})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected decoding the allow-list to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("querying the allow-list failed: %s", err)
+			}
+			if !reflect.DeepEqual(allowed, tc.want) {
+				t.Fatalf("expected allow-list %+v, got %+v", tc.want, allowed)
+			}
+			if len(peer.calls) != 1 || !reflect.DeepEqual(peer.calls[0], []string{"allowedcc"}) {
+				t.Fatalf("expected a single allowedcc query, got %q", peer.calls)
+			}
+		})
+	}
+}
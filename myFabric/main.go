@@ -1,11 +1,11 @@
 package main
 
 import (
+	"os"
 
-	//"encoding/json"
-	//"errors"
-	"fmt"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/logging"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
 
 	contextApi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
@@ -19,6 +19,8 @@ var(
 	org1ChannelClientContext contextApi.ChannelProvider
 	chClient *channel.Client
 	err error
+
+	logger = logging.NewLogger("myFabric")
 )
 
 
@@ -28,50 +30,13 @@ type Status struct {
 	Message interface{} `json:"message"`
 }
 
-// Blockchain ...
+// Blockchain is the REST gateway to the example chaincode
 type Blockchain struct {
-
+	ChaincodeID string
+	RetryPolicy RetryPolicy
+	ledger      *ledger.Client
 }
 
-func main(){
-
-	fmt.Println("come to main")
-
-
-	//init
-	r := NewWithExampleCC()
-	r.Initialize()
-	mainSDK = r.SDK()
-	mainTestSetup = r.TestSetup()
-	mainChaincodeID = r.ExampleChaincodeID()
-
-	fmt.Println("finish init")
-
-
-	//set logic key
-	aKey := "keyA"
-	//bKey := "keyB"
-
-	//prepare context
-	org1ChannelClientContext = mainSDK.ChannelContext(mainTestSetup.ChannelID, fabsdk.WithUser(r.Org1User), fabsdk.WithOrg(r.Org1Name))
-
-	//get channel client
-	chClient, err = channel.New(org1ChannelClientContext)
-	if err != nil {panic(err)}
-
-	//ledgerClient, err := ledger.New(org1ChannelClientContext)
-	if err != nil {panic(err)}
-
-	//1.set single
-	txid := SetKeyData(org1ChannelClientContext, mainChaincodeID, "250",aKey)
-	fmt.Printf("key is %s,txid is %s \n",aKey,txid)
-
-	//2.get data from key
-	val := GetValueFromKey(chClient,mainChaincodeID,aKey)
-	fmt.Printf("val is %s \n",val)
-
-
-
-
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
 }
-
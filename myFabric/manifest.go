@@ -0,0 +1,509 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	packager "github.com/hyperledger/fabric-sdk-go/pkg/fab/ccpackager/gopackager"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	"myFabric/configtxgen"
+)
+
+// NetworkManifestPath is the relative path to the manifest of the default network
+var NetworkManifestPath = "fixtures/config/network.yaml"
+
+// NetworkManifest declares the orgs, channels and chaincodes of a network
+type NetworkManifest struct {
+	Orderer    ManifestOrderer     `yaml:"orderer"`
+	Orgs       []ManifestOrg       `yaml:"orgs"`
+	Channels   []ManifestChannel   `yaml:"channels"`
+	Chaincodes []ManifestChaincode `yaml:"chaincodes"`
+}
+
+// ManifestOrderer is the orderer that creates and updates the channels
+type ManifestOrderer struct {
+	// Org is the orderer org whose admin creates the channels, OrdererOrg if empty
+	Org string `yaml:"org"`
+	// Endpoint is the orderer of the SDK config used for config updates, orderer.example.com if empty
+	Endpoint string `yaml:"endpoint"`
+}
+
+// ManifestOrg is a peer org of the network
+type ManifestOrg struct {
+	Name string `yaml:"name"`
+	// AdminUser manages the peers of the org, Admin if empty
+	AdminUser     string `yaml:"adminUser"`
+	ExpectedPeers int    `yaml:"expectedPeers"`
+}
+
+// ManifestChannel is a channel and the orgs whose peers join it
+type ManifestChannel struct {
+	Name string `yaml:"name"`
+	// Tx is the channel creation transaction, relative to ChannelConfigPath
+	Tx string `yaml:"tx"`
+	// Profile is a channel profile of ConfigtxPath. If Tx is empty the creation transaction
+	// is generated from it, as are the anchor peer updates of members without Anchors.
+	Profile string           `yaml:"profile"`
+	Members []ManifestMember `yaml:"members"`
+}
+
+// ManifestMember is an org of a channel
+type ManifestMember struct {
+	Org string `yaml:"org"`
+	// Anchors is the anchor peer update transaction of the org, relative to ChannelConfigPath.
+	// It is applied if the channel config has no anchor peers of the org yet.
+	Anchors string `yaml:"anchors"`
+}
+
+// ManifestChaincode is a chaincode instantiated on a channel
+type ManifestChaincode struct {
+	Name    string `yaml:"name"`
+	Path    string `yaml:"path"`
+	Version string `yaml:"version"`
+	Channel string `yaml:"channel"`
+	// Orgs install the chaincode on their peers, all members of the channel if empty
+	Orgs []string `yaml:"orgs"`
+	// Policy is the endorsement policy, a member of each org if empty
+	Policy string `yaml:"policy"`
+	// Collections is a collection definition file like CollectionConfigPath
+	Collections string   `yaml:"collections"`
+	Args        []string `yaml:"args"`
+}
+
+// LoadNetworkManifest reads and validates a network manifest
+func LoadNetworkManifest(path string) (*NetworkManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading network manifest [%s] failed", path)
+	}
+
+	m := &NetworkManifest{}
+	if err := yaml.UnmarshalStrict(data, m); err != nil {
+		return nil, errors.Wrapf(err, "decoding network manifest [%s] failed", path)
+	}
+	if err := m.validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid network manifest ["+path+"]")
+	}
+	return m, nil
+}
+
+// validate checks the references among orgs, channels and chaincodes and fills in defaults
+func (m *NetworkManifest) validate() error {
+	if m.Orderer.Org == "" {
+		m.Orderer.Org = ordererOrgName
+	}
+	if m.Orderer.Endpoint == "" {
+		m.Orderer.Endpoint = ordererEndpoint
+	}
+
+	orgs := make(map[string]bool)
+	for i := range m.Orgs {
+		org := &m.Orgs[i]
+		if org.Name == "" {
+			return errors.New("org name is required")
+		}
+		if orgs[org.Name] {
+			return errors.Errorf("duplicate org [%s]", org.Name)
+		}
+		if org.ExpectedPeers <= 0 {
+			return errors.Errorf("expectedPeers of org [%s] must be positive", org.Name)
+		}
+		if org.AdminUser == "" {
+			org.AdminUser = AdminUser
+		}
+		orgs[org.Name] = true
+	}
+
+	channels := make(map[string]map[string]bool)
+	for _, ch := range m.Channels {
+		if ch.Name == "" || (ch.Tx == "" && ch.Profile == "") {
+			return errors.New("channel name and tx or profile are required")
+		}
+		if channels[ch.Name] != nil {
+			return errors.Errorf("duplicate channel [%s]", ch.Name)
+		}
+		if len(ch.Members) == 0 {
+			return errors.Errorf("channel [%s] has no members", ch.Name)
+		}
+		members := make(map[string]bool)
+		for _, member := range ch.Members {
+			if !orgs[member.Org] {
+				return errors.Errorf("unknown org [%s] of channel [%s]", member.Org, ch.Name)
+			}
+			members[member.Org] = true
+		}
+		channels[ch.Name] = members
+	}
+
+	ccs := make(map[string]bool)
+	for i := range m.Chaincodes {
+		cc := &m.Chaincodes[i]
+		if cc.Name == "" || cc.Path == "" || cc.Version == "" {
+			return errors.New("chaincode name, path and version are required")
+		}
+		members, ok := channels[cc.Channel]
+		if !ok {
+			return errors.Errorf("unknown channel [%s] of chaincode [%s]", cc.Channel, cc.Name)
+		}
+		if ccs[cc.Channel+"/"+cc.Name] {
+			return errors.Errorf("duplicate chaincode [%s] on channel [%s]", cc.Name, cc.Channel)
+		}
+		ccs[cc.Channel+"/"+cc.Name] = true
+		if len(cc.Orgs) == 0 {
+			cc.Orgs = m.Channel(cc.Channel).memberOrgs()
+		}
+		for _, org := range cc.Orgs {
+			if !members[org] {
+				return errors.Errorf("org [%s] of chaincode [%s] is not a member of channel [%s]", org, cc.Name, cc.Channel)
+			}
+		}
+	}
+	return nil
+}
+
+// Org returns the org of the given name, or nil if the manifest does not declare it
+func (m *NetworkManifest) Org(name string) *ManifestOrg {
+	for i := range m.Orgs {
+		if m.Orgs[i].Name == name {
+			return &m.Orgs[i]
+		}
+	}
+	return nil
+}
+
+// Channel returns the channel of the given name, or nil if the manifest does not declare it
+func (m *NetworkManifest) Channel(name string) *ManifestChannel {
+	for i := range m.Channels {
+		if m.Channels[i].Name == name {
+			return &m.Channels[i]
+		}
+	}
+	return nil
+}
+
+func (ch *ManifestChannel) memberOrgs() []string {
+	orgs := make([]string, len(ch.Members))
+	for i, member := range ch.Members {
+		orgs[i] = member.Org
+	}
+	return orgs
+}
+
+// reconciler applies a manifest to the network and records the changes it made
+type reconciler struct {
+	sdk     *fabsdk.FabricSDK
+	m       *NetworkManifest
+	orgs    map[string]*OrgContext
+	changes []string
+}
+
+// ReconcileNetwork brings the network to the state declared by the manifest: it creates missing
+// channels, joins missing peers, updates missing anchor peers, installs chaincodes and instantiates
+// or upgrades those whose version differs. Whatever already matches the manifest is left alone,
+// so it may be run repeatedly. The changes made are returned, none if the network matched.
+func ReconcileNetwork(sdk *fabsdk.FabricSDK, m *NetworkManifest) ([]string, error) {
+	r := &reconciler{sdk: sdk, m: m, orgs: make(map[string]*OrgContext), changes: []string{}}
+
+	for _, ch := range m.Channels {
+		if err := r.reconcileChannel(ch); err != nil {
+			return r.changes, errors.WithMessage(err, "reconciling channel ["+ch.Name+"] failed")
+		}
+	}
+	for _, cc := range m.Chaincodes {
+		if err := r.reconcileChaincode(cc); err != nil {
+			return r.changes, errors.WithMessage(err, "reconciling chaincode ["+cc.Name+"] failed")
+		}
+	}
+	return r.changes, nil
+}
+
+func (r *reconciler) changed(format string, args ...interface{}) {
+	r.changes = append(r.changes, fmt.Sprintf(format, args...))
+}
+
+// orgContext returns the context of the org admin, created on first use
+func (r *reconciler) orgContext(orgName string) (*OrgContext, error) {
+	if orgCtx, ok := r.orgs[orgName]; ok {
+		return orgCtx, nil
+	}
+
+	org := r.m.Org(orgName)
+	orgCtx, err := newOrgContext(r.sdk, fabsdk.WithUser(org.AdminUser), org.Name, org.ExpectedPeers)
+	if err != nil {
+		return nil, errors.WithMessage(err, "preparing context of org ["+orgName+"] failed")
+	}
+
+	mspClient, err := mspclient.New(r.sdk.Context(), mspclient.WithOrg(orgName))
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating MSP client of org ["+orgName+"] failed")
+	}
+	orgCtx.SigningIdentity, err = mspClient.GetSigningIdentity(org.AdminUser)
+	if err != nil {
+		return nil, errors.WithMessage(err, "getting admin identity of org ["+orgName+"] failed")
+	}
+
+	r.orgs[orgName] = orgCtx
+	return orgCtx, nil
+}
+
+func (r *reconciler) orgContexts(orgNames []string) ([]*OrgContext, error) {
+	orgContexts := make([]*OrgContext, len(orgNames))
+	for i, orgName := range orgNames {
+		orgCtx, err := r.orgContext(orgName)
+		if err != nil {
+			return nil, err
+		}
+		orgContexts[i] = orgCtx
+	}
+	return orgContexts, nil
+}
+
+func (r *reconciler) reconcileChannel(ch ManifestChannel) error {
+	members, err := r.orgContexts(ch.memberOrgs())
+	if err != nil {
+		return err
+	}
+
+	exists := false
+	unjoined := make(map[string][]fabAPI.Peer)
+	for _, orgCtx := range members {
+		for _, peer := range orgCtx.Peers {
+			joined, err := IsJoinedChannel(ch.Name, orgCtx.ResMgmt, peer)
+			if err != nil {
+				return errors.WithMessage(err, "querying channels of peer ["+peer.URL()+"] failed")
+			}
+			if joined {
+				exists = true
+			} else {
+				unjoined[orgCtx.OrgID] = append(unjoined[orgCtx.OrgID], peer)
+			}
+		}
+	}
+
+	// A channel may exist without any of our peers, the orderer tells
+	if !exists {
+		_, err := members[0].ResMgmt.QueryConfigFromOrderer(ch.Name, resmgmt.WithOrdererEndpoint(r.m.Orderer.Endpoint))
+		exists = err == nil
+	}
+	if !exists {
+		if err := r.createChannel(ch, members); err != nil {
+			return err
+		}
+	}
+
+	if err := r.updateAnchors(ch, members); err != nil {
+		return err
+	}
+
+	for _, orgCtx := range members {
+		peers := unjoined[orgCtx.OrgID]
+		if len(peers) == 0 {
+			continue
+		}
+		err := orgCtx.ResMgmt.JoinChannel(
+			ch.Name,
+			resmgmt.WithRetry(retry.DefaultResMgmtOpts),
+			resmgmt.WithOrdererEndpoint(r.m.Orderer.Endpoint),
+			resmgmt.WithTargets(peers...),
+		)
+		if err != nil {
+			return errors.Wrapf(err, "failed to join peers in org [%s] to channel [%s]", orgCtx.OrgID, ch.Name)
+		}
+		r.changed("joined %d peers of org %s to channel %s", len(peers), orgCtx.OrgID, ch.Name)
+	}
+	return nil
+}
+
+// createChannel submits the channel creation transaction signed by the admins of all members
+func (r *reconciler) createChannel(ch ManifestChannel, members []*OrgContext) error {
+	ordererCtx := r.sdk.Context(fabsdk.WithUser(AdminUser), fabsdk.WithOrg(r.m.Orderer.Org))
+	chMgmtClient, err := resmgmt.New(ordererCtx)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get a new resmgmt client for orderer")
+	}
+
+	var signingIdentities []msp.SigningIdentity
+	for _, orgCtx := range members {
+		signingIdentities = append(signingIdentities, orgCtx.SigningIdentity)
+	}
+	req := resmgmt.SaveChannelRequest{
+		ChannelID:         ch.Name,
+		SigningIdentities: signingIdentities,
+	}
+	if ch.Tx != "" {
+		req.ChannelConfigPath = GetChannelConfigPath(ch.Tx)
+	} else {
+		profile, err := configtxgen.LoadProfile(GetConfigtxPath(), ch.Profile)
+		if err != nil {
+			return err
+		}
+		tx, err := configtxgen.ChannelCreateTx(profile, ch.Name)
+		if err != nil {
+			return errors.WithMessage(err, "generating channel creation transaction failed")
+		}
+		req.ChannelConfig = bytes.NewReader(tx)
+	}
+	if _, err := chMgmtClient.SaveChannel(req, resmgmt.WithRetry(retry.DefaultResMgmtOpts), resmgmt.WithOrdererEndpoint(r.m.Orderer.Endpoint)); err != nil {
+		return errors.WithMessage(err, "creating channel failed")
+	}
+	if _, err := waitForConfigBlock(members[0].ResMgmt, ch.Name, r.m.Orderer.Endpoint, true, 0); err != nil {
+		return err
+	}
+
+	r.changed("created channel %s", ch.Name)
+	return nil
+}
+
+// updateAnchors applies the anchor peer updates of the members that have no anchor peers yet
+func (r *reconciler) updateAnchors(ch ManifestChannel, members []*OrgContext) error {
+	chConfig, err := members[0].ResMgmt.QueryConfigFromOrderer(ch.Name, resmgmt.WithOrdererEndpoint(r.m.Orderer.Endpoint))
+	if err != nil {
+		return errors.WithMessage(err, "querying channel config failed")
+	}
+	anchored := make(map[string]bool)
+	for _, anchor := range chConfig.AnchorPeers() {
+		anchored[anchor.Org] = true
+	}
+	lastConfigBlock := chConfig.BlockNumber()
+
+	for i, member := range ch.Members {
+		if member.Anchors == "" && ch.Profile == "" {
+			continue
+		}
+		mspID, err := orgMSPID(r.sdk, member.Org)
+		if err != nil {
+			return errors.WithMessage(err, "MSP ID of org ["+member.Org+"] could not be determined")
+		}
+		if anchored[mspID] {
+			continue
+		}
+
+		orgCtx := members[i]
+		req := resmgmt.SaveChannelRequest{
+			ChannelID:         ch.Name,
+			SigningIdentities: []msp.SigningIdentity{orgCtx.SigningIdentity},
+		}
+		if member.Anchors != "" {
+			req.ChannelConfigPath = GetChannelConfigPath(member.Anchors)
+		} else {
+			tx, err := generateAnchorsTx(ch, mspID)
+			if err != nil {
+				return err
+			}
+			if tx == nil {
+				continue
+			}
+			req.ChannelConfig = bytes.NewReader(tx)
+		}
+		if _, err := orgCtx.ResMgmt.SaveChannel(req, resmgmt.WithRetry(retry.DefaultResMgmtOpts), resmgmt.WithOrdererEndpoint(r.m.Orderer.Endpoint)); err != nil {
+			return errors.WithMessage(err, "updating anchor peers of org ["+member.Org+"] failed")
+		}
+		if lastConfigBlock, err = waitForConfigBlock(orgCtx.ResMgmt, ch.Name, r.m.Orderer.Endpoint, false, lastConfigBlock); err != nil {
+			return err
+		}
+		r.changed("updated anchor peers of org %s on channel %s", member.Org, ch.Name)
+	}
+	return nil
+}
+
+// generateAnchorsTx generates the anchor peer update of the org of the channel profile named
+// like the MSP, nil if the profile declares no anchor peers for it
+func generateAnchorsTx(ch ManifestChannel, mspID string) ([]byte, error) {
+	profile, err := configtxgen.LoadProfile(GetConfigtxPath(), ch.Profile)
+	if err != nil {
+		return nil, err
+	}
+	org := profile.ApplicationOrg(mspID)
+	if org == nil || len(org.AnchorPeers) == 0 {
+		return nil, nil
+	}
+	tx, err := configtxgen.AnchorPeersTx(profile, ch.Name, mspID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "generating anchor peer update of org ["+mspID+"] failed")
+	}
+	return tx, nil
+}
+
+func (r *reconciler) reconcileChaincode(cc ManifestChaincode) error {
+	orgs, err := r.orgContexts(cc.Orgs)
+	if err != nil {
+		return err
+	}
+
+	policy := cc.Policy
+	if policy == "" {
+		if policy, err = prepareMembersPolicy(r.sdk, cc.Orgs); err != nil {
+			return err
+		}
+	}
+	var collConfigs []*cb.CollectionConfig
+	if cc.Collections != "" {
+		if collConfigs, err = CollectionConfigsFromFile(cc.Collections); err != nil {
+			return err
+		}
+	}
+
+	ccPkg, err := packager.NewCCPackage(cc.Path, GetDeployPath())
+	if err != nil {
+		return errors.WithMessage(err, "creating chaincode package failed")
+	}
+	for _, orgCtx := range orgs {
+		installed, err := isCCInstalled(orgCtx.ResMgmt, cc.Name, cc.Version, orgCtx.Peers)
+		if err != nil {
+			return err
+		}
+		if installed {
+			continue
+		}
+		if err := InstallChaincode(orgCtx.ResMgmt, ccPkg, cc.Path, cc.Name, cc.Version, orgCtx.Peers); err != nil {
+			return errors.Wrapf(err, "failed to install chaincode to peers in org [%s]", orgCtx.OrgID)
+		}
+		r.changed("installed chaincode %s:%s on peers of org %s", cc.Name, cc.Version, orgCtx.OrgID)
+	}
+
+	resp, err := orgs[0].ResMgmt.QueryInstantiatedChaincodes(cc.Channel, resmgmt.WithRetry(retry.DefaultResMgmtOpts))
+	if err != nil {
+		return errors.WithMessage(err, "Query for instantiated chaincodes failed")
+	}
+	deployedVersion := ""
+	for _, chaincode := range resp.Chaincodes {
+		if chaincode.Name == cc.Name {
+			deployedVersion = chaincode.Version
+		}
+	}
+
+	args := make([][]byte, len(cc.Args))
+	for i, arg := range cc.Args {
+		args[i] = []byte(arg)
+	}
+	switch deployedVersion {
+	case cc.Version:
+		return nil
+	case "":
+		if _, err := InstantiateChaincode(orgs[0].ResMgmt, cc.Channel, cc.Name, cc.Path, cc.Version, policy, args, collConfigs...); err != nil {
+			return errors.WithMessage(err, "instantiating chaincode failed")
+		}
+		r.changed("instantiated chaincode %s:%s on channel %s", cc.Name, cc.Version, cc.Channel)
+	default:
+		if _, err := UpgradeChaincode(orgs[0].ResMgmt, cc.Channel, cc.Name, cc.Path, cc.Version, policy, args, collConfigs...); err != nil {
+			return errors.WithMessage(err, "upgrading chaincode failed")
+		}
+		r.changed("upgraded chaincode %s from %s to %s on channel %s", cc.Name, deployedVersion, cc.Version, cc.Channel)
+	}
+	return nil
+}
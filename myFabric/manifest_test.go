@@ -0,0 +1,107 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadNetworkManifest(t *testing.T) {
+	m, err := LoadNetworkManifest(NetworkManifestPath)
+	if err != nil {
+		t.Fatalf("loading network manifest failed: %s", err)
+	}
+	if m.Orderer != (ManifestOrderer{Org: "OrdererOrg", Endpoint: "orderer.example.com"}) {
+		t.Fatalf("unexpected orderer %+v", m.Orderer)
+	}
+	if org := m.Org("Org1"); org == nil || org.AdminUser != "Admin" || org.ExpectedPeers != 2 {
+		t.Fatalf("unexpected org %+v", org)
+	}
+	if ch := m.Channel("mychannel"); ch == nil || ch.Tx != "mychannel.tx" || !reflect.DeepEqual(ch.memberOrgs(), []string{"Org1"}) {
+		t.Fatalf("unexpected channel %+v", ch)
+	}
+	if len(m.Chaincodes) != 2 {
+		t.Fatalf("expected 2 chaincodes, got %d", len(m.Chaincodes))
+	}
+	// The orgs of a chaincode default to the members of its channel
+	for _, cc := range m.Chaincodes {
+		if cc.Channel != "mychannel" || !reflect.DeepEqual(cc.Orgs, []string{"Org1"}) {
+			t.Errorf("unexpected chaincode %+v", cc)
+		}
+	}
+	if m.Org("Org2") != nil || m.Channel("otherchannel") != nil {
+		t.Fatal("expected undeclared orgs and channels to be nil")
+	}
+}
+
+func TestLoadNetworkManifestDefaults(t *testing.T) {
+	path := writeManifest(t, "orgs: [{name: Org1, expectedPeers: 1}]\nchannels: [{name: ch, profile: OneOrgChannel, members: [{org: Org1}]}]\n")
+	m, err := LoadNetworkManifest(path)
+	if err != nil {
+		t.Fatalf("loading network manifest failed: %s", err)
+	}
+	if m.Orderer.Org != ordererOrgName || m.Orderer.Endpoint != ordererEndpoint {
+		t.Fatalf("expected the default orderer, got %+v", m.Orderer)
+	}
+	if m.Org("Org1").AdminUser != AdminUser {
+		t.Fatalf("expected the default admin user, got %s", m.Org("Org1").AdminUser)
+	}
+}
+
+func TestLoadNetworkManifestErrors(t *testing.T) {
+	const (
+		orgs     = "orgs: [{name: Org1, expectedPeers: 1}, {name: Org2, expectedPeers: 1}]\n"
+		channels = "channels: [{name: ch, tx: ch.tx, members: [{org: Org1}]}]\n"
+	)
+	tests := []struct {
+		name    string
+		yaml    string
+		errText string
+	}{
+		{"unknown field", orgs + "peers: 2\n", "decoding network manifest"},
+		{"unknown org field", "orgs: [{name: Org1, expectedPeers: 1, peers: 2}]\n", "decoding network manifest"},
+		{"missing org name", "orgs: [{expectedPeers: 1}]\n", "org name is required"},
+		{"duplicate org", "orgs: [{name: Org1, expectedPeers: 1}, {name: Org1, expectedPeers: 2}]\n", "duplicate org [Org1]"},
+		{"no expected peers", "orgs: [{name: Org1}]\n", "expectedPeers of org [Org1] must be positive"},
+		{"channel without tx or profile", orgs + "channels: [{name: ch, members: [{org: Org1}]}]\n", "tx or profile are required"},
+		{"duplicate channel", orgs + "channels: [{name: ch, tx: a.tx, members: [{org: Org1}]}, {name: ch, tx: b.tx, members: [{org: Org2}]}]\n", "duplicate channel [ch]"},
+		{"channel without members", orgs + "channels: [{name: ch, tx: ch.tx}]\n", "channel [ch] has no members"},
+		{"unknown org", orgs + "channels: [{name: ch, tx: ch.tx, members: [{org: Org3}]}]\n", "unknown org [Org3] of channel [ch]"},
+		{"chaincode without version", orgs + channels + "chaincodes: [{name: cc, path: p, channel: ch}]\n", "name, path and version are required"},
+		{"chaincode on unknown channel", orgs + channels + "chaincodes: [{name: cc, path: p, version: v0, channel: other}]\n", "unknown channel [other] of chaincode [cc]"},
+		{"duplicate chaincode", orgs + channels + "chaincodes: [{name: cc, path: p, version: v0, channel: ch}, {name: cc, path: p, version: v1, channel: ch}]\n", "duplicate chaincode [cc] on channel [ch]"},
+		{"chaincode org not a member", orgs + channels + "chaincodes: [{name: cc, path: p, version: v0, channel: ch, orgs: [Org2]}]\n", "org [Org2] of chaincode [cc] is not a member of channel [ch]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeManifest(t, tc.yaml)
+			_, err := LoadNetworkManifest(path)
+			if err == nil || !strings.Contains(err.Error(), tc.errText) {
+				t.Fatalf("expected error containing [%s], got %v", tc.errText, err)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Fatalf("expected the error to name the manifest, got %s", err)
+			}
+		})
+	}
+
+	if _, err := LoadNetworkManifest(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected a missing manifest to fail")
+	}
+}
+
+func writeManifest(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "network.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("writing manifest failed: %s", err)
+	}
+	return path
+}
@@ -10,6 +10,12 @@ package main
 // ChannelConfigPath is the relative path to the generated channel artifacts directory
 var ChannelConfigPath = "fixtures/fabric/v1.4/channel"
 
+// ConfigtxPath is the relative path to the configtx.yaml channel artifacts are generated from
+var ConfigtxPath = "fixtures/fabric/v1.4/config/configtx.yaml"
+
+// CryptogenConfigPath is the relative path to the cryptogen.yaml the crypto config is generated from
+var CryptogenConfigPath = "fixtures/fabric/v1/config/cryptogen.yaml"
+
 // CryptoConfigPath is the relative path to the generated crypto config directory
 var CryptoConfigPath = "fixtures/fabric/v1/crypto-config"
 
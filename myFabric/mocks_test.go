@@ -0,0 +1,526 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	reqContext "context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	txnmocks "github.com/hyperledger/fabric-sdk-go/pkg/client/common/mocks"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/options"
+	contextApi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	contextImpl "github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/service/dispatcher"
+	fcmocks "github.com/hyperledger/fabric-sdk-go/pkg/fab/mocks"
+	mspmocks "github.com/hyperledger/fabric-sdk-go/pkg/msp/test/mockmsp"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/ledger/rwset/kvrwset"
+	mspProto "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+const mockChannelID = "mockchannel"
+
+// mockResponse is the answer of a mock peer to a proposal
+type mockResponse struct {
+	Status  int32
+	Message string
+	Payload []byte
+}
+
+// mockPeer is a peer answering every proposal with its handler of the chaincode args.
+// Transactions it endorses are committed as valid by the mock event service.
+type mockPeer struct {
+	*fcmocks.MockPeer
+	handler func(args []string) mockResponse
+	calls   [][]string
+	txArgs  map[string][]string
+}
+
+func newMockPeer(handler func(args []string) mockResponse) *mockPeer {
+	return &mockPeer{MockPeer: fcmocks.NewMockPeer("peer0", "peer0.mock:7051"), handler: handler, txArgs: make(map[string][]string)}
+}
+
+// ProcessTransactionProposal answers the proposal with the response of the handler
+func (p *mockPeer) ProcessTransactionProposal(ctx reqContext.Context, req fab.ProcessProposalRequest) (*fab.TransactionProposalResponse, error) {
+	txID, args, err := proposalArgs(req)
+	if err != nil {
+		return nil, err
+	}
+	res := p.handler(args)
+
+	p.RWLock.Lock()
+	p.calls = append(p.calls, args)
+	p.txArgs[txID] = args
+	p.Status, p.ResponseMessage, p.Payload = res.Status, res.Message, res.Payload
+	p.RWLock.Unlock()
+	if res.Status >= 400 {
+		// A peer returns the error of the chaincode as the SDK peer endorser does
+		err := status.New(status.ChaincodeStatus, res.Status, res.Message, nil)
+		return &fab.TransactionProposalResponse{Endorser: p.MockURL}, errors.Wrapf(err, "Transaction processing for endorser [%s]", p.MockURL)
+	}
+	return p.MockPeer.ProcessTransactionProposal(ctx, req)
+}
+
+// argsOfTx returns the chaincode args of the proposal of a transaction
+func (p *mockPeer) argsOfTx(txID string) []string {
+	p.RWLock.RLock()
+	defer p.RWLock.RUnlock()
+	return p.txArgs[txID]
+}
+
+// proposalArgs returns the transaction ID and the function and args of the chaincode
+// invocation of a proposal
+func proposalArgs(req fab.ProcessProposalRequest) (string, []string, error) {
+	prop := &pb.Proposal{}
+	if err := proto.Unmarshal(req.SignedProposal.ProposalBytes, prop); err != nil {
+		return "", nil, err
+	}
+	header := &common.Header{}
+	if err := proto.Unmarshal(prop.Header, header); err != nil {
+		return "", nil, err
+	}
+	chHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(header.ChannelHeader, chHeader); err != nil {
+		return "", nil, err
+	}
+	payload := &pb.ChaincodeProposalPayload{}
+	if err := proto.Unmarshal(prop.Payload, payload); err != nil {
+		return "", nil, err
+	}
+	spec := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(payload.Input, spec); err != nil {
+		return "", nil, err
+	}
+	var args []string
+	for _, arg := range spec.ChaincodeSpec.Input.Args {
+		args = append(args, string(arg))
+	}
+	return chHeader.TxId, args, nil
+}
+
+// mockChannelService is a channel service whose event service reports the validation
+// code chosen by commit for every transaction. If connect is set, every event service
+// connection delivers the chaincode events sent to the channel connect returns for it.
+type mockChannelService struct {
+	*fcmocks.MockChannelService
+	commit  func(txID string) pb.TxValidationCode
+	connect func(seek *mockSeekParams) (chan *fab.CCEvent, error)
+}
+
+// EventService returns an event service answering with the validation codes of commit
+func (cs *mockChannelService) EventService(opts ...options.Opt) (fab.EventService, error) {
+	es := &mockEventService{MockEventService: fcmocks.NewMockEventService(), commit: cs.commit}
+	if cs.connect != nil {
+		params := &mockSeekParams{}
+		options.Apply(params, opts)
+		ccEvents, err := cs.connect(params)
+		if err != nil {
+			return nil, err
+		}
+		es.ccEvents = ccEvents
+	}
+	return es, nil
+}
+
+// mockSeekParams records where an event service connection seeks to
+type mockSeekParams struct {
+	SeekType  seek.Type
+	FromBlock uint64
+}
+
+func (p *mockSeekParams) SetSeekType(value seek.Type) { p.SeekType = value }
+func (p *mockSeekParams) SetFromBlock(value uint64)   { p.FromBlock = value }
+
+type mockEventService struct {
+	*fcmocks.MockEventService
+	commit   func(txID string) pb.TxValidationCode
+	ccEvents chan *fab.CCEvent
+}
+
+// RegisterChaincodeEvent delivers the chaincode events of the connection, if any
+func (es *mockEventService) RegisterChaincodeEvent(ccID, eventFilter string) (fab.Registration, <-chan *fab.CCEvent, error) {
+	if es.ccEvents == nil {
+		return es.MockEventService.RegisterChaincodeEvent(ccID, eventFilter)
+	}
+	return &dispatcher.ChaincodeReg{ChaincodeID: ccID, EventFilter: eventFilter, Eventch: es.ccEvents}, es.ccEvents, nil
+}
+
+// RegisterTxStatusEvent delivers the status of the transaction right away
+func (es *mockEventService) RegisterTxStatusEvent(txID string) (fab.Registration, <-chan *fab.TxStatusEvent, error) {
+	eventCh := make(chan *fab.TxStatusEvent, 1)
+	eventCh <- &fab.TxStatusEvent{TxID: txID, TxValidationCode: es.commit(txID)}
+	return &dispatcher.TxStatusReg{Eventch: eventCh, TxID: txID}, eventCh, nil
+}
+
+// newMockChannelProvider returns the context of a channel whose only peers are the given
+// ones and whose transactions are all valid
+func newMockChannelProvider(t *testing.T, peers ...fab.Peer) contextApi.ChannelProvider {
+	return newMockChannelProviderWithCommit(t, func(string) pb.TxValidationCode { return pb.TxValidationCode_VALID }, peers...)
+}
+
+// newMockChannelProviderWithCommit is like newMockChannelProvider but commit decides the
+// validation code of every transaction
+func newMockChannelProviderWithCommit(t *testing.T, commit func(txID string) pb.TxValidationCode, peers ...fab.Peer) contextApi.ChannelProvider {
+	return newMockChannelProviderWithService(t, &mockChannelService{commit: commit}, peers...)
+}
+
+// newMockChannelProviderWithEvents is like newMockChannelProvider but every event service
+// connection delivers the chaincode events of the channel connect returns for it
+func newMockChannelProviderWithEvents(t *testing.T, connect func(seek *mockSeekParams) (chan *fab.CCEvent, error)) contextApi.ChannelProvider {
+	return newMockChannelProviderWithService(t, &mockChannelService{
+		commit:  func(string) pb.TxValidationCode { return pb.TxValidationCode_VALID },
+		connect: connect,
+	})
+}
+
+func newMockChannelProviderWithService(t *testing.T, cs *mockChannelService, peers ...fab.Peer) contextApi.ChannelProvider {
+	ctx := fcmocks.NewMockContext(mspmocks.NewMockSigningIdentity("User1", "Org1MSP"))
+	orderers := []fab.Orderer{fcmocks.NewMockOrderer("", nil)}
+
+	chProvider, err := fcmocks.NewMockChannelProvider(ctx)
+	if err != nil {
+		t.Fatalf("mock channel provider creation failed: %s", err)
+	}
+	chService, err := chProvider.ChannelService(ctx, mockChannelID)
+	if err != nil {
+		t.Fatalf("mock channel service creation failed: %s", err)
+	}
+	mockChService := chService.(*fcmocks.MockChannelService)
+	mockChService.SetTransactor(&txnmocks.MockTransactor{Ctx: ctx, ChannelID: mockChannelID, Orderers: orderers})
+	mockChService.SetDiscovery(txnmocks.NewMockDiscoveryService(nil, peers...))
+	mockChService.SetSelection(txnmocks.NewMockSelectionService(nil, peers...))
+	cs.MockChannelService = mockChService
+	ctx.MockProviderContext.ChannelProvider().(*fcmocks.MockChannelProvider).SetCustomChannelService(cs)
+
+	clientProvider := func() (contextApi.Client, error) { return ctx, nil }
+	return func() (contextApi.Channel, error) {
+		return contextImpl.NewChannel(clientProvider, mockChannelID)
+	}
+}
+
+// setMockClients points chClient and org1ChannelClientContext at the given peers until the
+// test ends
+func setMockClients(t *testing.T, peers ...fab.Peer) {
+	savedClient, savedCtx := chClient, org1ChannelClientContext
+	t.Cleanup(func() { chClient, org1ChannelClientContext = savedClient, savedCtx })
+
+	org1ChannelClientContext = newMockChannelProvider(t, peers...)
+	client, err := channel.New(org1ChannelClientContext)
+	if err != nil {
+		t.Fatalf("creating channel client failed: %s", err)
+	}
+	chClient = client
+}
+
+// ccErrorResponse is the response of example CC failing with a JSON error
+func ccErrorResponse(code, key string) mockResponse {
+	msg, _ := json.Marshal(ChaincodeError{Code: code, Message: "mock error", Key: key})
+	return mockResponse{Status: 500, Message: string(msg)}
+}
+
+// ccSuccess is the response of example CC succeeding with the given payload
+func ccSuccess(payload string) mockResponse {
+	return mockResponse{Status: 200, Payload: []byte(payload)}
+}
+
+// fakeExampleCC answers the set, query, delete, move and range calls of example CC from a map.
+// A key of fail makes every call on it return the given response instead.
+type fakeExampleCC struct {
+	mutex sync.Mutex
+	state map[string]string
+	fail  map[string]mockResponse
+}
+
+func newFakeExampleCC(state map[string]string) *fakeExampleCC {
+	return &fakeExampleCC{state: state, fail: make(map[string]mockResponse)}
+}
+
+func (cc *fakeExampleCC) handle(args []string) mockResponse {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	if len(args) < 3 || args[0] != "invoke" {
+		return ccErrorResponse(CCErrUnknownFunction, "")
+	}
+	fcn, key := args[1], args[2]
+	if res, ok := cc.fail[key]; ok {
+		return res
+	}
+	switch {
+	case fcn == "set" && len(args) == 4:
+		cc.state[key] = args[3]
+		return ccSuccess("")
+	case fcn == "query":
+		if v, ok := cc.state[key]; ok {
+			return ccSuccess(v)
+		}
+	case fcn == "delete":
+		if _, ok := cc.state[key]; ok {
+			delete(cc.state, key)
+			return ccSuccess("")
+		}
+	case fcn == "move" && len(args) == 5:
+		return cc.move(key, args[3], args[4])
+	case fcn == "range" && len(args) == 6:
+		return cc.rangePage(args[4], args[5])
+	default:
+		return ccErrorResponse(CCErrUnknownFunction, "")
+	}
+	return ccErrorResponse(CCErrNotFound, key)
+}
+
+func (cc *fakeExampleCC) move(from, to, amount string) mockResponse {
+	if res, ok := cc.fail[to]; ok {
+		return res
+	}
+	a, err := strconv.Atoi(amount)
+	if err != nil {
+		return ccErrorResponse(CCErrInvalidValue, "")
+	}
+	fromVal, ok := cc.state[from]
+	if !ok {
+		return ccErrorResponse(CCErrNotFound, from)
+	}
+	toVal, ok := cc.state[to]
+	if !ok {
+		return ccErrorResponse(CCErrNotFound, to)
+	}
+	f, _ := strconv.Atoi(fromVal)
+	v, _ := strconv.Atoi(toVal)
+	if f < a {
+		return ccErrorResponse(CCErrInsufficient, from)
+	}
+	cc.state[from], cc.state[to] = strconv.Itoa(f-a), strconv.Itoa(v+a)
+	return ccSuccess("")
+}
+
+// rangePage returns a page of all keys starting at the bookmark, the bookmark of the next
+// page is its first key
+func (cc *fakeExampleCC) rangePage(pageSize, bookmark string) mockResponse {
+	size, err := strconv.Atoi(pageSize)
+	if err != nil {
+		return ccErrorResponse(CCErrInvalidArgs, "")
+	}
+	var keys []string
+	for k := range cc.state {
+		if k >= bookmark {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	page := StatePage{Records: []StateRecord{}}
+	for i, k := range keys {
+		if i == size {
+			page.Bookmark = k
+			break
+		}
+		page.Records = append(page.Records, StateRecord{Key: k, Value: cc.state[k]})
+	}
+	page.Count = int32(len(page.Records))
+	payload, _ := json.Marshal(page)
+	return mockResponse{Status: 200, Payload: payload}
+}
+
+func (cc *fakeExampleCC) get(key string) string {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	return cc.state[key]
+}
+
+// mockTx is a transaction of example CC in a mock block
+type mockTx struct {
+	txID   string
+	args   []string
+	writes []*KVWrite
+	code   pb.TxValidationCode
+}
+
+// mockCreator is the serialized identity of User1 of Org1 creating the mock transactions
+func mockCreator(t *testing.T) []byte {
+	cert, err := ioutil.ReadFile("fixtures/fabric/v1/crypto-config/peerOrganizations/org1.example.com/users/User1@org1.example.com/msp/signcerts/User1@org1.example.com-cert.pem")
+	if err != nil {
+		t.Fatalf("reading certificate failed: %s", err)
+	}
+	creator, err := proto.Marshal(&mspProto.SerializedIdentity{Mspid: "Org1MSP", IdBytes: cert})
+	if err != nil {
+		t.Fatalf("encoding creator failed: %s", err)
+	}
+	return creator
+}
+
+// mockTxTimestamp is the timestamp of the transactions of the given mock block
+func mockTxTimestamp(number uint64) time.Time {
+	return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Add(time.Duration(number) * time.Minute)
+}
+
+// newMockBlock builds a block of endorser transactions of example CC, as DecodeBlock reads it
+func newMockBlock(t *testing.T, number uint64, txs ...mockTx) *common.Block {
+	creator := mockCreator(t)
+	block := &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, len(common.BlockMetadataIndex_name))},
+	}
+	var filter []byte
+	for _, tx := range txs {
+		env, err := newMockEnvelope(creator, number, tx)
+		if err != nil {
+			t.Fatalf("building transaction [%s] failed: %s", tx.txID, err)
+		}
+		data, err := proto.Marshal(env)
+		if err != nil {
+			t.Fatalf("encoding transaction [%s] failed: %s", tx.txID, err)
+		}
+		block.Data.Data = append(block.Data.Data, data)
+		filter = append(filter, byte(tx.code))
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+	return block
+}
+
+func newMockEnvelope(creator []byte, number uint64, tx mockTx) (*common.Envelope, error) {
+	ts, err := ptypes.TimestampProto(mockTxTimestamp(number))
+	if err != nil {
+		return nil, err
+	}
+	chHeader, err := proto.Marshal(&common.ChannelHeader{Type: int32(common.HeaderType_ENDORSER_TRANSACTION), ChannelId: mockChannelID, TxId: tx.txID, Timestamp: ts})
+	if err != nil {
+		return nil, err
+	}
+	sigHeader, err := proto.Marshal(&common.SignatureHeader{Creator: creator})
+	if err != nil {
+		return nil, err
+	}
+
+	var args [][]byte
+	for _, arg := range tx.args {
+		args = append(args, []byte(arg))
+	}
+	input, err := proto.Marshal(&pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{ChaincodeId: &pb.ChaincodeID{Name: "examplecc"}, Input: &pb.ChaincodeInput{Args: args}}})
+	if err != nil {
+		return nil, err
+	}
+	proposalPayload, err := proto.Marshal(&pb.ChaincodeProposalPayload{Input: input})
+	if err != nil {
+		return nil, err
+	}
+
+	kvRwSet := &kvrwset.KVRWSet{}
+	for _, w := range tx.writes {
+		kvRwSet.Writes = append(kvRwSet.Writes, &kvrwset.KVWrite{Key: w.Key, Value: []byte(w.Value), IsDelete: w.IsDelete})
+	}
+	results, err := (&rwsetutil.TxRwSet{NsRwSets: []*rwsetutil.NsRwSet{{NameSpace: "examplecc", KvRwSet: kvRwSet}}}).ToProtoBytes()
+	if err != nil {
+		return nil, err
+	}
+	ccAction, err := proto.Marshal(&pb.ChaincodeAction{Results: results, Response: &pb.Response{Status: 200}, ChaincodeId: &pb.ChaincodeID{Name: "examplecc", Version: "v0"}})
+	if err != nil {
+		return nil, err
+	}
+	responsePayload, err := proto.Marshal(&pb.ProposalResponsePayload{Extension: ccAction})
+	if err != nil {
+		return nil, err
+	}
+	actionPayload, err := proto.Marshal(&pb.ChaincodeActionPayload{
+		ChaincodeProposalPayload: proposalPayload,
+		Action:                   &pb.ChaincodeEndorsedAction{ProposalResponsePayload: responsePayload},
+	})
+	if err != nil {
+		return nil, err
+	}
+	transaction, err := proto.Marshal(&pb.Transaction{Actions: []*pb.TransactionAction{{Header: sigHeader, Payload: actionPayload}}})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(&common.Payload{Header: &common.Header{ChannelHeader: chHeader, SignatureHeader: sigHeader}, Data: transaction})
+	if err != nil {
+		return nil, err
+	}
+	return &common.Envelope{Payload: payload}, nil
+}
+
+// mockLedger answers the chain info, block and transaction queries of qscc from its blocks
+type mockLedger struct {
+	mutex  sync.Mutex
+	blocks []*common.Block
+}
+
+func (l *mockLedger) add(blocks ...*common.Block) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.blocks = append(l.blocks, blocks...)
+}
+
+func (l *mockLedger) handle(args []string) mockResponse {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	switch {
+	case args[0] == "GetChainInfo":
+		info, _ := proto.Marshal(&common.BlockchainInfo{Height: uint64(len(l.blocks))})
+		return mockResponse{Status: 200, Payload: info}
+	case args[0] == "GetBlockByNumber" && len(args) == 3:
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n >= len(l.blocks) {
+			return mockResponse{Status: 500, Message: "Failed to get block number " + args[2]}
+		}
+		block, _ := proto.Marshal(l.blocks[n])
+		return mockResponse{Status: 200, Payload: block}
+	case (args[0] == "GetTransactionByID" || args[0] == "GetBlockByTxID") && len(args) == 3:
+		block, i := l.findTx(args[2])
+		if block == nil {
+			return mockResponse{Status: 500, Message: "Failed to get transaction with id " + args[2]}
+		}
+		if args[0] == "GetBlockByTxID" {
+			payload, _ := proto.Marshal(block)
+			return mockResponse{Status: 200, Payload: payload}
+		}
+		env := &common.Envelope{}
+		if err := proto.Unmarshal(block.Data.Data[i], env); err != nil {
+			return mockResponse{Status: 500, Message: err.Error()}
+		}
+		code := block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER][i]
+		payload, _ := proto.Marshal(&pb.ProcessedTransaction{TransactionEnvelope: env, ValidationCode: int32(code)})
+		return mockResponse{Status: 200, Payload: payload}
+	}
+	return mockResponse{Status: 500, Message: "unknown function " + args[0]}
+}
+
+// findTx returns the block holding a transaction and its index in the block
+func (l *mockLedger) findTx(txID string) (*common.Block, int) {
+	for _, block := range l.blocks {
+		for i, data := range block.Data.Data {
+			env := &common.Envelope{}
+			payload := &common.Payload{}
+			chHeader := &common.ChannelHeader{}
+			if proto.Unmarshal(data, env) != nil || proto.Unmarshal(env.Payload, payload) != nil ||
+				proto.Unmarshal(payload.Header.ChannelHeader, chHeader) != nil {
+				continue
+			}
+			if chHeader.TxId == txID {
+				return block, i
+			}
+		}
+	}
+	return nil, 0
+}
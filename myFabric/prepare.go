@@ -6,7 +6,9 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
@@ -33,7 +35,7 @@ const (
 	exampleCCPath       = "myFabric/chaincode"
 	exampleCCVersion    = "v0"
 	examplePvtCCName    = "example_pvt_cc"
-	examplePvtCCPath    = "github.com/example_pvt_cc"
+	examplePvtCCPath    = "myFabric/pvtchaincode"
 	examplePvtCCVersion = "v0"
 	exampleUpgdPvtCCVer = "v1"
 )
@@ -58,8 +60,9 @@ func GenerateExampleID(randomize bool) string {
 	return fmt.Sprintf("%s_0%s%s", exampleCCName, TestRunID, suffix)
 }
 
-// PrepareExampleCC install and instantiate using resource management client
-func PrepareExampleCC(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgName string, chaincodeID string) error {
+// PrepareExampleCC install and instantiate using resource management client. The keys set by
+// the instantiation are handed over to clientUser, which resets them if cc is already instantiated.
+func PrepareExampleCC(sdk *fabsdk.FabricSDK, user, clientUser fabsdk.ContextOption, orgName string, chaincodeID string) error {
 	const (
 		channelID = defaultChannelID
 	)
@@ -70,7 +73,10 @@ func PrepareExampleCC(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgName
 	}
 
 	if instantiated {
-		resetErr := resetExampleCC(sdk, user, orgName, channelID, chaincodeID, resetArgs)
+		if err := handOverExampleKeys(sdk, user, clientUser, orgName, channelID, chaincodeID); err != nil {
+			return err
+		}
+		resetErr := resetExampleCC(sdk, clientUser, orgName, channelID, chaincodeID, resetArgs)
 		if resetErr != nil {
 			return errors.WithMessage(resetErr, "Resetting example chaincode failed")
 		}
@@ -99,6 +105,103 @@ func PrepareExampleCC(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgName
 	if err != nil {
 		return errors.WithMessage(err, "Instantiating example chaincode failed")
 	}
+	if err := handOverExampleKeys(sdk, user, clientUser, orgName, channelID, chaincodeID); err != nil {
+		return err
+	}
+
+	t := time.Now()
+	elapsed := t.Sub(start)
+	fmt.Printf("Done [%d ms]\n", elapsed/time.Millisecond)
+
+	return nil
+}
+
+// PrepareMultiOrgExampleCC installs example CC on the peers of all given orgs and instantiates it
+// on the channel with a policy requiring an endorsement from a member of each org. The keys set
+// by the instantiation are handed over to clientUser of the first org, which resets them if cc
+// is already instantiated.
+func PrepareMultiOrgExampleCC(sdk *fabsdk.FabricSDK, orgs []*OrgContext, clientUser fabsdk.ContextOption, channelID string, chaincodeID string) error {
+	instantiated, err := queryInstantiatedCC(orgs[0].ResMgmt, orgs[0].OrgID, channelID, chaincodeID, exampleCCVersion, false)
+	if err != nil {
+		return errors.WithMessage(err, "Querying for instantiated status failed")
+	}
+
+	admin := fabsdk.WithUser(AdminUser)
+	if instantiated {
+		if err := handOverExampleKeys(sdk, admin, clientUser, orgs[0].OrgID, channelID, chaincodeID); err != nil {
+			return err
+		}
+		resetErr := resetExampleCC(sdk, clientUser, orgs[0].OrgID, channelID, chaincodeID, resetArgs)
+		if resetErr != nil {
+			return errors.WithMessage(resetErr, "Resetting example chaincode failed")
+		}
+		return nil
+	}
+
+	fmt.Printf("Installing and instantiating example chaincode on %d orgs...", len(orgs))
+	start := time.Now()
+
+	orgNames := make([]string, len(orgs))
+	for i, orgCtx := range orgs {
+		orgNames[i] = orgCtx.OrgID
+	}
+	ccPolicy, err := prepareMembersPolicy(sdk, orgNames)
+	if err != nil {
+		return errors.WithMessage(err, "CC policy could not be prepared")
+	}
+
+	err = InstallExampleChaincode(orgs, chaincodeID)
+	if err != nil {
+		return errors.WithMessage(err, "Installing example chaincode failed")
+	}
+
+	err = InstantiateExampleChaincode(orgs, channelID, chaincodeID, ccPolicy)
+	if err != nil {
+		return errors.WithMessage(err, "Instantiating example chaincode failed")
+	}
+	if err := handOverExampleKeys(sdk, admin, clientUser, orgs[0].OrgID, channelID, chaincodeID); err != nil {
+		return err
+	}
+
+	t := time.Now()
+	elapsed := t.Sub(start)
+	fmt.Printf("Done [%d ms]\n", elapsed/time.Millisecond)
+
+	return nil
+}
+
+// PrepareExamplePvtCC install and instantiate example pvt CC with the given private data collections
+func PrepareExamplePvtCC(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgName string, channelID string, chaincodeID string, collConfigs ...*cb.CollectionConfig) error {
+	instantiated, err := queryInstantiatedCCWithSDK(sdk, user, orgName, channelID, chaincodeID, examplePvtCCVersion, false)
+	if err != nil {
+		return errors.WithMessage(err, "Querying for instantiated status failed")
+	}
+	if instantiated {
+		return nil
+	}
+
+	fmt.Printf("Installing and instantiating example pvt chaincode...")
+	start := time.Now()
+
+	ccPolicy, err := prepareOneOrgPolicy(sdk, orgName)
+	if err != nil {
+		return errors.WithMessage(err, "CC policy could not be prepared")
+	}
+
+	orgContexts, err := prepareOrgContexts(sdk, user, []string{orgName})
+	if err != nil {
+		return errors.WithMessage(err, "Org contexts could not be prepared")
+	}
+
+	err = InstallExamplePvtChaincode(orgContexts, chaincodeID)
+	if err != nil {
+		return errors.WithMessage(err, "Installing example pvt chaincode failed")
+	}
+
+	err = InstantiateExamplePvtChaincode(orgContexts, channelID, chaincodeID, ccPolicy, collConfigs...)
+	if err != nil {
+		return errors.WithMessage(err, "Instantiating example pvt chaincode failed")
+	}
 
 	t := time.Now()
 	elapsed := t.Sub(start)
@@ -166,6 +269,51 @@ func UpgradeExamplePvtChaincode(orgs []*OrgContext, channelID, ccID, ccPolicy st
 	return err
 }
 
+// handOverExampleKeys hands the keys of resetArgs that admin owns over to client, so that client
+// may use the keys set by the instantiation of admin
+func handOverExampleKeys(sdk *fabsdk.FabricSDK, admin, client fabsdk.ContextOption, orgName string, channelID string, chaincodeID string) error {
+	adminCtx, err := sdk.Context(admin, fabsdk.WithOrg(orgName))()
+	if err != nil {
+		return errors.WithMessage(err, "Creating admin context failed")
+	}
+	adminOwner, err := KeyOwnerOf(adminCtx)
+	if err != nil {
+		return errors.WithMessage(err, "Getting admin key owner failed")
+	}
+	clientCtx, err := sdk.Context(client, fabsdk.WithOrg(orgName))()
+	if err != nil {
+		return errors.WithMessage(err, "Creating client context failed")
+	}
+	clientOwner, err := KeyOwnerOf(clientCtx)
+	if err != nil {
+		return errors.WithMessage(err, "Getting client key owner failed")
+	}
+	if *adminOwner == *clientOwner {
+		return nil
+	}
+
+	chClient, err := channel.New(sdk.ChannelContext(channelID, admin, fabsdk.WithOrg(orgName)))
+	if err != nil {
+		return errors.WithMessage(err, "Creating channel client failed")
+	}
+	for _, key := range []string{string(resetArgs[0]), string(resetArgs[2])} {
+		owner, err := GetKeyOwnerWithContext(context.Background(), chClient, chaincodeID, key, DefaultRetryPolicy)
+		if err != nil {
+			if LedgerErrorKindOf(err) == ErrNotFound {
+				continue
+			}
+			return errors.WithMessage(err, "Querying owner of key ["+key+"] failed")
+		}
+		if *owner != *adminOwner {
+			continue
+		}
+		if _, err := ChangeKeyOwnerWithContext(context.Background(), chClient, chaincodeID, key, *clientOwner, DefaultRetryPolicy); err != nil {
+			return errors.WithMessage(err, "Handing over key ["+key+"] failed")
+		}
+	}
+	return nil
+}
+
 func resetExampleCC(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgName string, channelID string, chainCodeID string, args [][]byte) error {
 	clientContext := sdk.ChannelContext(channelID, user, fabsdk.WithOrg(orgName))
 
@@ -192,33 +340,42 @@ func prepareOrgContexts(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgNam
 	orgContexts := make([]*OrgContext, len(orgNames))
 
 	for i, orgName := range orgNames {
-		clientContext := sdk.Context(user, fabsdk.WithOrg(orgName))
-
-		resMgmt, err := resmgmt.New(clientContext)
-		if err != nil {
-			return nil, errors.WithMessage(err, "Creating resource management client failed")
-		}
-
 		expectedPeers, ok := orgExpectedPeers[orgName]
 		if !ok {
-			return nil, errors.WithMessage(err, "unknown org name")
+			return nil, errors.Errorf("unknown org name [%s]", orgName)
 		}
-		peers, err := DiscoverLocalPeers(clientContext, expectedPeers)
+		orgCtx, err := newOrgContext(sdk, user, orgName, expectedPeers)
 		if err != nil {
-			return nil, errors.WithMessage(err, "local peers could not be determined")
-		}
-
-		orgCtx := OrgContext{
-			OrgID:       orgName,
-			CtxProvider: clientContext,
-			ResMgmt:     resMgmt,
-			Peers:       peers,
+			return nil, err
 		}
-		orgContexts[i] = &orgCtx
+		orgContexts[i] = orgCtx
 	}
 	return orgContexts, nil
 }
 
+// newOrgContext creates the resource management client of the org and waits until the
+// expected number of local peers has been discovered
+func newOrgContext(sdk *fabsdk.FabricSDK, user fabsdk.ContextOption, orgName string, expectedPeers int) (*OrgContext, error) {
+	clientContext := sdk.Context(user, fabsdk.WithOrg(orgName))
+
+	resMgmt, err := resmgmt.New(clientContext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Creating resource management client failed")
+	}
+
+	peers, err := DiscoverLocalPeers(clientContext, expectedPeers)
+	if err != nil {
+		return nil, errors.WithMessage(err, "local peers could not be determined")
+	}
+
+	return &OrgContext{
+		OrgID:       orgName,
+		CtxProvider: clientContext,
+		ResMgmt:     resMgmt,
+		Peers:       peers,
+	}, nil
+}
+
 func prepareOneOrgPolicy(sdk *fabsdk.FabricSDK, orgName string) (string, error) {
 	mspID, err := orgMSPID(sdk, orgName)
 	if err != nil {
@@ -228,6 +385,19 @@ func prepareOneOrgPolicy(sdk *fabsdk.FabricSDK, orgName string) (string, error)
 	return fmt.Sprintf("AND('%s.member')", mspID), nil
 }
 
+// prepareMembersPolicy returns a policy that requires a member of each of the orgs
+func prepareMembersPolicy(sdk *fabsdk.FabricSDK, orgNames []string) (string, error) {
+	principals := make([]string, len(orgNames))
+	for i, orgName := range orgNames {
+		mspID, err := orgMSPID(sdk, orgName)
+		if err != nil {
+			return "", errors.WithMessage(err, "MSP ID of org ["+orgName+"] could not be determined")
+		}
+		principals[i] = fmt.Sprintf("'%s.member'", mspID)
+	}
+	return "AND(" + strings.Join(principals, ",") + ")", nil
+}
+
 func orgMSPID(sdk *fabsdk.FabricSDK, orgName string) (string, error) {
 	configBackend, err := sdk.Config()
 	if err != nil {
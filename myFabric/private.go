@@ -0,0 +1,112 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// examplePvtCCValueKey is the transient map field carrying private values to example pvt CC.
+// It is the transientValueKey of the chaincode, a test keeps both equal.
+const examplePvtCCValueKey = "value"
+
+// PrivateDataVerification is the result of checking a value against the hash of a private key
+type PrivateDataVerification struct {
+	Collection string `json:"collection"`
+	Key        string `json:"key"`
+	Hash       string `json:"hash"`
+	Match      bool   `json:"match"`
+}
+
+// ExamplePvtCCArgs returns example pvt cc args naming a key of a collection
+func ExamplePvtCCArgs(collection, key string) [][]byte {
+	return [][]byte{[]byte(collection), []byte(key)}
+}
+
+// pvtRequest returns a request of example pvt CC, the collection is added to the invocation
+// chain so that only peers of member orgs are selected as endorsers
+func pvtRequest(ccID, fcn, collection, key string, value []byte) channel.Request {
+	req := channel.Request{
+		ChaincodeID:     ccID,
+		Fcn:             fcn,
+		Args:            ExamplePvtCCArgs(collection, key),
+		InvocationChain: []*fabAPI.ChaincodeCall{{ID: ccID, Collections: []string{collection}}},
+	}
+	if value != nil {
+		req.TransientMap = map[string][]byte{examplePvtCCValueKey: value}
+	}
+	return req
+}
+
+// PutPrivateDataWithContext writes the value of a key of a collection. The value is passed in
+// the transient map so that only its hash is written to the channel ledger.
+// On failure a *LedgerError is returned.
+func PutPrivateDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID, collection, key string, value []byte, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		pvtRequest(ccID, "putprivate", collection, key, value),
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "putprivate", key, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// GetPrivateDataWithContext queries the value of a key of a collection, the client must be a
+// member of the collection. On failure a *LedgerError is returned.
+func GetPrivateDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID, collection, key string, policy RetryPolicy) ([]byte, error) {
+	response, err := chClient.Query(
+		pvtRequest(ccID, "getprivate", collection, key, nil),
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "getprivate", key, err)
+	}
+
+	return response.Payload, nil
+}
+
+// DeletePrivateDataWithContext deletes a key of a collection. On failure a *LedgerError is returned.
+func DeletePrivateDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID, collection, key string, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		pvtRequest(ccID, "delprivate", collection, key, nil),
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "delprivate", key, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// VerifyPrivateDataWithContext checks a value against the hash of a key of a collection.
+// The hash is kept by all peers of the channel, so clients of orgs outside the collection can
+// check values shared with them off chain. On failure a *LedgerError is returned.
+func VerifyPrivateDataWithContext(reqCtx context.Context, chClient *channel.Client, ccID, collection, key string, value []byte, policy RetryPolicy) (*PrivateDataVerification, error) {
+	req := pvtRequest(ccID, "verifyprivate", collection, key, value)
+	// Any peer holds the hash, membership of the collection is not needed
+	req.InvocationChain = nil
+
+	response, err := chClient.Query(req,
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "verifyprivate", key, err)
+	}
+
+	verification := &PrivateDataVerification{}
+	if err := json.Unmarshal(response.Payload, verification); err != nil {
+		return nil, errors.Wrap(err, "decoding verification failed")
+	}
+	return verification, nil
+}
@@ -0,0 +1,239 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+var logger = shim.NewLogger("examplepvtcc")
+
+// transientValueKey is the field of the transient map holding private values, so that they
+// are never written to the proposal args or the ledger. Clients send it as examplePvtCCValueKey.
+const transientValueKey = "value"
+
+// Error codes of the JSON errors returned by example pvt CC, they match those of example CC
+const (
+	errCodeInvalidArgs     = "INVALID_ARGUMENTS"
+	errCodeInvalidValue    = "INVALID_VALUE"
+	errCodeNotFound        = "NOT_FOUND"
+	errCodeStateFailure    = "STATE_FAILURE"
+	errCodeEventFailure    = "EVENT_FAILURE"
+	errCodeUnknownFunction = "UNKNOWN_FUNCTION"
+)
+
+// ccError is returned as JSON in the message of a failed response
+type ccError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Key     string `json:"key,omitempty"`
+}
+
+// Error returns the JSON encoding of the error
+func (e *ccError) Error() string {
+	errJSON, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(errJSON)
+}
+
+func newCCError(code, key, format string, args ...interface{}) *ccError {
+	return &ccError{Code: code, Message: fmt.Sprintf(format, args...), Key: key}
+}
+
+// errorResponse returns a failed response carrying a JSON error
+func errorResponse(code, key, format string, args ...interface{}) pb.Response {
+	return shim.Error(newCCError(code, key, format, args...).Error())
+}
+
+// hashVerification is the response of verifyprivate
+type hashVerification struct {
+	Collection string `json:"collection"`
+	Key        string `json:"key"`
+	Hash       string `json:"hash"`
+	Match      bool   `json:"match"`
+}
+
+// PvtChaincode keeps values in private data collections
+type PvtChaincode struct {
+}
+
+// Init sets two public keys to the given holdings, it takes the same args as example CC
+// so that both are instantiated alike
+func (t *PvtChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	txID := stub.GetTxID()
+	logger.Debugf("[txID %s] ########### example_pvt_cc Init ###########\n", txID)
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 4 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting 4")
+	}
+	for i := 0; i < len(args); i += 2 {
+		if args[i] == "" {
+			return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty key")
+		}
+		if _, err := strconv.Atoi(args[i+1]); err != nil {
+			return errorResponse(errCodeInvalidValue, args[i], "Expecting integer value for asset holding")
+		}
+		if err := stub.PutState(args[i], []byte(args[i+1])); err != nil {
+			return errorResponse(errCodeStateFailure, args[i], "Failed to put state: %s", err)
+		}
+	}
+	return shim.Success(nil)
+}
+
+// Invoke dispatches the private data functions, each takes a collection and a key
+func (t *PvtChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	logger.Debugf("[txID %s] ########### example_pvt_cc Invoke ###########\n", stub.GetTxID())
+	function, args := stub.GetFunctionAndParameters()
+
+	if len(args) < 2 {
+		return errorResponse(errCodeInvalidArgs, "", "Incorrect number of arguments. Expecting a collection and a key")
+	}
+	if args[0] == "" || args[1] == "" {
+		return errorResponse(errCodeInvalidArgs, "", "Expecting a non empty collection and key")
+	}
+
+	switch function {
+	case "putprivate":
+		return t.putPrivate(stub, args)
+	case "getprivate":
+		return t.getPrivate(stub, args)
+	case "delprivate":
+		return t.delPrivate(stub, args)
+	case "getprivatehash":
+		return t.getPrivateHash(stub, args)
+	case "verifyprivate":
+		return t.verifyPrivate(stub, args)
+	}
+	return errorResponse(errCodeUnknownFunction, "", "Unknown function call: %s, must be one of 'putprivate', 'getprivate', 'delprivate', 'getprivatehash' or 'verifyprivate'", function)
+}
+
+// transientValue returns the private value passed in the transient map
+func transientValue(stub shim.ChaincodeStubInterface, key string) ([]byte, *ccError) {
+	transientMap, err := stub.GetTransient()
+	if err != nil {
+		return nil, newCCError(errCodeInvalidArgs, key, "Failed to get transient map: %s", err)
+	}
+	value, ok := transientMap[transientValueKey]
+	if !ok {
+		return nil, newCCError(errCodeInvalidArgs, key, "Expecting the value in the transient map field '%s'", transientValueKey)
+	}
+	return value, nil
+}
+
+// putPrivate writes the value of the transient map to a collection
+// arg0: collection, arg1: key, arg2: optional event ID
+func (t *PvtChaincode) putPrivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	collection, key := args[0], args[1]
+	value, ccErr := transientValue(stub, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	if err := stub.PutPrivateData(collection, key, value); err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to put private data in collection %s: %s", collection, err)
+	}
+
+	if len(args) >= 3 && args[2] != "" {
+		// The event only names the key, the value stays private
+		if err := stub.SetEvent(args[2], []byte(collection+"/"+key)); err != nil {
+			return errorResponse(errCodeEventFailure, key, "Failed to set event: %s", err)
+		}
+	}
+	return shim.Success(nil)
+}
+
+// getPrivate returns the value of a key of a collection, only members of the collection can read it
+// arg0: collection, arg1: key
+func (t *PvtChaincode) getPrivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	collection, key := args[0], args[1]
+	value, err := stub.GetPrivateData(collection, key)
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to get private data from collection %s: %s", collection, err)
+	}
+	if value == nil {
+		return errorResponse(errCodeNotFound, key, "Entity not found")
+	}
+	return shim.Success(value)
+}
+
+// delPrivate deletes a key of a collection
+// arg0: collection, arg1: key
+func (t *PvtChaincode) delPrivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	collection, key := args[0], args[1]
+	if err := stub.DelPrivateData(collection, key); err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to delete private data from collection %s: %s", collection, err)
+	}
+	return shim.Success(nil)
+}
+
+// getPrivateHash returns the hex encoded hash of the value of a key, it is available on
+// all peers of the channel, members of the collection or not
+// arg0: collection, arg1: key
+func (t *PvtChaincode) getPrivateHash(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	collection, key := args[0], args[1]
+	hash, ccErr := privateDataHash(stub, collection, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	return shim.Success([]byte(hex.EncodeToString(hash)))
+}
+
+// verifyPrivate checks that the value passed in the transient map matches the hash of the value
+// of a key, so that a value shared off chain can be checked by non members of the collection
+// arg0: collection, arg1: key
+func (t *PvtChaincode) verifyPrivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	collection, key := args[0], args[1]
+	value, ccErr := transientValue(stub, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+	hash, ccErr := privateDataHash(stub, collection, key)
+	if ccErr != nil {
+		return shim.Error(ccErr.Error())
+	}
+
+	valueHash := sha256.Sum256(value)
+	verificationJSON, err := json.Marshal(&hashVerification{
+		Collection: collection,
+		Key:        key,
+		Hash:       hex.EncodeToString(hash),
+		Match:      bytes.Equal(valueHash[:], hash),
+	})
+	if err != nil {
+		return errorResponse(errCodeStateFailure, key, "Failed to marshal verification: %s", err)
+	}
+	return shim.Success(verificationJSON)
+}
+
+func privateDataHash(stub shim.ChaincodeStubInterface, collection, key string) ([]byte, *ccError) {
+	hash, err := stub.GetPrivateDataHash(collection, key)
+	if err != nil {
+		return nil, newCCError(errCodeStateFailure, key, "Failed to get private data hash from collection %s: %s", collection, err)
+	}
+	if hash == nil {
+		return nil, newCCError(errCodeNotFound, key, "Entity not found")
+	}
+	return hash, nil
+}
+
+func main() {
+	err := shim.Start(new(PvtChaincode))
+	if err != nil {
+		logger.Errorf("Error starting Pvt chaincode: %s", err)
+	}
+}
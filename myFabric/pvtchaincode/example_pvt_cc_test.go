@@ -0,0 +1,268 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// testStub is a mock stub of example pvt CC. The mock stub of the shim neither hashes nor
+// deletes private data, testStub does and so invokes the chaincode itself.
+type testStub struct {
+	*shim.MockStub
+	args    [][]byte
+	txCount int
+}
+
+// newTestStub returns a mock stub of example pvt CC instantiated with a=100 and b=200
+func newTestStub(t *testing.T) *testStub {
+	stub := &testStub{MockStub: shim.NewMockStub("example_pvt_cc", new(PvtChaincode))}
+	if res := stub.MockInit("init", toBytes("init", "a", "100", "b", "200")); res.Status != shim.OK {
+		t.Fatalf("init failed: %s", res.Message)
+	}
+	return stub
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *testStub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+func (s *testStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value, err := s.GetPrivateData(collection, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (s *testStub) DelPrivateData(collection, key string) error {
+	delete(s.PvtState[collection], key)
+	return nil
+}
+
+// invoke calls the chaincode with the given transient value, none if nil
+func (s *testStub) invoke(value []byte, args ...string) pb.Response {
+	s.txCount++
+	txID := fmt.Sprintf("tx%d", s.txCount)
+
+	s.args = toBytes(args...)
+	s.TransientMap = nil
+	if value != nil {
+		s.TransientMap = map[string][]byte{transientValueKey: value}
+	}
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+	return new(PvtChaincode).Invoke(s)
+}
+
+func (s *testStub) private(collection, key string) string {
+	return string(s.PvtState[collection][key])
+}
+
+// lastEvent returns the last event emitted since the previous call, or nil
+func (s *testStub) lastEvent() *pb.ChaincodeEvent {
+	var last *pb.ChaincodeEvent
+	for {
+		select {
+		case ev := <-s.ChaincodeEventsChannel:
+			last = ev
+		default:
+			return last
+		}
+	}
+}
+
+func toBytes(args ...string) [][]byte {
+	bytes := make([][]byte, len(args))
+	for i, arg := range args {
+		bytes[i] = []byte(arg)
+	}
+	return bytes
+}
+
+// errorCode returns the code of the JSON error of a failed response
+func errorCode(t *testing.T, res pb.Response) string {
+	if res.Status == shim.OK {
+		return ""
+	}
+	ccErr := &ccError{}
+	if err := json.Unmarshal([]byte(res.Message), ccErr); err != nil {
+		t.Fatalf("response message is not a JSON error: %s", res.Message)
+	}
+	return ccErr.Code
+}
+
+func hashOf(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode string
+	}{
+		{name: "valid", args: []string{"init", "a", "100", "b", "200"}},
+		{name: "missing args", args: []string{"init", "a", "100"}, wantCode: errCodeInvalidArgs},
+		{name: "empty key", args: []string{"init", "a", "100", "", "200"}, wantCode: errCodeInvalidArgs},
+		{name: "non integer value", args: []string{"init", "a", "100", "b", "x"}, wantCode: errCodeInvalidValue},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := shim.NewMockStub("example_pvt_cc", new(PvtChaincode))
+			res := stub.MockInit("init", toBytes(tc.args...))
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantCode == "" && (string(stub.State["a"]) != "100" || string(stub.State["b"]) != "200") {
+				t.Fatalf("unexpected state a=%s b=%s", stub.State["a"], stub.State["b"])
+			}
+		})
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		args        []string
+		wantCode    string
+		wantPayload string
+		wantPrivate map[string]string
+		wantEvent   string
+	}{
+		{name: "unknown function", args: []string{"foo", "collection1", "x"}, wantCode: errCodeUnknownFunction},
+		{name: "missing key", args: []string{"getprivate", "collection1"}, wantCode: errCodeInvalidArgs},
+		{name: "empty collection", args: []string{"getprivate", "", "x"}, wantCode: errCodeInvalidArgs},
+		{name: "empty key", args: []string{"getprivate", "collection1", ""}, wantCode: errCodeInvalidArgs},
+
+		{name: "putprivate", value: "secret", args: []string{"putprivate", "collection1", "y"}, wantPrivate: map[string]string{"y": "secret"}},
+		{name: "putprivate overwrites", value: "new", args: []string{"putprivate", "collection1", "x"}, wantPrivate: map[string]string{"x": "new"}},
+		{name: "putprivate with event", value: "secret", args: []string{"putprivate", "collection1", "y", "pvtEvent"}, wantEvent: "pvtEvent"},
+		{name: "putprivate without transient value", args: []string{"putprivate", "collection1", "y"}, wantCode: errCodeInvalidArgs},
+
+		{name: "getprivate", args: []string{"getprivate", "collection1", "x"}, wantPayload: "hidden"},
+		{name: "getprivate of other collection", args: []string{"getprivate", "collection2", "x"}, wantCode: errCodeNotFound},
+		{name: "getprivate missing key", args: []string{"getprivate", "collection1", "y"}, wantCode: errCodeNotFound},
+
+		{name: "delprivate", args: []string{"delprivate", "collection1", "x"}, wantPrivate: map[string]string{"x": ""}},
+
+		{name: "getprivatehash", args: []string{"getprivatehash", "collection1", "x"}, wantPayload: hashOf("hidden")},
+		{name: "getprivatehash missing key", args: []string{"getprivatehash", "collection1", "y"}, wantCode: errCodeNotFound},
+
+		{name: "verifyprivate without transient value", args: []string{"verifyprivate", "collection1", "x"}, wantCode: errCodeInvalidArgs},
+		{name: "verifyprivate missing key", value: "hidden", args: []string{"verifyprivate", "collection1", "y"}, wantCode: errCodeNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newTestStub(t)
+			if res := stub.invoke([]byte("hidden"), "putprivate", "collection1", "x"); res.Status != shim.OK {
+				t.Fatalf("putprivate failed: %s", res.Message)
+			}
+
+			var value []byte
+			if tc.value != "" {
+				value = []byte(tc.value)
+			}
+			res := stub.invoke(value, tc.args...)
+			if code := errorCode(t, res); code != tc.wantCode {
+				t.Fatalf("expected code [%s], got [%s]: %s", tc.wantCode, code, res.Message)
+			}
+			if tc.wantPayload != "" && string(res.Payload) != tc.wantPayload {
+				t.Fatalf("expected payload [%s], got [%s]", tc.wantPayload, res.Payload)
+			}
+			for key, v := range tc.wantPrivate {
+				if got := stub.private("collection1", key); got != v {
+					t.Fatalf("expected private %s=[%s], got [%s]", key, v, got)
+				}
+			}
+			if tc.wantEvent != "" {
+				ev := stub.lastEvent()
+				if ev == nil || ev.EventName != tc.wantEvent || string(ev.Payload) != "collection1/y" {
+					t.Fatalf("expected event [%s] naming the key, got %v", tc.wantEvent, ev)
+				}
+			}
+		})
+	}
+}
+
+func TestPrivateValueStaysPrivate(t *testing.T) {
+	stub := newTestStub(t)
+	if res := stub.invoke([]byte("secret"), "putprivate", "collection1", "x", "pvtEvent"); res.Status != shim.OK {
+		t.Fatalf("putprivate failed: %s", res.Message)
+	}
+
+	if _, ok := stub.State["x"]; ok {
+		t.Fatal("expected the private value not to be written to the public state")
+	}
+	if ev := stub.lastEvent(); ev == nil || string(ev.Payload) == "secret" {
+		t.Fatalf("expected the event not to carry the value, got %v", ev)
+	}
+}
+
+func TestVerifyPrivate(t *testing.T) {
+	stub := newTestStub(t)
+	if res := stub.invoke([]byte("secret"), "putprivate", "collection1", "x"); res.Status != shim.OK {
+		t.Fatalf("putprivate failed: %s", res.Message)
+	}
+
+	for _, tc := range []struct {
+		value string
+		match bool
+	}{
+		{"secret", true},
+		{"guess", false},
+	} {
+		res := stub.invoke([]byte(tc.value), "verifyprivate", "collection1", "x")
+		if res.Status != shim.OK {
+			t.Fatalf("verifyprivate failed: %s", res.Message)
+		}
+		v := &hashVerification{}
+		if err := json.Unmarshal(res.Payload, v); err != nil {
+			t.Fatalf("invalid verification: %s", err)
+		}
+		want := hashVerification{Collection: "collection1", Key: "x", Hash: hashOf("secret"), Match: tc.match}
+		if *v != want {
+			t.Fatalf("expected %+v for value [%s], got %+v", want, tc.value, *v)
+		}
+	}
+
+	// A deleted key can no longer be verified
+	if res := stub.invoke(nil, "delprivate", "collection1", "x"); res.Status != shim.OK {
+		t.Fatalf("delprivate failed: %s", res.Message)
+	}
+	if res := stub.invoke([]byte("secret"), "verifyprivate", "collection1", "x"); errorCode(t, res) != errCodeNotFound {
+		t.Fatalf("expected a deleted key not to be found, got %s", res.Message)
+	}
+}
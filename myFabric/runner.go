@@ -7,8 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
-	"fmt"
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
 	"os"
 	"testing"
 )
@@ -21,6 +22,7 @@ const (
 	org1User      = "User1"
 	org2User      = "User1"
 	channelID     = "mychannel"
+	orgChannelID  = "orgchannel"
 	ccPath        = "example_cc"
 )
 
@@ -34,10 +36,14 @@ type Runner struct {
 	Org2User           string
 	ChannelID          string
 	CCPath             string
+	Manifest           *NetworkManifest
+	MultiOrg           bool
 	sdk                *fabsdk.FabricSDK
 	testSetup          *BaseSetupImpl
 	installExampleCC   bool
 	exampleChaincodeID string
+	changes            []string
+	orgContexts        []*OrgContext
 }
 
 // New constructs a Runner instance using defaults.
@@ -64,6 +70,16 @@ func NewWithExampleCC() *Runner {
 	return r
 }
 
+// NewMultiOrgWithExampleCC constructs a Runner instance for both orgs on the org channel and
+// configures to install example CC on both, endorsed by a member of each.
+func NewMultiOrgWithExampleCC() *Runner {
+	r := NewWithExampleCC()
+	r.ChannelID = orgChannelID
+	r.MultiOrg = true
+
+	return r
+}
+
 // Run executes the test suite against ExampleCC.
 func (r *Runner) Run(m *testing.M) {
 	gr := m.Run()
@@ -90,8 +106,34 @@ func (r *Runner) ExampleChaincodeID() string {
 	return r.exampleChaincodeID
 }
 
+// Changes returns the changes made by reconciling the manifest.
+func (r *Runner) Changes() []string {
+	return r.changes
+}
+
+// OrgContexts returns the admin contexts of the orgs of the multi-org mode, Org1 first.
+func (r *Runner) OrgContexts() []*OrgContext {
+	return r.orgContexts
+}
+
 // Initialize prepares for the test run.
 func (r *Runner) Initialize() {
+	if err := r.Prepare(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Prepare creates the SDK, creates and joins the channel and installs example CC if configured.
+// With a manifest the network of the manifest is reconciled instead, in multi-org mode the
+// channel is created for both orgs. Unlike Initialize it returns an error instead of panicking.
+func (r *Runner) Prepare() error {
+	if r.Manifest != nil {
+		return r.prepareManifest()
+	}
+	if r.MultiOrg {
+		return r.prepareMultiOrg()
+	}
+
 	r.testSetup = &BaseSetupImpl{
 		ChannelID:         r.ChannelID,
 		OrgID:             r.Org1Name,
@@ -100,7 +142,7 @@ func (r *Runner) Initialize() {
 
 	sdk, err := fabsdk.New(ConfigBackend)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to create new SDK: %s", err))
+		return errors.WithMessage(err, "Failed to create new SDK")
 	}
 	r.sdk = sdk
 
@@ -109,15 +151,150 @@ func (r *Runner) Initialize() {
 	CleanupUserData(nil, sdk)
 
 	if err := r.testSetup.Initialize(sdk); err != nil {
-		panic(err.Error())
+		return err
+	}
+
+	if r.installExampleCC {
+		r.exampleChaincodeID = GenerateExampleID(false)
+		if err := PrepareExampleCC(sdk, fabsdk.WithUser("Admin"), fabsdk.WithUser(r.Org1User), r.testSetup.OrgID, r.exampleChaincodeID); err != nil {
+			return errors.WithMessage(err, "PrepareExampleCC return error")
+		}
+	}
+
+	return nil
+}
+
+// prepareManifest reconciles the network of the manifest. The first channel and its first
+// member are used for the test setup, the first chaincode at the path of example CC as example CC.
+// The keys the org admin set when instantiating example CC are handed over to Org1User.
+func (r *Runner) prepareManifest() error {
+	if len(r.Manifest.Channels) == 0 {
+		return errors.New("manifest declares no channel")
+	}
+	ch := r.Manifest.Channels[0]
+	org := r.Manifest.Org(ch.Members[0].Org)
+
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to create new SDK")
+	}
+	r.sdk = sdk
+
+	// Delete all private keys from the crypto suite store
+	// and users from the user store
+	CleanupUserData(nil, sdk)
+
+	r.changes, err = ReconcileNetwork(sdk, r.Manifest)
+	if err != nil {
+		return errors.WithMessage(err, "reconciling network manifest failed")
+	}
+
+	r.ChannelID = ch.Name
+	r.Org1Name = org.Name
+	r.Org1AdminUser = org.AdminUser
+	r.testSetup = &BaseSetupImpl{
+		ChannelID: ch.Name,
+		OrgID:     org.Name,
+	}
+	if ch.Tx != "" {
+		r.testSetup.ChannelConfigFile = GetChannelConfigPath(ch.Tx)
+	}
+
+	mspClient, err := mspclient.New(sdk.Context(), mspclient.WithOrg(org.Name))
+	if err != nil {
+		return errors.WithMessage(err, "failed to create MSP client")
+	}
+	if r.testSetup.Identity, err = mspClient.GetSigningIdentity(org.AdminUser); err != nil {
+		return errors.WithMessage(err, "failed to get admin identity")
+	}
+	configBackend, err := sdk.Config()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get config backend")
+	}
+	if r.testSetup.Targets, err = OrgTargetPeers([]string{org.Name}, configBackend); err != nil {
+		return errors.WithMessage(err, "loading target peers from config failed")
+	}
+
+	for _, cc := range r.Manifest.Chaincodes {
+		if cc.Path == exampleCCPath && cc.Channel == ch.Name {
+			r.exampleChaincodeID = cc.Name
+			return handOverExampleKeys(sdk, fabsdk.WithUser(org.AdminUser), fabsdk.WithUser(r.Org1User), org.Name, ch.Name, cc.Name)
+		}
+	}
+	return nil
+}
+
+// prepareMultiOrg creates the channel with the anchor peers of both orgs, joins the peers of both
+// and installs example CC on both orgs with a policy requiring an endorsement from each.
+// Org1 is used for the test setup.
+func (r *Runner) prepareMultiOrg() error {
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		return errors.WithMessage(err, "Failed to create new SDK")
+	}
+	r.sdk = sdk
+
+	// Delete all private keys from the crypto suite store
+	// and users from the user store
+	CleanupUserData(nil, sdk)
+
+	orgs, err := SetupMultiOrgContext(sdk, r.Org1Name, r.Org2Name, r.Org1AdminUser, r.Org2AdminUser)
+	if err != nil {
+		return errors.WithMessage(err, "setting up multi-org context failed")
+	}
+	for _, orgCtx := range orgs {
+		mspID, err := orgMSPID(sdk, orgCtx.OrgID)
+		if err != nil {
+			return errors.WithMessage(err, "MSP ID of org ["+orgCtx.OrgID+"] could not be determined")
+		}
+		orgCtx.AnchorPeerConfigFile = r.ChannelID + mspID + "anchors.tx"
+	}
+	if err := checkMultiOrgChannelTxs(r.ChannelID, orgs); err != nil {
+		return err
+	}
+	r.orgContexts = orgs
+
+	r.testSetup = &BaseSetupImpl{
+		ChannelID:         r.ChannelID,
+		OrgID:             r.Org1Name,
+		Identity:          orgs[0].SigningIdentity,
+		ChannelConfigFile: GetChannelConfigPath(r.ChannelID + ".tx"),
+	}
+	configBackend, err := sdk.Config()
+	if err != nil {
+		return errors.WithMessage(err, "failed to get config backend")
+	}
+	if r.testSetup.Targets, err = OrgTargetPeers([]string{r.Org1Name, r.Org2Name}, configBackend); err != nil {
+		return errors.WithMessage(err, "loading target peers from config failed")
+	}
+
+	if err := EnsureChannelCreatedAndPeersJoined(nil, sdk, r.ChannelID, r.ChannelID+".tx", orgs); err != nil {
+		return errors.WithMessage(err, "creating channel ["+r.ChannelID+"] for both orgs failed")
 	}
 
 	if r.installExampleCC {
 		r.exampleChaincodeID = GenerateExampleID(false)
-		if err := PrepareExampleCC(sdk, fabsdk.WithUser("Admin"), r.testSetup.OrgID, r.exampleChaincodeID); err != nil {
-			panic(fmt.Sprintf("PrepareExampleCC return error: %s", err))
+		if err := PrepareMultiOrgExampleCC(sdk, orgs, fabsdk.WithUser(r.Org1User), r.ChannelID, r.exampleChaincodeID); err != nil {
+			return errors.WithMessage(err, "PrepareMultiOrgExampleCC return error")
+		}
+	}
+
+	return nil
+}
+
+// checkMultiOrgChannelTxs fails unless the fixtures hold the channel tx and the anchor peer
+// txs of all orgs, so that an unsupported channel is rejected before creating anything
+func checkMultiOrgChannelTxs(channelID string, orgs []*OrgContext) error {
+	files := []string{channelID + ".tx"}
+	for _, orgCtx := range orgs {
+		files = append(files, orgCtx.AnchorPeerConfigFile)
+	}
+	for _, file := range files {
+		if _, err := os.Stat(GetChannelConfigPath(file)); err != nil {
+			return errors.Errorf("multi-org channel [%s] is not supported, channel tx [%s] not found, use channel [%s]", channelID, file, orgChannelID)
 		}
 	}
+	return nil
 }
 
 func (r *Runner) teardown() {
@@ -0,0 +1,75 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+func TestPrepareMembersPolicy(t *testing.T) {
+	sdk, err := fabsdk.New(ConfigBackend)
+	if err != nil {
+		t.Fatalf("creating SDK failed: %s", err)
+	}
+	defer sdk.Close()
+
+	policy, err := prepareMembersPolicy(sdk, []string{org1Name, org2Name})
+	if err != nil {
+		t.Fatalf("preparing policy failed: %s", err)
+	}
+	if want := "AND('Org1MSP.member','Org2MSP.member')"; policy != want {
+		t.Fatalf("expected policy %s, got %s", want, policy)
+	}
+
+	if _, err := prepareMembersPolicy(sdk, []string{org1Name, "NoSuchOrg"}); err == nil {
+		t.Fatal("expected an unknown org to fail")
+	}
+}
+
+func TestCheckMultiOrgChannelTxs(t *testing.T) {
+	anchors := func(channelID string) []*OrgContext {
+		return []*OrgContext{
+			{OrgID: org1Name, AnchorPeerConfigFile: channelID + "Org1MSPanchors.tx"},
+			{OrgID: org2Name, AnchorPeerConfigFile: channelID + "Org2MSPanchors.tx"},
+		}
+	}
+	if err := checkMultiOrgChannelTxs(orgChannelID, anchors(orgChannelID)); err != nil {
+		t.Fatalf("expected the txs of %s to be found, got %s", orgChannelID, err)
+	}
+	// mychannel has a channel tx but no anchor peer txs
+	if err := checkMultiOrgChannelTxs(channelID, anchors(channelID)); err == nil {
+		t.Fatalf("expected %s without anchor peer txs to be rejected", channelID)
+	}
+	if err := checkMultiOrgChannelTxs("otherchannel", anchors("otherchannel")); err == nil {
+		t.Fatal("expected a channel without txs to be rejected")
+	}
+}
+
+func TestNewMultiOrgWithExampleCC(t *testing.T) {
+	r := NewMultiOrgWithExampleCC()
+	if !r.MultiOrg || !r.installExampleCC {
+		t.Fatal("expected a multi-org runner installing example CC")
+	}
+	if r.ChannelID != orgChannelID {
+		t.Fatalf("expected channel [%s], got [%s]", orgChannelID, r.ChannelID)
+	}
+	if r.Org1Name != org1Name || r.Org2Name != org2Name {
+		t.Fatalf("expected orgs %s and %s, got %s and %s", org1Name, org2Name, r.Org1Name, r.Org2Name)
+	}
+}
+
+// The TestMain of an integration test package creates orgchannel for both orgs and
+// instantiates example CC endorsed by a member of each before running the tests
+func ExampleNewMultiOrgWithExampleCC() {
+	var m *testing.M // passed to TestMain
+
+	r := NewMultiOrgWithExampleCC()
+	r.Initialize()
+	r.Run(m)
+}
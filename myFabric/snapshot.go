@@ -0,0 +1,164 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/pkg/errors"
+)
+
+// DefaultSnapshotPageSize is the number of keys fetched by each range query of a snapshot
+const DefaultSnapshotPageSize = 100
+
+// StateRecord is a key of example CC and its value
+type StateRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// StatePage is a page of keys returned by a range query of example CC
+type StatePage struct {
+	Records  []StateRecord `json:"records"`
+	Count    int32         `json:"count"`
+	Bookmark string        `json:"bookmark"`
+}
+
+// Snapshot is the world state of example CC at some point in time
+type Snapshot struct {
+	ChannelID   string        `json:"channelId"`
+	ChaincodeID string        `json:"chaincodeId"`
+	TakenAt     time.Time     `json:"takenAt"`
+	Records     []StateRecord `json:"records"`
+}
+
+// StateChange is a key whose value differs between two snapshots
+type StateChange struct {
+	Key      string `json:"key"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+}
+
+// StateDiff lists the keys added, removed and changed between two snapshots
+type StateDiff struct {
+	Added   []StateRecord `json:"added"`
+	Removed []StateRecord `json:"removed"`
+	Changed []StateChange `json:"changed"`
+}
+
+// Empty returns true if both snapshots hold the same state
+func (d *StateDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// QueryStateRangeWithContext queries a page of the keys in [startKey, endKey) of cc, empty keys
+// mean an open range. Pass the bookmark of the previous page to get the next one.
+// The call is abandoned when reqCtx is done. On failure a *LedgerError is returned.
+func QueryStateRangeWithContext(reqCtx context.Context, chClient *channel.Client, ccID, startKey, endKey string, pageSize int32, bookmark string, policy RetryPolicy) (*StatePage, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCRangeArgs(startKey, endKey, pageSize, bookmark),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "range", startKey, err)
+	}
+
+	page := &StatePage{}
+	if err := json.Unmarshal(response.Payload, page); err != nil {
+		return nil, errors.Wrap(err, "decoding range response failed")
+	}
+	return page, nil
+}
+
+// TakeSnapshot reads all keys of cc page by page. The pages are read in separate queries,
+// so keys written while the snapshot is taken may or may not be included.
+func TakeSnapshot(reqCtx context.Context, chClient *channel.Client, channelID, ccID string, pageSize int32, policy RetryPolicy) (*Snapshot, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultSnapshotPageSize
+	}
+
+	snapshot := &Snapshot{ChannelID: channelID, ChaincodeID: ccID, TakenAt: time.Now().UTC(), Records: []StateRecord{}}
+	bookmark := ""
+	for {
+		page, err := QueryStateRangeWithContext(reqCtx, chClient, ccID, "", "", pageSize, bookmark, policy)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Records = append(snapshot.Records, page.Records...)
+		if page.Bookmark == "" || page.Count < pageSize {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+
+	sort.Slice(snapshot.Records, func(i, j int) bool { return snapshot.Records[i].Key < snapshot.Records[j].Key })
+	return snapshot, nil
+}
+
+// LoadSnapshot reads a snapshot saved by SaveSnapshot
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading snapshot [%s] failed", path)
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, errors.Wrapf(err, "decoding snapshot [%s] failed", path)
+	}
+	return snapshot, nil
+}
+
+// SaveSnapshot writes the snapshot to path as indented JSON
+func SaveSnapshot(path string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding snapshot failed")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, data, 0644), "writing snapshot [%s] failed", path)
+}
+
+// DiffSnapshots compares the state of two snapshots, the keys of the result are sorted
+func DiffSnapshots(from, to *Snapshot) *StateDiff {
+	fromValues := make(map[string]string, len(from.Records))
+	for _, r := range from.Records {
+		fromValues[r.Key] = r.Value
+	}
+	toValues := make(map[string]string, len(to.Records))
+	for _, r := range to.Records {
+		toValues[r.Key] = r.Value
+	}
+
+	diff := &StateDiff{Added: []StateRecord{}, Removed: []StateRecord{}, Changed: []StateChange{}}
+	for _, r := range to.Records {
+		oldValue, ok := fromValues[r.Key]
+		if !ok {
+			diff.Added = append(diff.Added, r)
+		} else if oldValue != r.Value {
+			diff.Changed = append(diff.Changed, StateChange{Key: r.Key, OldValue: oldValue, NewValue: r.Value})
+		}
+	}
+	for _, r := range from.Records {
+		if _, ok := toValues[r.Key]; !ok {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Key < diff.Added[j].Key })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Key < diff.Removed[j].Key })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Key < diff.Changed[j].Key })
+	return diff
+}
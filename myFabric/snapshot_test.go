@@ -0,0 +1,121 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	records := func(kv ...string) *Snapshot {
+		s := &Snapshot{}
+		for i := 0; i < len(kv); i += 2 {
+			s.Records = append(s.Records, StateRecord{Key: kv[i], Value: kv[i+1]})
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		from    *Snapshot
+		to      *Snapshot
+		added   []StateRecord
+		removed []StateRecord
+		changed []StateChange
+	}{
+		{"both empty", records(), records(), nil, nil, nil},
+		{"equal", records("a", "1", "b", "2"), records("b", "2", "a", "1"), nil, nil, nil},
+		{"added", records("a", "1"), records("c", "3", "a", "1", "b", "2"), []StateRecord{{"b", "2"}, {"c", "3"}}, nil, nil},
+		{"removed", records("c", "3", "a", "1", "b", "2"), records("b", "2"), nil, []StateRecord{{"a", "1"}, {"c", "3"}}, nil},
+		{"changed", records("b", "2", "a", "1"), records("a", "10", "b", "20"), nil, nil, []StateChange{{"a", "1", "10"}, {"b", "2", "20"}}},
+		{"changed to empty", records("a", "1"), records("a", ""), nil, nil, []StateChange{{"a", "1", ""}}},
+		{"all kinds", records("a", "1", "b", "2", "c", "3"), records("d", "4", "b", "2", "c", "30"),
+			[]StateRecord{{"d", "4"}}, []StateRecord{{"a", "1"}}, []StateChange{{"c", "3", "30"}}},
+		{"from empty", records(), records("a", "1"), []StateRecord{{"a", "1"}}, nil, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := DiffSnapshots(tc.from, tc.to)
+			// The diff always has empty rather than nil lists, so that it encodes as []
+			if diff.Added == nil || diff.Removed == nil || diff.Changed == nil {
+				t.Fatalf("expected empty lists instead of nil, got %+v", diff)
+			}
+			if len(diff.Added) != len(tc.added) || len(tc.added) > 0 && !reflect.DeepEqual(diff.Added, tc.added) {
+				t.Errorf("expected added %v, got %v", tc.added, diff.Added)
+			}
+			if len(diff.Removed) != len(tc.removed) || len(tc.removed) > 0 && !reflect.DeepEqual(diff.Removed, tc.removed) {
+				t.Errorf("expected removed %v, got %v", tc.removed, diff.Removed)
+			}
+			if len(diff.Changed) != len(tc.changed) || len(tc.changed) > 0 && !reflect.DeepEqual(diff.Changed, tc.changed) {
+				t.Errorf("expected changed %v, got %v", tc.changed, diff.Changed)
+			}
+			if empty := len(tc.added)+len(tc.removed)+len(tc.changed) == 0; diff.Empty() != empty {
+				t.Errorf("expected Empty %t, got %t", empty, diff.Empty())
+			}
+		})
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	state := map[string]string{"e": "5", "a": "1", "d": "4", "b": "2", "c": "3"}
+	tests := []struct {
+		name     string
+		pageSize int32
+		queries  int
+	}{
+		{"single page", 10, 1},
+		{"pages", 2, 3},
+		{"exact page", 5, 1},
+		{"default page size", 0, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			peer := newMockPeer(newFakeExampleCC(state).handle)
+			setMockClients(t, peer)
+
+			snapshot, err := TakeSnapshot(context.Background(), chClient, mockChannelID, "examplecc", tc.pageSize, GatewayRetryPolicy)
+			if err != nil {
+				t.Fatalf("taking snapshot failed: %s", err)
+			}
+			want := []StateRecord{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}, {"e", "5"}}
+			if !reflect.DeepEqual(snapshot.Records, want) {
+				t.Fatalf("expected records %v, got %v", want, snapshot.Records)
+			}
+			if snapshot.ChannelID != mockChannelID || snapshot.ChaincodeID != "examplecc" || snapshot.TakenAt.IsZero() {
+				t.Fatalf("expected the channel, chaincode and time of the snapshot, got %+v", snapshot)
+			}
+			if len(peer.calls) != tc.queries {
+				t.Fatalf("expected %d range queries, got %d", tc.queries, len(peer.calls))
+			}
+		})
+	}
+}
+
+func TestSaveLoadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	saved := &Snapshot{ChannelID: mockChannelID, ChaincodeID: "examplecc", Records: []StateRecord{{"a", "1"}, {"b", ""}}}
+	if err := SaveSnapshot(path, saved); err != nil {
+		t.Fatalf("saving snapshot failed: %s", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loading snapshot failed: %s", err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Fatalf("expected %+v, got %+v", saved, loaded)
+	}
+	if !DiffSnapshots(saved, loaded).Empty() {
+		t.Fatal("expected no difference to the saved snapshot")
+	}
+
+	if _, err := LoadSnapshot(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected loading a missing snapshot to fail")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// TokenInfo is the configuration of example CC in token mode. Amounts are integers of
+// the smallest unit, Decimals is the number of digits of the fraction when displayed.
+type TokenInfo struct {
+	Issuer      string `json:"issuer"`
+	Decimals    int    `json:"decimals"`
+	Cap         int    `json:"cap"`
+	TotalSupply int    `json:"totalSupply"`
+}
+
+// FormatTokenAmount formats a non negative amount of the smallest unit with the given
+// decimals, e.g. 12345 with 2 decimals as 123.45
+func FormatTokenAmount(amount, decimals int) string {
+	s := strconv.Itoa(amount)
+	if decimals <= 0 {
+		return s
+	}
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	return s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+}
+
+// ParseTokenAmount parses a decimal amount into the smallest unit, e.g. 1.5 with 2
+// decimals as 150. The amount may not have more fraction digits than decimals.
+func ParseTokenAmount(s string, decimals int) (int, error) {
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	if intPart == "" && frac == "" {
+		return 0, errors.Errorf("invalid token amount [%s]", s)
+	}
+	if len(frac) > decimals {
+		return 0, errors.Errorf("invalid token amount [%s], expecting at most %d decimals", s, decimals)
+	}
+
+	digits := intPart + frac + strings.Repeat("0", decimals-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, errors.Errorf("invalid token amount [%s], expecting a non negative decimal number", s)
+		}
+	}
+	amount, err := strconv.ParseInt(digits, 10, strconv.IntSize)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid token amount [%s]", s)
+	}
+	return int(amount), nil
+}
+
+// MintWithContext creates an amount of tokens in the given key of cc. Only members of the
+// issuer MSP may mint and the total supply may not exceed the cap. The key must already be a
+// token account, created by its owner with a value of 0. On failure a *LedgerError
+// is returned, with Kind ErrForbidden if the caller is not the issuer.
+func MintWithContext(reqCtx context.Context, chClient *channel.Client, ccID, to string, amount int, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCMintArgs(to, amount),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "mint", to, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// BurnWithContext destroys an amount of tokens of the given key of cc. Only members of the
+// issuer MSP that own the key may burn. On failure a *LedgerError is returned.
+func BurnWithContext(reqCtx context.Context, chClient *channel.Client, ccID, from string, amount int, policy RetryPolicy) (fabAPI.TransactionID, error) {
+	response, err := chClient.Execute(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "invoke",
+			Args:        ExampleCCBurnArgs(from, amount),
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return "", newLedgerErrorWithContext(reqCtx, "burn", from, err)
+	}
+
+	return response.TransactionID, nil
+}
+
+// GetTokenInfoWithContext queries the token configuration and total supply of cc.
+// On failure a *LedgerError is returned, the chaincode error code is CCErrTokenModeDisabled
+// if cc was not initialized in token mode.
+func GetTokenInfoWithContext(reqCtx context.Context, chClient *channel.Client, ccID string, policy RetryPolicy) (*TokenInfo, error) {
+	response, err := chClient.Query(
+		channel.Request{
+			ChaincodeID: ccID,
+			Fcn:         "totalsupply",
+		},
+		channel.WithParentContext(reqCtx),
+		channel.WithRetry(policy.Opts))
+	if err != nil {
+		return nil, newLedgerErrorWithContext(reqCtx, "totalsupply", "", err)
+	}
+
+	info := &TokenInfo{}
+	if err := json.Unmarshal(response.Payload, info); err != nil {
+		return nil, errors.Wrap(err, "decoding token info failed")
+	}
+	return info, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatTokenAmount(t *testing.T) {
+	tests := []struct {
+		amount   int
+		decimals int
+		want     string
+	}{
+		{12345, 2, "123.45"},
+		{5, 2, "0.05"},
+		{50, 2, "0.50"},
+		{0, 2, "0.00"},
+		{100, 0, "100"},
+		{1, 18, "0.000000000000000001"},
+		{math.MaxInt64, 18, "9.223372036854775807"},
+	}
+	for _, tc := range tests {
+		if got := FormatTokenAmount(tc.amount, tc.decimals); got != tc.want {
+			t.Errorf("expected %d with %d decimals to be formatted as %s, got %s", tc.amount, tc.decimals, tc.want, got)
+		}
+	}
+}
+
+func TestParseTokenAmount(t *testing.T) {
+	tests := []struct {
+		s        string
+		decimals int
+		want     int
+		wantErr  bool
+	}{
+		{s: "123.45", decimals: 2, want: 12345},
+		{s: "1.5", decimals: 2, want: 150},
+		{s: "1", decimals: 2, want: 100},
+		{s: ".5", decimals: 2, want: 50},
+		{s: "5.", decimals: 2, want: 500},
+		{s: "007", decimals: 0, want: 7},
+		{s: "9.223372036854775807", decimals: 18, want: math.MaxInt64},
+		{s: "1.234", decimals: 2, wantErr: true},
+		{s: "1.5", decimals: 0, wantErr: true},
+		{s: ".", decimals: 2, wantErr: true},
+		{s: "", decimals: 2, wantErr: true},
+		{s: "-1", decimals: 2, wantErr: true},
+		{s: "+1", decimals: 2, wantErr: true},
+		{s: "1e3", decimals: 2, wantErr: true},
+		{s: "1.2.3", decimals: 4, wantErr: true},
+		// 10 * 10^18 does not fit in an int64 and must not wrap around
+		{s: "10", decimals: 18, wantErr: true},
+		{s: "9.223372036854775808", decimals: 18, wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := ParseTokenAmount(tc.s, tc.decimals)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("expected [%s] with %d decimals to fail, got %d", tc.s, tc.decimals, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("expected [%s] with %d decimals to be parsed as %d, got %d: %v", tc.s, tc.decimals, tc.want, got, err)
+		}
+	}
+}
+
+func TestTokenAmountRoundTrip(t *testing.T) {
+	for _, decimals := range []int{0, 2, 6, 18} {
+		for _, amount := range []int{0, 1, 99, 100, 12345678, math.MaxInt64} {
+			s := FormatTokenAmount(amount, decimals)
+			got, err := ParseTokenAmount(s, decimals)
+			if err != nil || got != amount {
+				t.Errorf("expected %s to be parsed back as %d with %d decimals, got %d: %v", s, amount, decimals, got, err)
+			}
+		}
+	}
+}
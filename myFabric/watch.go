@@ -0,0 +1,205 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	contextAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	fabAPI "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/pkg/errors"
+)
+
+// Defaults for the ChaincodeEventWatcher
+const (
+	DefaultEventFilter    = ".*"
+	DefaultReconnectDelay = 5 * time.Second
+)
+
+// Checkpoint records the last event or block that was processed, so that processing
+// can resume from there after a restart
+type Checkpoint struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxID        string `json:"txId,omitempty"`
+}
+
+// LoadCheckpoint reads the checkpoint stored in path. It returns nil if there is none yet.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading checkpoint [%s] failed", path)
+	}
+
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, errors.Wrapf(err, "decoding checkpoint [%s] failed", path)
+	}
+	return cp, nil
+}
+
+// SaveCheckpoint atomically replaces the checkpoint stored in path
+func SaveCheckpoint(path string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return errors.Wrap(err, "encoding checkpoint failed")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "creating checkpoint file failed")
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "writing checkpoint failed")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "writing checkpoint failed")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ChaincodeEventWatcher delivers the chaincode events of example CC whose name matches a filter.
+// The last delivered event is stored in CheckpointPath, if set, and watching resumes after it.
+// When the event connection is lost the watcher reconnects, possibly to another peer,
+// and resumes from the checkpoint.
+type ChaincodeEventWatcher struct {
+	ChaincodeID    string
+	EventFilter    string
+	CheckpointPath string
+	ReconnectDelay time.Duration
+	ctxProvider    contextAPI.ChannelProvider
+}
+
+// NewChaincodeEventWatcher creates a watcher for the events of cc whose name matches the
+// eventFilter regular expression
+func NewChaincodeEventWatcher(ctx contextAPI.ChannelProvider, chaincodeID, eventFilter string) (*ChaincodeEventWatcher, error) {
+	if eventFilter == "" {
+		eventFilter = DefaultEventFilter
+	}
+	if _, err := regexp.Compile(eventFilter); err != nil {
+		return nil, errors.Wrapf(err, "invalid event filter [%s]", eventFilter)
+	}
+
+	return &ChaincodeEventWatcher{
+		ChaincodeID:    chaincodeID,
+		EventFilter:    eventFilter,
+		ReconnectDelay: DefaultReconnectDelay,
+		ctxProvider:    ctx,
+	}, nil
+}
+
+// Watch calls handler for every event until ctx is done or handler returns an error.
+// The checkpoint is only advanced after handler returned successfully.
+func (w *ChaincodeEventWatcher) Watch(ctx context.Context, handler func(*fabAPI.CCEvent) error) error {
+	cp, err := w.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	for {
+		cp, err = w.watchFrom(ctx, cp, handler)
+		if err != errEventsDisconnected {
+			return err
+		}
+
+		logger.Warnf("chaincode event connection lost, reconnecting in %s", w.ReconnectDelay)
+		select {
+		case <-time.After(w.ReconnectDelay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+var errEventsDisconnected = errors.New("event connection lost")
+
+// watchFrom delivers events after cp, or new events if cp is nil, and returns the last checkpoint
+func (w *ChaincodeEventWatcher) watchFrom(ctx context.Context, cp *Checkpoint, handler func(*fabAPI.CCEvent) error) (*Checkpoint, error) {
+	// Block events are needed since filtered block events carry no payload
+	opts := []event.ClientOption{event.WithBlockEvents()}
+	if cp != nil {
+		opts = append(opts, event.WithSeekType(seek.FromBlock), event.WithBlockNum(cp.BlockNumber))
+	} else {
+		opts = append(opts, event.WithSeekType(seek.Newest))
+	}
+
+	events, err := event.New(w.ctxProvider, opts...)
+	if err != nil {
+		if ClassifyError(err) == ErrConnection {
+			logger.Warnf("failed to connect event client: %s", err)
+			return cp, errEventsDisconnected
+		}
+		return cp, errors.WithMessage(err, "failed to create new event client")
+	}
+
+	reg, notifier, err := events.RegisterChaincodeEvent(w.ChaincodeID, w.EventFilter)
+	if err != nil {
+		return cp, errors.WithMessage(err, "failed to register chaincode event")
+	}
+	defer events.Unregister(reg)
+
+	// Seeking starts at the checkpoint block, so the events of that block are
+	// delivered again up to and including the checkpoint transaction
+	skipping := cp != nil && cp.TxID != ""
+
+	for {
+		select {
+		case <-ctx.Done():
+			return cp, nil
+		case ccEvent, ok := <-notifier:
+			if !ok {
+				return cp, errEventsDisconnected
+			}
+			if skipping {
+				if ccEvent.BlockNumber < cp.BlockNumber {
+					continue
+				}
+				if ccEvent.BlockNumber == cp.BlockNumber {
+					skipping = ccEvent.TxID != cp.TxID
+					continue
+				}
+				skipping = false
+			}
+
+			if err := handler(ccEvent); err != nil {
+				return cp, err
+			}
+
+			cp = &Checkpoint{BlockNumber: ccEvent.BlockNumber, TxID: ccEvent.TxID}
+			if err := w.saveCheckpoint(cp); err != nil {
+				return cp, err
+			}
+		}
+	}
+}
+
+func (w *ChaincodeEventWatcher) loadCheckpoint() (*Checkpoint, error) {
+	if w.CheckpointPath == "" {
+		return nil, nil
+	}
+	return LoadCheckpoint(w.CheckpointPath)
+}
+
+func (w *ChaincodeEventWatcher) saveCheckpoint(cp *Checkpoint) error {
+	if w.CheckpointPath == "" {
+		return nil
+	}
+	return SaveCheckpoint(w.CheckpointPath, cp)
+}
@@ -0,0 +1,272 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/pkg/errors"
+)
+
+const watchCCID = "example_cc"
+
+// watchConnection is an event service connection of a watcher under test
+type watchConnection struct {
+	seek   *mockSeekParams
+	events chan *fab.CCEvent
+}
+
+// watchTest runs a ChaincodeEventWatcher against mock event service connections
+type watchTest struct {
+	t           *testing.T
+	checkpoint  string
+	connections chan watchConnection
+	handled     chan *fab.CCEvent
+	cancel      context.CancelFunc
+	done        chan error
+}
+
+// startWatch starts a watcher resuming from cp, if not nil. The first failures connections
+// fail with a connection error. Events are handled successfully unless handler fails them.
+func startWatch(t *testing.T, cp *Checkpoint, failures int, handler func(*fab.CCEvent) error) *watchTest {
+	wt := &watchTest{
+		t:           t,
+		checkpoint:  filepath.Join(t.TempDir(), "watch.checkpoint"),
+		connections: make(chan watchConnection, 1),
+		handled:     make(chan *fab.CCEvent, 10),
+		done:        make(chan error, 1),
+	}
+	if cp != nil {
+		if err := SaveCheckpoint(wt.checkpoint, cp); err != nil {
+			t.Fatalf("saving checkpoint failed: %s", err)
+		}
+	}
+
+	provider := newMockChannelProviderWithEvents(t, func(seek *mockSeekParams) (chan *fab.CCEvent, error) {
+		if failures > 0 {
+			failures--
+			return nil, status.New(status.ClientStatus, status.ConnectionFailed.ToInt32(), "connection refused", nil)
+		}
+		events := make(chan *fab.CCEvent)
+		wt.connections <- watchConnection{seek: seek, events: events}
+		return events, nil
+	})
+	w, err := NewChaincodeEventWatcher(provider, watchCCID, "")
+	if err != nil {
+		t.Fatalf("creating watcher failed: %s", err)
+	}
+	w.CheckpointPath = wt.checkpoint
+	w.ReconnectDelay = time.Millisecond
+
+	var ctx context.Context
+	ctx, wt.cancel = context.WithCancel(context.Background())
+	t.Cleanup(wt.cancel)
+	go func() {
+		wt.done <- w.Watch(ctx, func(ccEvent *fab.CCEvent) error {
+			if handler != nil {
+				if err := handler(ccEvent); err != nil {
+					return err
+				}
+			}
+			wt.handled <- ccEvent
+			return nil
+		})
+	}()
+	return wt
+}
+
+// connection waits for the next connection of the watcher
+func (wt *watchTest) connection() watchConnection {
+	select {
+	case conn := <-wt.connections:
+		return conn
+	case err := <-wt.done:
+		wt.t.Fatalf("expected the watcher to connect, it stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		wt.t.Fatal("timed out waiting for the watcher to connect")
+	}
+	return watchConnection{}
+}
+
+// send delivers the events on the connection and returns the IDs of the transactions
+// whose events were handled
+func (wt *watchTest) send(conn watchConnection, events ...*fab.CCEvent) []string {
+	for _, ccEvent := range events {
+		select {
+		case conn.events <- ccEvent:
+		case <-time.After(5 * time.Second):
+			wt.t.Fatalf("timed out delivering event of tx %s", ccEvent.TxID)
+		}
+	}
+	// The watcher received the last event, so it handled every event it did not skip,
+	// apart from possibly the last one
+	var txIDs []string
+	for {
+		select {
+		case ccEvent := <-wt.handled:
+			txIDs = append(txIDs, ccEvent.TxID)
+			if ccEvent == events[len(events)-1] {
+				return txIDs
+			}
+		case <-time.After(100 * time.Millisecond):
+			return txIDs
+		}
+	}
+}
+
+// stop cancels watching and returns the error Watch returned
+func (wt *watchTest) stop() error {
+	wt.cancel()
+	select {
+	case err := <-wt.done:
+		return err
+	case <-time.After(5 * time.Second):
+		wt.t.Fatal("timed out waiting for the watcher to stop")
+	}
+	return nil
+}
+
+// savedCheckpoint returns the checkpoint the watcher saved, the watcher must be stopped
+func (wt *watchTest) savedCheckpoint() *Checkpoint {
+	cp, err := LoadCheckpoint(wt.checkpoint)
+	if err != nil {
+		wt.t.Fatalf("loading checkpoint failed: %s", err)
+	}
+	return cp
+}
+
+func newCCEvent(block uint64, txID string) *fab.CCEvent {
+	return &fab.CCEvent{ChaincodeID: watchCCID, EventName: "set", BlockNumber: block, TxID: txID}
+}
+
+func TestChaincodeEventWatcherSkipsToCheckpoint(t *testing.T) {
+	wt := startWatch(t, &Checkpoint{BlockNumber: 5, TxID: "tx2"}, 0, nil)
+
+	conn := wt.connection()
+	if conn.seek.SeekType != seek.FromBlock || conn.seek.FromBlock != 5 {
+		t.Fatalf("expected to seek from block 5, got %+v", conn.seek)
+	}
+	// The events of the checkpoint block are delivered again up to and including the
+	// checkpoint transaction
+	handled := wt.send(conn, newCCEvent(4, "tx0"), newCCEvent(5, "tx1"), newCCEvent(5, "tx2"), newCCEvent(5, "tx3"), newCCEvent(6, "tx4"))
+	if !reflect.DeepEqual(handled, []string{"tx3", "tx4"}) {
+		t.Fatalf("expected the events of tx3 and tx4 to be handled, got %v", handled)
+	}
+	if err := wt.stop(); err != nil {
+		t.Fatalf("expected watching to stop without error, got %s", err)
+	}
+	if cp := wt.savedCheckpoint(); !reflect.DeepEqual(cp, &Checkpoint{BlockNumber: 6, TxID: "tx4"}) {
+		t.Fatalf("expected checkpoint at tx4 of block 6, got %+v", cp)
+	}
+}
+
+func TestChaincodeEventWatcherBlockCheckpoint(t *testing.T) {
+	// A checkpoint without transaction resumes with all the events of its block
+	wt := startWatch(t, &Checkpoint{BlockNumber: 5}, 0, nil)
+
+	conn := wt.connection()
+	if conn.seek.SeekType != seek.FromBlock || conn.seek.FromBlock != 5 {
+		t.Fatalf("expected to seek from block 5, got %+v", conn.seek)
+	}
+	handled := wt.send(conn, newCCEvent(5, "tx1"), newCCEvent(5, "tx2"), newCCEvent(6, "tx3"))
+	if !reflect.DeepEqual(handled, []string{"tx1", "tx2", "tx3"}) {
+		t.Fatalf("expected all events to be handled, got %v", handled)
+	}
+	if err := wt.stop(); err != nil {
+		t.Fatalf("expected watching to stop without error, got %s", err)
+	}
+}
+
+func TestChaincodeEventWatcherReconnect(t *testing.T) {
+	// The first connection attempt fails, the watcher retries
+	wt := startWatch(t, nil, 1, nil)
+
+	conn := wt.connection()
+	if conn.seek.SeekType != seek.Newest {
+		t.Fatalf("expected to seek the newest block without checkpoint, got %+v", conn.seek)
+	}
+	if handled := wt.send(conn, newCCEvent(7, "tx1"), newCCEvent(7, "tx2")); !reflect.DeepEqual(handled, []string{"tx1", "tx2"}) {
+		t.Fatalf("expected the events of tx1 and tx2 to be handled, got %v", handled)
+	}
+
+	// After a disconnect watching resumes from the checkpoint
+	close(conn.events)
+	conn = wt.connection()
+	if conn.seek.SeekType != seek.FromBlock || conn.seek.FromBlock != 7 {
+		t.Fatalf("expected to resume from block 7, got %+v", conn.seek)
+	}
+	if handled := wt.send(conn, newCCEvent(7, "tx1"), newCCEvent(7, "tx2"), newCCEvent(8, "tx3")); !reflect.DeepEqual(handled, []string{"tx3"}) {
+		t.Fatalf("expected only the event of tx3 to be handled, got %v", handled)
+	}
+	if err := wt.stop(); err != nil {
+		t.Fatalf("expected watching to stop without error, got %s", err)
+	}
+	if cp := wt.savedCheckpoint(); !reflect.DeepEqual(cp, &Checkpoint{BlockNumber: 8, TxID: "tx3"}) {
+		t.Fatalf("expected checkpoint at tx3 of block 8, got %+v", cp)
+	}
+}
+
+func TestChaincodeEventWatcherHandlerError(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+	wt := startWatch(t, &Checkpoint{BlockNumber: 5, TxID: "tx1"}, 0, func(ccEvent *fab.CCEvent) error {
+		if ccEvent.TxID == "tx3" {
+			return handlerErr
+		}
+		return nil
+	})
+
+	conn := wt.connection()
+	wt.send(conn, newCCEvent(5, "tx1"), newCCEvent(5, "tx2"), newCCEvent(6, "tx3"))
+	select {
+	case err := <-wt.done:
+		if err != handlerErr {
+			t.Fatalf("expected the handler error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watcher to stop")
+	}
+	// The checkpoint is not advanced past the failed event
+	if cp := wt.savedCheckpoint(); !reflect.DeepEqual(cp, &Checkpoint{BlockNumber: 5, TxID: "tx2"}) {
+		t.Fatalf("expected checkpoint at tx2 of block 5, got %+v", cp)
+	}
+}
+
+func TestSaveLoadCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.checkpoint")
+	if cp, err := LoadCheckpoint(path); err != nil || cp != nil {
+		t.Fatalf("expected no checkpoint before saving, got %+v: %v", cp, err)
+	}
+
+	for _, saved := range []*Checkpoint{{BlockNumber: 3, TxID: "tx1"}, {BlockNumber: 4}} {
+		if err := SaveCheckpoint(path, saved); err != nil {
+			t.Fatalf("saving checkpoint failed: %s", err)
+		}
+		cp, err := LoadCheckpoint(path)
+		if err != nil || !reflect.DeepEqual(cp, saved) {
+			t.Fatalf("expected checkpoint %+v, got %+v: %v", saved, cp, err)
+		}
+	}
+	// The checkpoint is replaced without leaving temporary files behind
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Fatalf("expected only the checkpoint file, got %d files", len(files))
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("writing checkpoint failed: %s", err)
+	}
+	if _, err := LoadCheckpoint(path); err == nil {
+		t.Fatal("expected an invalid checkpoint to fail")
+	}
+}